@@ -0,0 +1,166 @@
+package aprsgo
+
+// address.go contains the Address type for AX.25 destination, source and digipeater
+// addresses and its encoding in the 7 byte address field
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// The SSID byte of an address field, bit 7 to bit 0: C/H, two reserved bits, the 4 bit SSID and the extension bit
+const (
+	addressCHBit      byte = 0x80 // the command bit of destination and source, the has-been-repeated bit of digipeaters
+	addressReserved   byte = 0x60 // reserved bits, set to one
+	addressSSIDMask   byte = 0x1e
+	addressExtension  byte = 0x01 // marks the last address in the address field
+	addressFieldSize       = 7
+	maxTacticalLength      = 9 // tactical callsigns, including any SSID, as used on APRS-IS
+)
+
+// Address is a station address as used for the destination, source and digipeaters of a frame
+type Address struct {
+	Callsign string // 1 to 6 upper case letters and digits, up to 9 for tactical callsigns
+	SSID     SSID
+	Repeated bool // the H bit, set once a digipeater has repeated the frame
+}
+
+// ParseAddress parses an AX.25 address such as N0CALL-7, a trailing * marks a repeated digipeater.
+// Problems are reported as a *CallsignError or *SSIDError.
+func ParseAddress(s string) (Address, error) {
+	address, err := parseAddress(s)
+	if err != nil {
+		return address, err
+	}
+	return address, address.Validate()
+}
+
+// ParseTacticalAddress parses an address that may also be a tactical callsign of up to 9 letters
+// and digits, including any SSID, such as EOC or SHELTER-2. Tactical callsigns appear in
+// third-party headers and on APRS-IS but cannot be sent in an AX.25 address field.
+func ParseTacticalAddress(s string) (Address, error) {
+	address, err := parseAddress(s)
+	if err != nil {
+		return address, err
+	}
+	if len(address.Callsign) <= maxCallsignLength {
+		return address, address.Validate()
+	}
+	if n := len(strings.TrimSuffix(s, "*")); n > maxTacticalLength {
+		return address, &CallsignError{Callsign: s, Reason: fmt.Sprintf("tactical callsigns are limited to %d characters", maxTacticalLength)}
+	}
+	if err := validateCallsignCharacters(address.Callsign); err != nil {
+		return address, err
+	}
+	return address, nil
+}
+
+// parseAddress splits CALL-SSID* into its parts without checking the callsign
+func parseAddress(s string) (Address, error) {
+	var address Address
+	callsign := strings.TrimSuffix(s, "*")
+	address.Repeated = len(callsign) < len(s)
+	if dash := strings.LastIndexByte(callsign, '-'); dash >= 0 {
+		ssid, err := strconv.ParseUint(callsign[dash+1:], 10, 8)
+		if err != nil {
+			return address, &CallsignError{Callsign: s, Reason: "the SSID must be a number"}
+		}
+		if ssid > maxSSID {
+			return address, &SSIDError{SSID: SSID(ssid)}
+		}
+		address.SSID = SSID(ssid)
+		callsign = callsign[:dash]
+	}
+	address.Callsign = callsign
+	return address, nil
+}
+
+// String returns the address as CALL-SSID, the SSID is left out if 0 and a * is added once repeated
+func (a Address) String() string {
+	s := a.Callsign
+	if a.SSID != 0 {
+		s = fmt.Sprintf("%s-%d", s, a.SSID)
+	}
+	if a.Repeated {
+		s += "*"
+	}
+	return s
+}
+
+// Network returns "ax25" so an Address can be used as a net.Addr
+func (a Address) Network() string {
+	return "ax25"
+}
+
+// Equal returns true if both addresses are the same station, the H bit is ignored
+func (a Address) Equal(b Address) bool {
+	return a.Callsign == b.Callsign && a.SSID == b.SSID
+}
+
+// IsTactical returns true if the callsign is too long for an AX.25 address field
+func (a Address) IsTactical() bool {
+	return len(a.Callsign) > maxCallsignLength
+}
+
+// Validate checks the address fits an AX.25 address field
+func (a Address) Validate() error {
+	if a.Callsign == "" {
+		return &CallsignError{Callsign: a.Callsign, Reason: "empty"}
+	}
+	if len(a.Callsign) > maxCallsignLength {
+		return &CallsignError{Callsign: a.Callsign, Reason: fmt.Sprintf("longer than %d characters", maxCallsignLength)}
+	}
+	if err := validateCallsignCharacters(a.Callsign); err != nil {
+		return err
+	}
+	if a.SSID > maxSSID {
+		return &SSIDError{SSID: a.SSID}
+	}
+	return nil
+}
+
+// validateCallsignCharacters checks the callsign only has upper case letters and digits
+func validateCallsignCharacters(callsign string) error {
+	for i := 0; i < len(callsign); i++ {
+		if c := callsign[i]; !(c >= 'A' && c <= 'Z') && !(c >= '0' && c <= '9') {
+			return &CallsignError{Callsign: callsign, Reason: "only upper case letters and digits are allowed"}
+		}
+	}
+	return nil
+}
+
+// field returns the 7 byte address field, shifted left one bit as sent.
+// The C/H bit is set if chBit, for the destination of a command frame or a repeated digipeater,
+// and the extension bit is set if the address is the last one.
+func (a Address) field(chBit bool, last bool) [addressFieldSize]byte {
+	var field [addressFieldSize]byte
+	copy(field[:], "      ")    // intialize the field with spaces for shorter callsigns
+	copy(field[:6], a.Callsign) // callsigns are validated before the field is assembled
+	for i := 0; i < 6; i++ {
+		field[i] = field[i] << 1 // AX.25 addresses are shifted left one bit
+	}
+	field[6] = addressReserved | (byte(a.SSID)<<1)&addressSSIDMask
+	if chBit {
+		field[6] |= addressCHBit
+	}
+	if last {
+		field[6] |= addressExtension
+	}
+	return field
+}
+
+// decodeAddressField converts a shifted 7 byte address field to an Address with the C/H bit
+// in Repeated, and returns whether the address extension bit marks it as the last address
+func decodeAddressField(field []byte) (Address, bool) {
+	var callsign []byte
+	for i := 0; i < 6; i++ {
+		callsign = append(callsign, field[i]>>1)
+	}
+	address := Address{
+		Callsign: strings.TrimRight(string(callsign), " "),
+		SSID:     SSID((field[6] & addressSSIDMask) >> 1),
+		Repeated: field[6]&addressCHBit != 0,
+	}
+	return address, field[6]&addressExtension != 0
+}
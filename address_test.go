@@ -0,0 +1,112 @@
+package aprsgo
+
+import (
+	"testing"
+)
+
+func TestParseAddress(t *testing.T) {
+	testCases := []struct {
+		In   string
+		Want Address
+		Err  string // the expected error type, empty if valid
+	}{
+		{In: "N0CALL-7", Want: Address{Callsign: "N0CALL", SSID: 7}},
+		{In: "W1AW", Want: Address{Callsign: "W1AW"}},
+		{In: "WIDE2-1*", Want: Address{Callsign: "WIDE2", SSID: 1, Repeated: true}},
+		{In: "W1AW-0", Want: Address{Callsign: "W1AW"}},
+		{In: "W1AW-16", Err: "*aprsgo.SSIDError"},
+		{In: "W1AW-A", Err: "*aprsgo.CallsignError"},
+		{In: "w1aw", Err: "*aprsgo.CallsignError"},
+		{In: "N0CALLX", Err: "*aprsgo.CallsignError"},
+		{In: "-1", Err: "*aprsgo.CallsignError"},
+	}
+
+	for _, testCase := range testCases {
+		got, err := ParseAddress(testCase.In)
+		if errorType(err) != testCase.Err {
+			t.Errorf("Parsing %s expected error %q got %v", testCase.In, testCase.Err, err)
+			continue
+		}
+		if err == nil && got != testCase.Want {
+			t.Errorf("Parsing %s expected %+v got %+v", testCase.In, testCase.Want, got)
+		}
+	}
+}
+
+func TestParseTacticalAddress(t *testing.T) {
+	testCases := []struct {
+		In       string
+		Tactical bool
+		Err      string
+	}{
+		{In: "W1AW-10", Tactical: false},
+		{In: "SHELTER-2", Tactical: true},
+		{In: "EOCNORTH", Tactical: true},
+		{In: "SHELTER-12", Err: "*aprsgo.CallsignError"},
+		{In: "Shelter", Err: "*aprsgo.CallsignError"},
+	}
+
+	for _, testCase := range testCases {
+		got, err := ParseTacticalAddress(testCase.In)
+		if errorType(err) != testCase.Err {
+			t.Errorf("Parsing %s expected error %q got %v", testCase.In, testCase.Err, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if got.IsTactical() != testCase.Tactical {
+			t.Errorf("Parsing %s expected tactical %v", testCase.In, testCase.Tactical)
+		}
+		if got.String() != testCase.In {
+			t.Errorf("Expected %s got %s", testCase.In, got.String())
+		}
+		if testCase.Tactical && got.Validate() == nil {
+			t.Errorf("Tactical callsign %s should not validate as an AX.25 address", testCase.In)
+		}
+	}
+}
+
+func TestAddressString(t *testing.T) {
+	for _, in := range []string{"N0CALL-7", "W1AW", "WIDE2-1*", "APZ001"} {
+		address, err := ParseAddress(in)
+		if err != nil {
+			t.Fatalf("Parsing %s failed with error %v", in, err)
+		}
+		if address.String() != in {
+			t.Errorf("Expected %s got %s", in, address.String())
+		}
+	}
+
+	a := Address{Callsign: "WIDE2", SSID: 1}
+	if !a.Equal(Address{Callsign: "WIDE2", SSID: 1, Repeated: true}) || a.Equal(Address{Callsign: "WIDE2", SSID: 2}) {
+		t.Errorf("Addresses should compare by callsign and SSID only")
+	}
+}
+
+func TestAddressField(t *testing.T) {
+	testCases := []struct {
+		In    Address
+		CHBit bool
+		Last  bool
+		Want  [7]byte
+	}{
+		{In: Address{Callsign: "APZ001"}, CHBit: true,
+			Want: [7]byte{'A' << 1, 'P' << 1, 'Z' << 1, '0' << 1, '0' << 1, '1' << 1, 0xe0}},
+		{In: Address{Callsign: "W1AW", SSID: 7}, Last: true,
+			Want: [7]byte{'W' << 1, '1' << 1, 'A' << 1, 'W' << 1, ' ' << 1, ' ' << 1, 0x6f}},
+		{In: Address{Callsign: "WIDE2", SSID: 1, Repeated: true}, CHBit: true,
+			Want: [7]byte{'W' << 1, 'I' << 1, 'D' << 1, 'E' << 1, '2' << 1, ' ' << 1, 0xe2}},
+	}
+
+	for _, testCase := range testCases {
+		got := testCase.In.field(testCase.CHBit, testCase.Last)
+		if got != testCase.Want {
+			t.Errorf("Address %s expected field % x got % x", testCase.In, testCase.Want, got)
+		}
+		decoded, last := decodeAddressField(got[:])
+		if !decoded.Equal(testCase.In) || decoded.Repeated != testCase.CHBit || last != testCase.Last {
+			t.Errorf("Field % x decoded to %s, last %v", got, decoded, last)
+		}
+	}
+}
@@ -4,6 +4,7 @@ package aprsgo
 // of the AFSK1200 data at various bit and sample rates
 
 import (
+	"bytes"
 	"encoding/binary"
 	"errors"
 	"math"
@@ -16,6 +17,7 @@ type WAVParams struct {
 	SamplesPerSecond uint32
 	BitsPerSample    uint8
 	NumChannels      uint8
+	Float            bool // IEEE float samples, BitsPerSample must be 32
 }
 
 type symbolWriter interface {
@@ -32,9 +34,20 @@ var (
 	riffTag = "RIFF" // RIFF tag header for entire file
 	waveTag = "WAVE" // WAVE tag header identifying type of RIFF
 	fmtTag  = "fmt " // fmt tag header for format chunk
+	factTag = "fact" // fact tag header for the sample count chunk of formats other than PCM
 	dataTag = "data" // data tag header for data chunk
 )
 
+// WAV format tags
+const (
+	wavFormatPCM        uint16 = 0x0001
+	wavFormatFloat      uint16 = 0x0003
+	wavFormatExtensible uint16 = 0xfffe // the format is the first two bytes of the sub format GUID
+)
+
+// wavSubFormatGUID is the GUID of a format in a WAVE_FORMAT_EXTENSIBLE header, after the format tag
+var wavSubFormatGUID = [14]byte{0x00, 0x00, 0x00, 0x00, 0x10, 0x00, 0x80, 0x00, 0x00, 0xaa, 0x00, 0x38, 0x9b, 0x71}
+
 var scalingFactor = 0.75 // the scaling factor for volume between 0.0 and 1.0
 
 // SetVolume sets the scaling factor for volume between 0.0 and 1.0
@@ -48,12 +61,37 @@ func SetVolume(newVolume float64) {
 	scalingFactor = newVolume
 }
 
+// ToneShaping is the response of the transmitted audio, set so the signal on air is flat after the radio
+type ToneShaping struct {
+	Twist    float64  // dB the 2200 Hz space tone is sent louder than the 1200 Hz mark tone, negative for quieter
+	Emphasis Emphasis // the shaping filter, DeEmphasis to cancel the pre-emphasis of a microphone input
+}
+
+var toneShaping ToneShaping // the shaping of the modulator, flat by default
+
+// SetToneShaping sets the twist and emphasis of the modulator, the louder tone is sent at the volume
+func SetToneShaping(shaping ToneShaping) {
+	toneShaping = shaping
+}
+
+// RadioProfiles are the tone shaping for the usual ways of feeding audio to a radio
+var RadioProfiles = map[string]ToneShaping{
+	"flat":         {},                      // a flat data port, sent as is
+	"mic":          {Emphasis: DeEmphasis},  // a microphone input that pre-emphasizes the audio
+	"deemphasized": {Emphasis: PreEmphasis}, // a data port that de-emphasizes the audio
+}
+
 // WriteWAV writes a symbol stream out to a WAV file with parameters given by params
 func (symbolStream SymbolStream) WriteWAV(params WAVParams) error {
 	wr, err := newWaveWriter(params.SamplesPerSecond, params.BitsPerSample, params.NumChannels)
 	if err != nil {
 		return err
 	}
+	if params.Float {
+		if err := wr.useFloat(); err != nil {
+			return err
+		}
+	}
 	for _, sym := range symbolStream { // encode the symbols
 		wr.writeSymbol(sym)
 	}
@@ -64,6 +102,23 @@ func (symbolStream SymbolStream) WriteWAV(params WAVParams) error {
 	return nil
 }
 
+// Modulate returns the AFSK1200 audio of the symbol stream as samples between -1.0 and 1.0,
+// scaled by the volume set with SetVolume and shaped as set with SetToneShaping, none below
+// one sample per symbol
+func (symbolStream SymbolStream) Modulate(samplesPerSecond uint32) []float64 {
+	w, err := newWaveWriter(samplesPerSecond, 16, 1) // only the timing, phase and shaping of the writer are used
+	if err != nil {
+		return nil
+	}
+	var samples []float64
+	for _, sym := range symbolStream {
+		for i := w.nextSymbolSamples(); i > 0; i-- {
+			samples = append(samples, scalingFactor*w.nextSample(sym))
+		}
+	}
+	return samples
+}
+
 var symbolFrequency = map[Symbol]float64{mark: markFreq, space: spaceFreq}
 
 type waveWriter struct {
@@ -71,116 +126,231 @@ type waveWriter struct {
 	bitsPerSample        uint8              // supported values are 8, 12, 32
 	numChannels          uint8              // 1 mono, 2 stereo
 	volumeLevel          float64            // the scaling factor for the samples to full scale
+	float                bool               // write IEEE float samples rather than integers
 	symbolCount          uint32             // the count of the current number of symbols in this second
-	samplesPerSymbol     uint32             // how many samples are written for each symbol
-	skewSamples          uint32             // the skew samples needed to prevent symbol rate drift
-	currentPhase         float64            // the current phase of the wave to maintain continuity
-	phaseIncrementSymbol map[Symbol]float64 // map of the phase increment per sample for each symbol
+	currentPhase         uint32             // the phase of the oscillator in 1/2^32 cycles, continuous across symbols
+	phaseIncrementSymbol map[Symbol]uint32  // map of the phase increment per sample for each symbol
+	toneGain             map[Symbol]float64 // the gain of each tone after shaping, the louder tone at full scale
+	shaping              *emphasisFilter    // the emphasis filter applied to the tones
 	data                 []byte             // the output data as an array of bytes
 }
 
-type waveHeader struct {
-	riffChunkID           [4]byte // "RIFF"
-	riffChunkSize         uint32  // 4 + (8 + formatChunkSize) + (8 + dataChunkSize) = 36 + dataChunkSize
-	waveChunkID           [4]byte // "WAVE"
-	formatChunkID         [4]byte // "fmt "
-	formatChunkSize       uint32  // 16 for PCM
-	waveFormatTag         uint16  // 0x0001 for PCM
-	numberOfChannels      uint16  // Nc
-	samplesPerSecond      uint32  // sampling frequency, e.g. 48000
-	averageBytesPerSecond uint32  // F*M*Nc
-	blockAlign            uint16  // M*Nc
-	bitsPerSample         uint16  // 8*M
-	dataChunkID           [4]byte // "data"
-	dataChunkSize         uint32  // M*Nc*Ns
+// waveFormat is the fmt chunk common to every format
+type waveFormat struct {
+	formatTag             uint16 // wavFormatPCM, wavFormatFloat or wavFormatExtensible
+	numberOfChannels      uint16 // Nc
+	samplesPerSecond      uint32 // sampling frequency, e.g. 48000
+	averageBytesPerSecond uint32 // F*M*Nc
+	blockAlign            uint16 // M*Nc
+	bitsPerSample         uint16 // 8*M
+}
+
+// waveFormatExtension follows waveFormat in a WAVE_FORMAT_EXTENSIBLE fmt chunk
+type waveFormatExtension struct {
+	extensionSize      uint16   // 22, the bytes that follow
+	validBitsPerSample uint16   // the bits used of each sample, all of them here
+	channelMask        uint32   // the speaker position of each channel
+	subFormat          [16]byte // the format tag followed by wavSubFormatGUID
 }
 
 func newWaveWriter(samplesPerSecond uint32, bitsPerSample uint8, numChannels uint8) (*waveWriter, error) {
-	if bitsPerSample%8 != 0 || bitsPerSample > 32 {
-		return nil, errors.New("only 8, 16, 24 and 32 bitsPerSample are supported")
+	format := AudioFormat{SamplesPerSecond: samplesPerSecond, BitsPerSample: bitsPerSample, NumChannels: numChannels}
+	if err := format.validate(); err != nil { // the same formats are read back
+		return nil, err
 	}
 	writer := new(waveWriter)
 	writer.samplesPerSecond = samplesPerSecond
 	writer.bitsPerSample = bitsPerSample
 	writer.numChannels = numChannels
-	writer.volumeLevel = scalingFactor * float64(uint64(1)<<(bitsPerSample-1)) // the level
-	writer.samplesPerSymbol = samplesPerSecond / symbolRate
-	writer.skewSamples = samplesPerSecond % symbolRate // the remainder will need to be skewed in to prevent drift
-	phaseIncrementSymbol := make(map[Symbol]float64)
-	phaseIncrementSymbol[mark] = 2 * math.Pi * markFreq / float64(samplesPerSecond)
-	phaseIncrementSymbol[space] = 2 * math.Pi * spaceFreq / float64(samplesPerSecond)
+	writer.setLevel(scalingFactor)
+	phaseIncrementSymbol := make(map[Symbol]uint32)
+	phaseIncrementSymbol[mark] = uint32(math.Round(markFreq / float64(samplesPerSecond) * (1 << 32)))
+	phaseIncrementSymbol[space] = uint32(math.Round(spaceFreq / float64(samplesPerSecond) * (1 << 32)))
 	writer.phaseIncrementSymbol = phaseIncrementSymbol
+	writer.shaping = newEmphasisFilter(toneShaping.Emphasis, samplesPerSecond)
+	twistGain := math.Pow(10, toneShaping.Twist/20)
+	peak := math.Max(writer.shaping.gain(markFreq), twistGain*writer.shaping.gain(spaceFreq))
+	writer.toneGain = map[Symbol]float64{mark: 1 / peak, space: twistGain / peak}
 	return writer, nil
 }
 
-func (w *waveWriter) writeSymbol(sym Symbol) {
-	phaseIncrement := w.phaseIncrementSymbol[sym]
-	for i := uint32(0); i < w.samplesPerSymbol; i++ {
-		w.writeSample(phaseIncrement)
+// useFloat switches the writer to 32-bit IEEE float samples between -1.0 and 1.0
+func (w *waveWriter) useFloat() error {
+	if w.bitsPerSample != 32 {
+		return errors.New("only 32 bitsPerSample are supported for float samples")
 	}
-	if w.symbolCount < w.skewSamples { // write an extra sample in for the first skew samples
-		w.writeSample(phaseIncrement)
+	w.float = true
+	w.setLevel(scalingFactor)
+	return nil
+}
+
+// setLevel sets the level of the samples written, between 0.0 and 1.0 of full scale
+func (w *waveWriter) setLevel(level float64) {
+	if w.float {
+		w.volumeLevel = level
+		return
 	}
+	w.volumeLevel = level * float64(uint64(1)<<(w.bitsPerSample-1))
+}
+
+func (w *waveWriter) writeSymbol(sym Symbol) {
+	for i := w.nextSymbolSamples(); i > 0; i-- {
+		w.writeSample(w.nextSample(sym))
+	}
+}
+
+// nextSymbolSamples returns the number of samples for the next symbol, ending it at the sample
+// nearest the ideal symbol boundary so the symbol lengths differ by at most one sample at any rate
+func (w *waveWriter) nextSymbolSamples() uint32 {
+	start := w.symbolBoundary(w.symbolCount)
 	w.symbolCount++
-	w.symbolCount %= symbolRate // reset the symbol count after every second
+	samples := w.symbolBoundary(w.symbolCount) - start
+	w.symbolCount %= symbolRate // reset the symbol count after every second, exactly samplesPerSecond samples
+	return samples
+}
+
+// symbolBoundary returns the sample nearest the start of the symbol, counted from the start of the second
+func (w *waveWriter) symbolBoundary(symbol uint32) uint32 {
+	return uint32((uint64(symbol)*uint64(w.samplesPerSecond) + uint64(symbolRate)/2) / uint64(symbolRate))
 }
 
-func (w *waveWriter) writeSample(phaseIncrement float64) {
+// advancePhase moves the oscillator on by one sample and returns the sine of its phase,
+// the phase wraps around at the end of each cycle
+func (w *waveWriter) advancePhase(phaseIncrement uint32) float64 {
 	w.currentPhase += phaseIncrement
-	if w.currentPhase > 2*math.Pi { // avoid overflowing the phase
-		w.currentPhase -= 2 * math.Pi
+	return sine(w.currentPhase)
+}
+
+const sineTableBits = 12 // the table holds 4096 samples of a cycle
+
+var sineTable = makeSineTable()
+
+// makeSineTable returns a cycle of the sine with the first sample repeated at the end for interpolation
+func makeSineTable() []float64 {
+	table := make([]float64, 1<<sineTableBits+1)
+	for i := range table {
+		table[i] = math.Sin(2 * math.Pi * float64(i) / (1 << sineTableBits))
+	}
+	return table
+}
+
+// sine returns the sine of the phase in 1/2^32 cycles, interpolating linearly in the table,
+// within 3e-7 of math.Sin
+func sine(phase uint32) float64 {
+	index := phase >> (32 - sineTableBits)
+	fraction := float64(phase<<sineTableBits) / (1 << 32)
+	return sineTable[index] + fraction*(sineTable[index+1]-sineTable[index])
+}
+
+// nextSample returns the next sample of the tone for the symbol after shaping, between -1.0 and 1.0
+func (w *waveWriter) nextSample(sym Symbol) float64 {
+	sample := w.shaping.filter(w.toneGain[sym] * w.advancePhase(w.phaseIncrementSymbol[sym]))
+	return math.Max(-1, math.Min(1, sample)) // the filter can overshoot at transitions
+}
+
+func (w *waveWriter) writeSample(sample float64) {
+	newSample := w.volumeLevel * sample
+	var u32Sample uint32 // bits per sample only supported multiples of 8 up to 32
+	switch {
+	case w.float:
+		u32Sample = math.Float32bits(float32(newSample))
+	case w.bitsPerSample == 8:
+		u32Sample = uint32(clampSample(newSample, 8) + (1 << 7)) // 8-bit is offset encoded
+	default:
+		u32Sample = uint32(clampSample(newSample, w.bitsPerSample)) // two's complement, the high bytes are dropped
 	}
-	newSample := w.volumeLevel * math.Sin(w.currentPhase)
 	for i := uint8(0); i < w.numChannels; i++ { // write one sample for each channel
-		u32Sample := uint32(newSample) // bits per sample only supported multiples of 8 up to 32
-		if w.bitsPerSample == 8 {
-			u32Sample += (1 << 7) // 8-bit is offset encoded
-			u32Sample %= (1 << 8)
-		}
-		for i := uint8(0); i < w.bitsPerSample/8; i++ {
-			w.data = append(w.data, byte(u32Sample&0xFF))
-			u32Sample = u32Sample >> 8
+		for b := uint8(0); b < w.bitsPerSample/8; b++ {
+			w.data = append(w.data, byte(u32Sample>>(8*b)))
 		}
 	}
 }
 
-func (w *waveWriter) writeFile(filename string) error {
-	data := w.data
-	if len(w.data)%2 != 0 {
-		data = append(data, byte(0)) // pad a zero byte if the length is not even
-	}
-	M := w.bitsPerSample / 8      // Bytes per sample
-	Nc := w.numChannels           // number of channels
-	dataSize := uint32(len(data)) // the total number of bytes, with padding
-	header := waveHeader{
-		riffChunkSize:         uint32(36 + dataSize),
-		formatChunkSize:       uint32(16),
-		waveFormatTag:         uint16(0x0001),
+// clampSample truncates the sample to a signed integer in the range of bitsPerSample bits
+func clampSample(sample float64, bitsPerSample uint8) int32 {
+	limit := float64(int64(1)<<(bitsPerSample-1) - 1)
+	if sample > limit {
+		return int32(limit)
+	}
+	if sample < -limit-1 {
+		return int32(-limit - 1)
+	}
+	return int32(sample)
+}
+
+// format returns the fmt chunk of the writer, WAVE_FORMAT_EXTENSIBLE for more than two channels or
+// integer samples of more than 16 bits, as older readers cannot tell how such samples are laid out
+func (w *waveWriter) format() []byte {
+	M := w.bitsPerSample / 8 // Bytes per sample
+	Nc := w.numChannels      // number of channels
+	format := waveFormat{
+		formatTag:             wavFormatPCM,
 		numberOfChannels:      uint16(Nc),
-		samplesPerSecond:      uint32(w.samplesPerSecond),
+		samplesPerSecond:      w.samplesPerSecond,
 		averageBytesPerSecond: w.samplesPerSecond * uint32(M) * uint32(Nc),
 		blockAlign:            uint16(M) * uint16(Nc),
 		bitsPerSample:         uint16(w.bitsPerSample),
-		dataChunkSize:         dataSize,
 	}
-	copy(header.riffChunkID[:], riffTag)
-	copy(header.waveChunkID[:], waveTag)
-	copy(header.formatChunkID[:], fmtTag)
-	copy(header.dataChunkID[:], dataTag)
+	if w.float {
+		format.formatTag = wavFormatFloat
+	}
+	subFormat := format.formatTag
 
-	file, err := os.Create(filename)
-	if err != nil {
-		return err
+	var chunk bytes.Buffer
+	if Nc > 2 || (!w.float && w.bitsPerSample > 16) {
+		format.formatTag = wavFormatExtensible
+		extension := waveFormatExtension{
+			extensionSize:      22,
+			validBitsPerSample: uint16(w.bitsPerSample),
+			channelMask:        channelMask(Nc),
+		}
+		binary.LittleEndian.PutUint16(extension.subFormat[:], subFormat)
+		copy(extension.subFormat[2:], wavSubFormatGUID[:])
+		binary.Write(&chunk, binary.LittleEndian, format)
+		binary.Write(&chunk, binary.LittleEndian, extension)
+	} else {
+		binary.Write(&chunk, binary.LittleEndian, format)
+		if format.formatTag != wavFormatPCM {
+			binary.Write(&chunk, binary.LittleEndian, uint16(0)) // no extension
+		}
 	}
-	defer file.Close()
-	err = binary.Write(file, binary.LittleEndian, header)
-	if err != nil {
-		return err
+	return chunk.Bytes()
+}
+
+// channelMask returns the speaker positions of the channels, front center for mono, otherwise the
+// channels in the standard order starting front left, front right
+func channelMask(numChannels uint8) uint32 {
+	if numChannels == 1 {
+		return 0x4
 	}
-	err = binary.Write(file, binary.LittleEndian, w.data)
-	if err != nil {
-		return err
+	if numChannels > 18 { // more channels than speaker positions
+		return 0
 	}
+	return uint32(1)<<numChannels - 1
+}
 
-	return nil
+// writeFile writes the RIFF WAVE file with the fmt chunk, a fact chunk for float samples and the data chunk
+func (w *waveWriter) writeFile(filename string) error {
+	var file bytes.Buffer
+	writeChunk := func(tag string, data []byte) {
+		file.WriteString(tag)
+		binary.Write(&file, binary.LittleEndian, uint32(len(data)))
+		file.Write(data)
+		if len(data)%2 != 0 {
+			file.WriteByte(0) // pad a zero byte if the length is not even
+		}
+	}
+	file.WriteString(waveTag)
+	writeChunk(fmtTag, w.format())
+	if w.float {
+		frames := make([]byte, 4) // the number of samples of each channel
+		binary.LittleEndian.PutUint32(frames, uint32(len(w.data)/int(w.bitsPerSample/8)/int(w.numChannels)))
+		writeChunk(factTag, frames)
+	}
+	writeChunk(dataTag, w.data)
+
+	riff := make([]byte, 8, 8+file.Len())
+	copy(riff, riffTag)
+	binary.LittleEndian.PutUint32(riff[4:], uint32(file.Len()))
+	return os.WriteFile(filename, append(riff, file.Bytes()...), 0666)
 }
@@ -1,8 +1,13 @@
 package aprsgo
 
 import (
+	"encoding/binary"
 	"fmt"
+	"math"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestNewWaveWriter(t *testing.T) {
@@ -10,21 +15,27 @@ func TestNewWaveWriter(t *testing.T) {
 	if err != nil {
 		t.Errorf("Errors creating 48kHz sampling at 8-bits per second: %v", err)
 	}
-	if wr.samplesPerSymbol != 40 {
-		t.Errorf("48kHz sampling should have 40 samples per symbol, has %v", wr.samplesPerSymbol)
-	}
-	if wr.skewSamples != 0 {
-		t.Errorf("48kHz sampling should have no skew samples, has %v", wr.skewSamples)
+	for i := 0; i < int(symbolRate); i++ {
+		if samples := wr.nextSymbolSamples(); samples != 40 {
+			t.Fatalf("48kHz sampling should have 40 samples in every symbol, symbol %d has %v", i, samples)
+		}
 	}
 	if wr.volumeLevel != 96 {
 		t.Errorf("8-bit volume level with scaling 0.75 should be XXX, got %v", int8(wr.volumeLevel))
 	}
 	wr, err = newWaveWriter(44100, 16, 1)
-	if wr.samplesPerSymbol != 36 {
-		t.Errorf("44.1kHz sampling should have 36 samples per symbol, has %v", wr.samplesPerSymbol)
+	long, total := 0, uint32(0)
+	for i := 0; i < int(symbolRate); i++ {
+		samples := wr.nextSymbolSamples()
+		if samples == 37 {
+			long++
+		} else if samples != 36 {
+			t.Fatalf("44.1kHz sampling should have 36 or 37 samples in a symbol, symbol %d has %v", i, samples)
+		}
+		total += samples
 	}
-	if wr.skewSamples != 900 {
-		t.Errorf("44.1kHz sampling should have 900 skew samples, has %v", wr.skewSamples)
+	if long != 900 || total != 44100 {
+		t.Errorf("44.1kHz sampling should have 900 symbols of 37 samples in a second of 44100, has %v in %v", long, total)
 	}
 	if wr.volumeLevel != 24576 {
 		t.Errorf("16-bit volume level with scaling 0.75 should be 24576, got %v", int16(wr.volumeLevel))
@@ -108,3 +119,333 @@ func TestWriteFile(t *testing.T) {
 		t.Errorf("Error saving file: %v", err)
 	}
 }
+
+func TestToneShaping(t *testing.T) {
+	defer SetToneShaping(ToneShaping{})
+	const samplesPerSecond = 48000
+	marks, spaces := make(SymbolStream, 1200), make(SymbolStream, 1200)
+	for i := range spaces {
+		marks[i], spaces[i] = mark, space
+	}
+	tests := []struct {
+		shaping ToneShaping
+		channel ChannelSimulator // the radio the shaping is for
+		twist   float64          // the expected space to mark amplitude ratio in dB on air
+	}{
+		{ToneShaping{}, ChannelSimulator{}, 0},
+		{ToneShaping{Twist: 6}, ChannelSimulator{}, 6},
+		{ToneShaping{Twist: -4}, ChannelSimulator{}, -4},
+		{ToneShaping{Emphasis: DeEmphasis}, ChannelSimulator{}, -1.9},
+		{RadioProfiles["mic"], ChannelSimulator{Emphasis: PreEmphasis}, 0},
+		{RadioProfiles["deemphasized"], ChannelSimulator{Emphasis: DeEmphasis}, 0},
+		{ToneShaping{Twist: 3}, ChannelSimulator{Twist: -3}, 0},
+	}
+	for _, test := range tests {
+		SetToneShaping(test.shaping)
+		markAmplitude := toneAmplitude(test.channel.Apply(marks.Modulate(samplesPerSecond), samplesPerSecond), markFreq, samplesPerSecond)
+		spaceAmplitude := toneAmplitude(test.channel.Apply(spaces.Modulate(samplesPerSecond), samplesPerSecond), spaceFreq, samplesPerSecond)
+		if twist := 20 * math.Log10(spaceAmplitude/markAmplitude); math.Abs(twist-test.twist) > 0.2 {
+			t.Errorf("%+v: expected twist %.1f dB on air, got %.2f dB", test.shaping, test.twist, twist)
+		}
+		// before the radio the louder tone is at the volume
+		markAmplitude = toneAmplitude(marks.Modulate(samplesPerSecond), markFreq, samplesPerSecond)
+		spaceAmplitude = toneAmplitude(spaces.Modulate(samplesPerSecond), spaceFreq, samplesPerSecond)
+		if louder := math.Max(markAmplitude, spaceAmplitude); math.Abs(louder-scalingFactor) > 0.01 {
+			t.Errorf("%+v: expected the louder tone at %.2f, got %.3f", test.shaping, scalingFactor, louder)
+		}
+	}
+}
+
+func TestToneShapingDecodes(t *testing.T) {
+	defer SetToneShaping(ToneShaping{})
+	ax25data := testAX25Data(t, "Test")
+	for name, shaping := range RadioProfiles {
+		SetToneShaping(shaping)
+		received := Receiver{}.Receive(ax25data.Encode().Modulate(48000), 48000)
+		if len(received) != 1 || string(received[0].AX25Data) != string(ax25data) {
+			t.Errorf("%s: expected the shaped frame received, got %d", name, len(received))
+		}
+	}
+}
+
+// testWAV is a WAV file parsed back by the tests
+type testWAV struct {
+	format    waveFormat
+	extension *waveFormatExtension
+	frames    uint32 // from the fact chunk
+	data      []byte
+}
+
+func parseTestWAV(t *testing.T, filename string) testWAV {
+	file, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(file[0:4]) != riffTag || string(file[8:12]) != waveTag {
+		t.Fatalf("%s: not a RIFF WAVE file", filename)
+	}
+	if size := binary.LittleEndian.Uint32(file[4:8]); int(size) != len(file)-8 {
+		t.Errorf("%s: RIFF size %d, file has %d bytes after it", filename, size, len(file)-8)
+	}
+	var wav testWAV
+	for chunks := file[12:]; len(chunks) > 0; {
+		tag, size := string(chunks[0:4]), int(binary.LittleEndian.Uint32(chunks[4:8]))
+		body := chunks[8 : 8+size]
+		switch tag {
+		case fmtTag:
+			wav.format = waveFormat{
+				formatTag:             binary.LittleEndian.Uint16(body[0:]),
+				numberOfChannels:      binary.LittleEndian.Uint16(body[2:]),
+				samplesPerSecond:      binary.LittleEndian.Uint32(body[4:]),
+				averageBytesPerSecond: binary.LittleEndian.Uint32(body[8:]),
+				blockAlign:            binary.LittleEndian.Uint16(body[12:]),
+				bitsPerSample:         binary.LittleEndian.Uint16(body[14:]),
+			}
+			switch wav.format.formatTag {
+			case wavFormatPCM:
+				if size != 16 {
+					t.Errorf("%s: PCM fmt chunk of %d bytes", filename, size)
+				}
+			case wavFormatFloat:
+				if size != 18 || binary.LittleEndian.Uint16(body[16:]) != 0 {
+					t.Errorf("%s: float fmt chunk of %d bytes", filename, size)
+				}
+			case wavFormatExtensible:
+				wav.extension = &waveFormatExtension{
+					extensionSize:      binary.LittleEndian.Uint16(body[16:]),
+					validBitsPerSample: binary.LittleEndian.Uint16(body[18:]),
+					channelMask:        binary.LittleEndian.Uint32(body[20:]),
+				}
+				copy(wav.extension.subFormat[:], body[24:40])
+				if size != 40 || wav.extension.extensionSize != 22 {
+					t.Errorf("%s: extensible fmt chunk of %d bytes", filename, size)
+				}
+			}
+		case factTag:
+			wav.frames = binary.LittleEndian.Uint32(body)
+		case dataTag:
+			wav.data = body
+		}
+		chunks = chunks[8+size+size%2:]
+	}
+	return wav
+}
+
+func TestWriteWAVFormats(t *testing.T) {
+	symbolStream := testAX25Data(t, "Test").Encode()
+	tests := []struct {
+		bitsPerSample uint8
+		numChannels   uint8
+		float         bool
+		formatTag     uint16
+		subFormat     uint16 // for extensible formats
+		channelMask   uint32
+	}{
+		{8, 1, false, wavFormatPCM, 0, 0},
+		{16, 1, false, wavFormatPCM, 0, 0},
+		{16, 2, false, wavFormatPCM, 0, 0},
+		{24, 1, false, wavFormatExtensible, wavFormatPCM, 0x4},
+		{32, 2, false, wavFormatExtensible, wavFormatPCM, 0x3},
+		{16, 4, false, wavFormatExtensible, wavFormatPCM, 0xf},
+		{8, 3, false, wavFormatExtensible, wavFormatPCM, 0x7},
+		{32, 1, true, wavFormatFloat, 0, 0},
+		{32, 6, true, wavFormatExtensible, wavFormatFloat, 0x3f},
+	}
+	for _, test := range tests {
+		const samplesPerSecond = 44100
+		name := fmt.Sprintf("%d bits %d channels float %t", test.bitsPerSample, test.numChannels, test.float)
+		params := WAVParams{
+			Filename:         filepath.Join(t.TempDir(), "test.wav"),
+			SamplesPerSecond: samplesPerSecond,
+			BitsPerSample:    test.bitsPerSample,
+			NumChannels:      test.numChannels,
+			Float:            test.float,
+		}
+		if err := symbolStream.WriteWAV(params); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		wav := parseTestWAV(t, params.Filename)
+
+		bytesPerSample := int(test.bitsPerSample / 8)
+		expectedFormat := waveFormat{
+			formatTag:             test.formatTag,
+			numberOfChannels:      uint16(test.numChannels),
+			samplesPerSecond:      samplesPerSecond,
+			averageBytesPerSecond: samplesPerSecond * uint32(bytesPerSample) * uint32(test.numChannels),
+			blockAlign:            uint16(bytesPerSample) * uint16(test.numChannels),
+			bitsPerSample:         uint16(test.bitsPerSample),
+		}
+		if wav.format != expectedFormat {
+			t.Errorf("%s: expected format %+v, got %+v", name, expectedFormat, wav.format)
+		}
+		if test.formatTag == wavFormatExtensible {
+			if wav.extension == nil {
+				t.Fatalf("%s: missing the format extension", name)
+			}
+			if wav.extension.validBitsPerSample != uint16(test.bitsPerSample) || wav.extension.channelMask != test.channelMask {
+				t.Errorf("%s: expected %d valid bits and channel mask %#x, got %+v", name, test.bitsPerSample, test.channelMask, *wav.extension)
+			}
+			if subFormat := binary.LittleEndian.Uint16(wav.extension.subFormat[:]); subFormat != test.subFormat ||
+				string(wav.extension.subFormat[2:]) != string(wavSubFormatGUID[:]) {
+				t.Errorf("%s: expected sub format %#x, got % x", name, test.subFormat, wav.extension.subFormat)
+			}
+		}
+
+		expected := symbolStream.Modulate(samplesPerSecond)
+		if len(wav.data) != len(expected)*bytesPerSample*int(test.numChannels) {
+			t.Fatalf("%s: expected %d samples, got %d bytes", name, len(expected), len(wav.data))
+		}
+		if test.float && wav.frames != uint32(len(expected)) {
+			t.Errorf("%s: expected a fact chunk of %d frames, got %d", name, len(expected), wav.frames)
+		}
+		scale := float64(int64(1) << (test.bitsPerSample - 1))
+		for n, sample := range expected {
+			for c := 0; c < int(test.numChannels); c++ {
+				offset := (n*int(test.numChannels) + c) * bytesPerSample
+				var raw uint32
+				for b := 0; b < bytesPerSample; b++ {
+					raw |= uint32(wav.data[offset+b]) << uint(8*b)
+				}
+				var got, want float64
+				switch {
+				case test.float:
+					got, want = float64(math.Float32frombits(raw)), float64(float32(sample))
+				case test.bitsPerSample == 8:
+					got, want = float64(int32(raw)-128), float64(int32(sample*scale))
+				default: // sign extend
+					shift := uint(32 - test.bitsPerSample)
+					got, want = float64(int32(raw<<shift)>>shift), float64(int32(sample*scale))
+				}
+				if got != want {
+					t.Fatalf("%s: sample %d channel %d expected %v, got %v", name, n, c, want, got)
+				}
+			}
+		}
+	}
+}
+
+func TestWriteWAVFloatBits(t *testing.T) {
+	params := WAVParams{Filename: filepath.Join(t.TempDir(), "test.wav"), SamplesPerSecond: 48000, BitsPerSample: 16, NumChannels: 1, Float: true}
+	if err := (SymbolStream{mark}).WriteWAV(params); err == nil {
+		t.Errorf("expected an error for 16-bit float samples")
+	}
+}
+
+func TestWriteWAVInvalidFormat(t *testing.T) {
+	var session Session
+	session.Add(DefaultTiming, DefaultTiming, testAX25Data(t, "Test"))
+	calibration := Calibration{Pattern: CalibrateMark, Duration: time.Second}
+	for _, params := range []WAVParams{
+		{SamplesPerSecond: 0, BitsPerSample: 16, NumChannels: 1},
+		{SamplesPerSecond: 1000, BitsPerSample: 16, NumChannels: 1},
+		{SamplesPerSecond: 48000, BitsPerSample: 0, NumChannels: 1},
+		{SamplesPerSecond: 48000, BitsPerSample: 16, NumChannels: 0},
+	} {
+		params.Filename = filepath.Join(t.TempDir(), "test.wav")
+		for name, writer := range map[string]func(WAVParams) error{
+			"symbols": (SymbolStream{mark}).WriteWAV, "session": session.WriteWAV, "calibration": calibration.WriteWAV,
+		} {
+			if err := writer(params); err == nil {
+				t.Errorf("%s %+v: expected an error", name, params)
+			}
+		}
+		if _, err := os.Stat(params.Filename); err == nil {
+			t.Errorf("%+v: expected no file written", params)
+		}
+	}
+
+	// modulating below one sample per symbol returns no samples rather than a writer error
+	for _, samplesPerSecond := range []uint32{0, 1000} {
+		if samples := (SymbolStream{mark, space}).Modulate(samplesPerSecond); samples != nil {
+			t.Errorf("symbols at %d Hz: expected no samples, got %d", samplesPerSecond, len(samples))
+		}
+		if samples := session.Modulate(samplesPerSecond); samples != nil {
+			t.Errorf("session at %d Hz: expected no samples, got %d", samplesPerSecond, len(samples))
+		}
+		if samples := calibration.Modulate(samplesPerSecond); samples != nil {
+			t.Errorf("calibration at %d Hz: expected no samples, got %d", samplesPerSecond, len(samples))
+		}
+	}
+}
+
+func TestClampSample(t *testing.T) {
+	tests := []struct {
+		sample        float64
+		bitsPerSample uint8
+		clamped       int32
+	}{
+		{-1.5, 16, -1},
+		{200, 8, 127},
+		{-200, 8, -128},
+		{-8388608.7, 24, -8388608},
+		{2147483648, 32, 2147483647},
+		{-2147483649, 32, -2147483648},
+	}
+	for _, test := range tests {
+		if clamped := clampSample(test.sample, test.bitsPerSample); clamped != test.clamped {
+			t.Errorf("%v at %d bits: expected %d, got %d", test.sample, test.bitsPerSample, test.clamped, clamped)
+		}
+	}
+}
+
+func TestSymbolTimingJitter(t *testing.T) {
+	for _, samplesPerSecond := range []uint32{8000, 9600, 11025, 16000, 22050, 44100, 48000, 96000} {
+		wr, err := newWaveWriter(samplesPerSecond, 16, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		elapsed, jitter := uint64(0), 0.0
+		for symbol := 1; symbol <= 3*int(symbolRate); symbol++ {
+			elapsed += uint64(wr.nextSymbolSamples())
+			ideal := float64(symbol) * float64(samplesPerSecond) / float64(symbolRate)
+			jitter = math.Max(jitter, math.Abs(float64(elapsed)-ideal))
+		}
+		if jitter > 0.5 {
+			t.Errorf("%d Hz: expected every symbol boundary within half a sample of ideal, off by %.2f", samplesPerSecond, jitter)
+		}
+		if elapsed != 3*uint64(samplesPerSecond) {
+			t.Errorf("%d Hz: expected %d samples in 3 seconds, got %d", samplesPerSecond, 3*samplesPerSecond, elapsed)
+		}
+	}
+}
+
+func TestSine(t *testing.T) {
+	worst := 0.0
+	for i := uint64(0); i < 1<<32; i += 1<<20 + 12345 {
+		worst = math.Max(worst, math.Abs(sine(uint32(i))-math.Sin(2*math.Pi*float64(i)/(1<<32))))
+	}
+	if worst > 3e-7 {
+		t.Errorf("expected the table sine within 3e-7, off by %g", worst)
+	}
+}
+
+func TestModulateFrequency(t *testing.T) {
+	for _, samplesPerSecond := range []uint32{11025, 22050, 44100} {
+		marks, spaces := make(SymbolStream, 1200), make(SymbolStream, 1200)
+		for i := range spaces {
+			marks[i], spaces[i] = mark, space
+		}
+		for _, test := range []struct {
+			symbols   SymbolStream
+			frequency float64
+		}{{marks, markFreq}, {spaces, spaceFreq}} {
+			amplitude := toneAmplitude(test.symbols.Modulate(samplesPerSecond), test.frequency, samplesPerSecond)
+			if math.Abs(amplitude-scalingFactor) > 1e-3 {
+				t.Errorf("%d Hz: expected the %.0f Hz tone at %.2f, got %.4f", samplesPerSecond, test.frequency, scalingFactor, amplitude)
+			}
+		}
+	}
+}
+
+func TestReceiveFractionalRates(t *testing.T) {
+	ax25data := testAX25Data(t, "}}} stuffing }}}")
+	symbolStream := append(ax25data.Encode(), ax25data.Encode()...)
+	for _, samplesPerSecond := range []uint32{11025, 22050, 44100} {
+		simulator := ChannelSimulator{Noise: true, SNR: 6, Seed: 1}
+		samples := simulator.Apply(symbolStream.Modulate(samplesPerSecond), samplesPerSecond)
+		received := Receiver{Demodulators: []Demodulator{{Name: "single", FilterWidth: 1, SpaceGain: 1}}}.Receive(samples, samplesPerSecond)
+		if len(received) != 2 {
+			t.Errorf("%d Hz: expected both frames received through noise, got %d", samplesPerSecond, len(received))
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+// aprs_rx decodes the packets in a WAV file, or in raw PCM audio read from standard input,
+// and prints them one per line
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/bmkessler/aprsgo"
+)
+
+const blockFrames = 4096 // frames of raw PCM read at a time, about a tenth of a second at 48000 samples per second
+
+func main() {
+	raw := flag.Bool("raw", false, "Read raw PCM from standard input rather than a WAV file")
+	sampleRate := flag.Uint("sr", 48000, "Sample rate of raw PCM in samples per second")
+	bitRate := flag.Uint("br", 16, "Bits per sample of raw PCM, 8, 16, 24 or 32, or 32 or 64 with -float")
+	numChannels := flag.Uint("nc", 1, "Number of interleaved channels of raw PCM")
+	unsigned := flag.Bool("unsigned", false, "Raw PCM samples are unsigned rather than signed")
+	float := flag.Bool("float", false, "Raw PCM samples are IEEE float")
+	channel := flag.Int("channel", 0, "Channel to decode, counting from 0")
+	fixBits := flag.Int("fix", aprsgo.FixNone, "Repair frames that fail their check, 0=none, 1=any one bit, 2=one bit or two adjacent bits (one wrong symbol), two bits apart are not repaired")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] file.wav | -raw < audio.raw\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	receiver := aprsgo.Receiver{FixBits: *fixBits}
+	switch {
+	case *raw:
+		format := aprsgo.AudioFormat{
+			SamplesPerSecond: uint32(*sampleRate),
+			BitsPerSample:    uint8(*bitRate),
+			NumChannels:      uint8(*numChannels),
+			Float:            *float,
+			Unsigned:         *unsigned,
+		}
+		reader, err := aprsgo.NewPCMReader(os.Stdin, format)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if *channel < 0 || *channel >= int(format.NumChannels) {
+			log.Fatalf("-channel: channel %d requested of audio with %d channels", *channel, format.NumChannels)
+		}
+		// the audio is received a block at a time, packets are printed as they are decoded
+		stream := aprsgo.NewReceiverStream(receiver, format.SamplesPerSecond)
+		for {
+			audio, err := reader.Next(blockFrames)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				log.Fatal(err)
+			}
+			samples, _ := audio.Channel(*channel)
+			printPackets(stream.Process(samples), format.SamplesPerSecond)
+		}
+		printPackets(stream.Flush(), format.SamplesPerSecond)
+	case flag.NArg() == 1:
+		audio, err := aprsgo.ReadWAVFile(flag.Arg(0))
+		if err != nil {
+			log.Fatal(err)
+		}
+		samples, err := audio.Channel(*channel)
+		if err != nil {
+			log.Fatalf("-channel: %v", err)
+		}
+		printPackets(receiver.Receive(samples, audio.SamplesPerSecond), audio.SamplesPerSecond)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
+
+// printPackets prints received packets one per line with the time they ended
+func printPackets(packets []aprsgo.ReceivedPacket, samplesPerSecond uint32) {
+	for _, received := range packets {
+		seconds := float64(received.Sample) / float64(samplesPerSecond)
+		packet, err := received.AX25Data.Decode()
+		if err != nil {
+			fmt.Printf("%8.3fs undecodable packet: %v\n", seconds, err)
+			continue
+		}
+		fmt.Printf("%8.3fs %s\n", seconds, packet)
+	}
+}
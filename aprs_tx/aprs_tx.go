@@ -4,64 +4,273 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/bmkessler/aprsgo"
 )
 
+// modes of operation, given as the first argument before any flags
+const (
+	modePosition  = "position" // position report, the default
+	modeBulletin  = "bulletin" // bulletin, announcement or NWS bulletin
+	modeCalibrate = "cal"      // steady tones for setting the deviation of a radio
+)
+
+// calibration patterns by the name given to -pattern
+var calibrationPatterns = map[string]aprsgo.CalibrationPattern{
+	"mark":  aprsgo.CalibrateMark,
+	"space": aprsgo.CalibrateSpace,
+	"alt":   aprsgo.CalibrateAlternating,
+	"flags": aprsgo.CalibrateFlags,
+}
+
 func main() {
+	mode, args := modePosition, os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		mode, args = args[0], args[1:]
+	}
+
 	// position report parameters
 	callsign := flag.String("call", "W1AW", "Callsign to send from")
 	comment := flag.String("comment", "Test", "Comment to append to position report")
 	lat := flag.Float64("lat", 41.7147, "Latitude for position report")
 	long := flag.Float64("long", -72.7272, "Longitude for position report")
-	format := flag.String("format", "b", "Format for position report, 'b'=basic, 'c'=compressed")
+	grid := flag.String("grid", "", "Maidenhead locator for position report, overrides lat and long")
+	ambiguity := flag.Int("ambiguity", 0, "Number of trailing position digits to blank for privacy, 0 to 4")
+	dao := flag.String("dao", "", "Extra precision !DAO! extension, 'h'=human readable, 'b'=base-91")
+	format := flag.String("format", "b", "Format for position report, 'b'=basic, 'c'=compressed, 'n'=raw NMEA, 'g'=grid square")
+	// bulletin parameters
+	bln := flag.String("bln", "0", "Bulletin to send, '0'-'9' bulletin line, 'A'-'Z' announcement, e.g. '4WX' group bulletin or 'NWS-WARN'")
+	text := flag.String("text", "Test bulletin", "Text of the bulletin")
+	fx25 := flag.Int("fx25", 0, "FX.25 forward error correction check bytes, 16, 32 or 64, 0 for plain AX.25")
+	il2p := flag.Int("il2p", 0, "Send as IL2P instead of AX.25, 1=normal FEC, 2=max FEC, 0 for plain AX.25")
+	il2pCRC := flag.Bool("il2pcrc", false, "Append the IL2P trailing CRC, the receiver must expect it")
+	// session parameters, for several packets in one file
+	frames := flag.Int("frames", 1, "Number of copies of the packet sent in each transmission")
+	transmissions := flag.Int("transmissions", 1, "Number of transmissions in the file")
+	gap := flag.Duration("gap", time.Second, "Silence between transmissions")
+	leadIn := flag.Duration("lead", 0, "Silence before the first transmission")
+	trail := flag.Duration("trail", 0, "Silence after the last transmission")
+	txDelay := flag.Duration("txdelay", aprsgo.DefaultChannelAccess.TXDelay, "Flags sent before the frames of each transmission")
+	txTail := flag.Duration("txtail", aprsgo.DefaultChannelAccess.TXTail, "Flags sent after the frames of each transmission")
+	// calibration parameters
+	pattern := flag.String("pattern", "alt", "Calibration pattern, 'mark', 'space', 'alt' for alternating tones or 'flags'")
+	duration := flag.Duration("duration", 10*time.Second, "Length of the calibration pattern")
+	level := flag.Float64("level", 0, "Calibration level between 0.0 and 1.0 of full scale, 0 for the default volume")
 	// WAV file parameters
 	sampleRate := flag.Uint("sr", 48000, "Sample rate in samples per second")
 	bitRate := flag.Uint("br", 16, "Bit rate in bits per sample, 8, 16, 24, and 32 supported")
 	numChannels := flag.Uint("nc", 1, "Number of audio channels to record")
+	float := flag.Bool("float", false, "Write 32-bit IEEE float samples, requires -br 32")
+	radio := flag.String("radio", "flat", "Radio audio input the tones are shaped for, 'flat', 'mic' or 'deemphasized'")
+	twist := flag.Float64("twist", 0, "Extra dB the 2200 Hz tone is sent louder than the 1200 Hz tone, negative for quieter")
+	emphasis := flag.String("emphasis", "", "Shaping filter overriding the radio, 'none', 'pre' or 'de'")
+
+	flag.CommandLine.Parse(args)
+	timed := false // a packet sent with its own TXDELAY or TXTAIL is sent as a session
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "txdelay" || f.Name == "txtail" {
+			timed = true
+		}
+	})
+
+	shaping, ok := aprsgo.RadioProfiles[*radio]
+	if !ok {
+		log.Fatalf("-radio: unknown radio %q", *radio)
+	}
+	shaping.Twist += *twist
+	switch *emphasis {
+	case "":
+	case "none":
+		shaping.Emphasis = aprsgo.FlatAudio
+	case "pre":
+		shaping.Emphasis = aprsgo.PreEmphasis
+	case "de":
+		shaping.Emphasis = aprsgo.DeEmphasis
+	default:
+		log.Fatalf("-emphasis: unknown emphasis %q", *emphasis)
+	}
+	aprsgo.SetToneShaping(shaping)
 
-	flag.Parse()
+	params := aprsgo.WAVParams{
+		SamplesPerSecond: uint32(*sampleRate),
+		BitsPerSample:    uint8(*bitRate),
+		NumChannels:      uint8(*numChannels),
+		Float:            *float,
+	}
+
+	if mode == modeCalibrate {
+		calibration := aprsgo.Calibration{Duration: *duration, Level: *level}
+		if calibration.Pattern, ok = calibrationPatterns[*pattern]; !ok {
+			log.Fatalf("-pattern: unknown pattern %q", *pattern)
+		}
+		if *duration <= 0 {
+			log.Fatal("-duration must be positive")
+		}
+		if *level < 0 || *level > 1 {
+			log.Fatal("-level must be between 0.0 and 1.0")
+		}
+		params.Filename = fmt.Sprintf("cal_%s_%s_%dHz_%dbits_%dchan.wav",
+			*pattern,
+			*duration,
+			*sampleRate,
+			*bitRate,
+			*numChannels)
+		if err := calibration.WriteWAV(params); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if *grid != "" {
+		var err error
+		if *lat, *long, err = aprsgo.LocatorToLatLong(*grid); err != nil {
+			log.Fatal(err)
+		}
+	}
 
 	report := aprsgo.PositionData{
 		Callsign:  *callsign,
 		Latitude:  *lat,
 		Longitude: *long,
 		Comment:   *comment,
+		Ambiguity: *ambiguity,
+	}
+	switch *dao {
+	case "":
+	case "h":
+		report.DAO = aprsgo.DAOHuman
+	case "b":
+		report.DAO = aprsgo.DAOBase91
+	default:
+		log.Fatalf("-dao: unknown format %q", *dao)
 	}
 
 	var ax25data aprsgo.AX25Data
-	switch *format {
-	case "c": // compressed
-		ax25data = report.CompressedAPRSReport()
-	default: // "b" and anything else not recognized
-		ax25data = report.BasicAPRSReport()
+	var err error
+	var description, label string // describe the packet in the file name
+	switch mode {
+	case modePosition:
+		switch *format {
+		case "c": // compressed
+			ax25data, err = report.CompressedAPRSReport()
+		case "n": // raw NMEA
+			ax25data, err = report.NMEAAPRSReport()
+		case "g": // grid square
+			ax25data, err = report.GridSquareAPRSReport()
+		default: // "b" and anything else not recognized
+			ax25data, err = report.BasicAPRSReport()
+		}
+		description, label = fmt.Sprintf("%.2f_%.2f", *lat, *long), *comment
+	case modeBulletin:
+		var bulletin aprsgo.BulletinData
+		if bulletin, err = newBulletin(*callsign, *bln, *text); err != nil {
+			log.Fatal(err)
+		}
+		ax25data, err = bulletin.BulletinAPRSReport()
+		description, label = bulletin.Addressee, *text
+	default:
+		log.Fatalf("unknown mode %q, supported modes are %s, %s and %s", mode, modePosition, modeBulletin, modeCalibrate)
+	}
+	if err != nil {
+		log.Fatal(flagError(err))
 	}
 
 	symbolStream := ax25data.Encode()
+	switch {
+	case *fx25 != 0 && *il2p != 0:
+		log.Fatal("-fx25 and -il2p cannot be used together")
+	case *fx25 != 0:
+		if symbolStream, err = ax25data.EncodeFX25(*fx25); err != nil {
+			log.Fatalf("-fx25: %v", err)
+		}
+	case *il2p != 0:
+		options := aprsgo.IL2POptions{MaxFEC: *il2p == 2, TrailingCRC: *il2pCRC}
+		if symbolStream, err = ax25data.EncodeIL2P(options); err != nil {
+			log.Fatalf("-il2p: %v", err)
+		}
+	}
 
-	wavFilename := fmt.Sprintf("%s_%.2f_%.2f_%dHz_%dbits_%dchan_%s.wav",
+	params.Filename = fmt.Sprintf("%s_%s_%dHz_%dbits_%dchan_%s.wav",
 		*callsign,
-		*lat,
-		*long,
+		description,
 		*sampleRate,
 		*bitRate,
 		*numChannels,
-		*comment)
+		label)
 
-	params := aprsgo.WAVParams{
-		Filename:         wavFilename,
-		SamplesPerSecond: uint32(*sampleRate),
-		BitsPerSample:    uint8(*bitRate),
-		NumChannels:      uint8(*numChannels),
+	if *frames == 1 && *transmissions == 1 && *leadIn == 0 && *trail == 0 && !timed {
+		if err := symbolStream.WriteWAV(params); err != nil {
+			log.Fatal(err)
+		}
+		return
 	}
-
-	if err := symbolStream.WriteWAV(params); err != nil {
+	if *fx25 != 0 || *il2p != 0 {
+		log.Fatal("-fx25 and -il2p send a single packet, they cannot be used with a session, -txdelay or -txtail")
+	}
+	if *frames < 1 || *transmissions < 1 {
+		log.Fatal("-frames and -transmissions must be at least 1")
+	}
+	if *txDelay < 0 || *txTail < 0 {
+		log.Fatal("-txdelay and -txtail cannot be negative")
+	}
+	session := aprsgo.Session{LeadIn: *leadIn, Gap: *gap, Trail: *trail}
+	for i := 0; i < *transmissions; i++ {
+		copies := make([]aprsgo.AX25Data, *frames)
+		for j := range copies {
+			copies[j] = ax25data
+		}
+		session.Add(*txDelay, *txTail, copies...)
+	}
+	if err := session.WriteWAV(params); err != nil {
 		log.Fatal(err)
 	}
 }
 
+// flagError names the flag to correct for a report that failed validation
+func flagError(err error) error {
+	var name string
+	switch e := err.(type) {
+	case *aprsgo.CallsignError, *aprsgo.SSIDError:
+		name = "call"
+	case *aprsgo.LatitudeError:
+		name = "lat"
+	case *aprsgo.LongitudeError:
+		name = "long"
+	case *aprsgo.AmbiguityError:
+		name = "ambiguity"
+	case *aprsgo.TextError:
+		name = "comment"
+		if e.Field != "comment" {
+			name = "text"
+		}
+	case *aprsgo.AddresseeError:
+		name = "bln"
+	default:
+		return err
+	}
+	return fmt.Errorf("-%s: %v", name, err)
+}
+
+// newBulletin creates the bulletin described by bln, e.g. 0, A, 4WX or NWS-WARN
+func newBulletin(callsign string, bln string, text string) (aprsgo.BulletinData, error) {
+	switch {
+	case strings.HasPrefix(bln, "NWS-"):
+		return aprsgo.NewNWSBulletin(callsign, 0, strings.TrimPrefix(bln, "NWS-"), text)
+	case len(bln) == 1 && bln[0] >= 'A' && bln[0] <= 'Z':
+		return aprsgo.NewAnnouncement(callsign, 0, bln[0], text)
+	case len(bln) == 1 && bln[0] >= '0' && bln[0] <= '9':
+		return aprsgo.NewBulletin(callsign, 0, int(bln[0]-'0'), text)
+	case len(bln) > 1 && bln[0] >= '0' && bln[0] <= '9':
+		return aprsgo.NewGroupBulletin(callsign, 0, int(bln[0]-'0'), bln[1:], text)
+	}
+	return aprsgo.BulletinData{}, fmt.Errorf("unknown bulletin %q", bln)
+}
+
 // To test the output file with multimon-ng the WAV file can be piped with sox to the expected format
 // sox -t wav test_file.wav -esigned-integer -b16 -r 22050 -t raw - | multimon-ng -a AFSK1200 -A -t raw -
 //
 // expected output should be:
-// APRS: W1AW>APZ001:!4142.88N/ 7243.63W-Test
+// APRS: W1AW>APZ001:!4142.88N/07243.63W-Test
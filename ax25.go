@@ -1,6 +1,10 @@
 package aprsgo
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+	"time"
+)
 
 // ax25.go contains routines for producing a valid ax25 data packet
 
@@ -25,10 +29,20 @@ type PositionData struct {
 	StationSSID SSID
 	Latitude    float64
 	Longitude   float64
-	Altitude    float64
-	Course      float64
-	Speed       float64
+	Altitude    float64 // feet
+	Course      float64 // degrees clockwise from true north
+	Speed       float64 // knots
+	Timestamp   time.Time
 	Comment     string
+	Ambiguity   int       // 0 to 4 trailing digits of the position blanked for privacy
+	DAO         DAOFormat // extra precision sent in a !DAO! extension
+	SymbolTable byte      // '/' primary, '\\' alternate or an overlay character, defaults to '/'
+	Symbol      byte      // the symbol code, defaults to '-' house
+	Range       float64   // pre-calculated radio range in miles, compressed format only
+
+	// CompressionType holds the compression type bits of a compressed report,
+	// 0 selects the type matching the course/speed, altitude or range sent
+	CompressionType CompressionType
 }
 
 // Destination SSID codes for AX.25 destination address fields
@@ -52,27 +66,33 @@ const (
 )
 
 // BasicAPRSReport constructs a basic APRS position report
-func (data PositionData) BasicAPRSReport() AX25Data {
+func (data PositionData) BasicAPRSReport() (AX25Data, error) {
+	if err := data.Validate(); err != nil {
+		return nil, err
+	}
 
-	destinationAddress := constructAddress(Version, DestSSIDVIAPath)
-	sourceAddress := constructAddress(data.Callsign, data.StationSSID)
+	destinationAddress := Address{Callsign: Version, SSID: DestSSIDVIAPath}
+	sourceAddress := Address{Callsign: data.Callsign, SSID: data.StationSSID}
 	informationField := data.CalculateBasicInformationField()
 
 	ax25data := AssembleAX25Data(sourceAddress, destinationAddress, informationField)
 
-	return AX25Data(ax25data)
+	return AX25Data(ax25data), nil
 }
 
 // CompressedAPRSReport constructs a basic APRS position report
-func (data PositionData) CompressedAPRSReport() AX25Data {
+func (data PositionData) CompressedAPRSReport() (AX25Data, error) {
+	if err := data.ValidateCompressed(); err != nil {
+		return nil, err
+	}
 
-	destinationAddress := constructAddress(Version, DestSSIDVIAPath)
-	sourceAddress := constructAddress(data.Callsign, data.StationSSID)
+	destinationAddress := Address{Callsign: Version, SSID: DestSSIDVIAPath}
+	sourceAddress := Address{Callsign: data.Callsign, SSID: data.StationSSID}
 	informationField := data.CalculateCompressedInformationField()
 
 	ax25data := AssembleAX25Data(sourceAddress, destinationAddress, informationField)
 
-	return AX25Data(ax25data)
+	return AX25Data(ax25data), nil
 }
 
 // Base91Encode encodes the given number to the given number of digits
@@ -95,6 +115,24 @@ func Base91Encode(number uint32, digits int) (string, error) {
 	return string(output), nil
 }
 
+// Base91Decode decodes a string of base-91 digits, the inverse of Base91Encode
+func Base91Decode(digits string) (uint32, error) {
+	if len(digits) == 0 || len(digits) > 5 {
+		return 0, fmt.Errorf("%q is not 1 to 5 base-91 digits", digits)
+	}
+	var number uint64
+	for i := 0; i < len(digits); i++ {
+		if digits[i] < 33 || digits[i] > 33+90 {
+			return 0, fmt.Errorf("%q is not a base-91 digit", digits[i])
+		}
+		number = number*91 + uint64(digits[i]-33)
+	}
+	if number > 1<<32-1 {
+		return 0, fmt.Errorf("%q overflows 32 bits", digits)
+	}
+	return uint32(number), nil
+}
+
 // CalculateCompressedInformationField returns the position in compressed format without any additional information
 func (data PositionData) CalculateCompressedInformationField() []byte {
 	/*
@@ -116,17 +154,23 @@ func (data PositionData) CalculateCompressedInformationField() []byte {
 		T
 		is the compression type indicator
 	*/
-	dataTypeIdentifier := "!"           // realtime position with no messaging
-	displaySymbolTableIdentifier := "/" // primary table
-	displaySymbol := "-"                // house
+	dataTypeIdentifier := "!" // realtime position with no messaging
+	displaySymbolTableIdentifier, displaySymbol := data.symbol()
+	if displaySymbolTableIdentifier >= '0' && displaySymbolTableIdentifier <= '9' {
+		displaySymbolTableIdentifier += 'a' - '0' // numeric overlays are sent as a-j
+	}
 
-	latString, _ := Base91Encode(uint32(380926*(90-data.Latitude)), 4) // TODO: properly handle out of bounds lat/long err
-	longString, _ := Base91Encode(uint32(190463*(180+data.Longitude)), 4)
+	lat, long := data.Latitude, data.Longitude
+	if data.Ambiguity > 0 { // the compressed format has no ambiguity, report the center of the ambiguous area
+		lat, long = ambiguousCenter(lat, data.Ambiguity), ambiguousCenter(long, data.Ambiguity)
+	}
+
+	latString, _ := Base91Encode(uint32(380926*(90-lat)), 4) // the position is checked by ValidateCompressed
+	longString, _ := Base91Encode(uint32(190463*(180+long)), 4)
 
-	courseSpeed := " s"    // " " indicates no information in this field "s" is just filler
-	compressionType := "T" // filler due to " " above
+	courseSpeed, compressionType := data.compressedCourseSpeed()
 
-	informationField := fmt.Sprintf("%s%s%s%s%s%s%s%s",
+	informationField := fmt.Sprintf("%s%c%s%s%c%s%s%s",
 		dataTypeIdentifier,
 		displaySymbolTableIdentifier,
 		latString,
@@ -150,8 +194,7 @@ func (data PositionData) CalculateBasicInformationField() []byte {
 		latDir = "S"
 		lat = -lat
 	}
-	latDeg := int(lat) // TODO to check for proper bounds
-	latMin := 60.0 * (lat - float64(latDeg))
+	latString, latDAO := basicCoordinate(lat, 2, data.Ambiguity, data.DAO)
 
 	longDir := "W" // default 0 is W
 	long := data.Longitude
@@ -160,52 +203,40 @@ func (data PositionData) CalculateBasicInformationField() []byte {
 	} else {
 		long = -long
 	}
-	longDeg := int(long) // TODO check for proper bounds
-	longMin := 60.0 * (long - float64(longDeg))
+	longString, longDAO := basicCoordinate(long, 3, data.Ambiguity, data.DAO)
 
-	displaySymbolTableIdentifier := "/" // primary table
-	displaySymbol := "-"                // house
+	displaySymbolTableIdentifier, displaySymbol := data.symbol()
 
-	informationField := fmt.Sprintf("%s%02d%2.2f%s%s%3d%2.2f%s%s%s",
+	informationField := fmt.Sprintf("%s%s%s%c%s%s%c%s",
 		dataTypeIdentifier,
-		latDeg,
-		latMin,
+		latString,
 		latDir,
 		displaySymbolTableIdentifier,
-		longDeg,
-		longMin,
+		longString,
 		longDir,
 		displaySymbol,
 		data.Comment)
+	if data.sendsDAO() {
+		informationField += formatDAO(data.DAO, latDAO, longDAO)
+	}
 
 	return []byte(informationField)
 }
 
-func constructAddress(callsign string, ssid SSID) [7]byte {
-	var address [7]byte
-	copy(address[:], "      ") // intialize the field with spaces for shorter callsigns
-	copy(address[:], callsign) // truncate any longer callsigns
-	address[6] = byte(ssid)    // write the SSID into the 7-th byte
-	return address
-}
-
-// AssembleAX25Data converts raw address, destination and information fields into an unnumbered information AX25 UI packet
-func AssembleAX25Data(sourceAddress [7]byte, destinationAddress [7]byte, informationField []byte) []byte {
+// AssembleAX25Data converts the source and destination addresses and information field into an unnumbered
+// information AX25 UI packet sent as a command, any digipeater addresses are added to the address field in order
+func AssembleAX25Data(sourceAddress Address, destinationAddress Address, informationField []byte, digipeaters ...Address) []byte {
 	var ax25data []byte
 
-	// append the addresses, destination first
-	for i := 0; i < 7; i++ {
-		destinationAddress[i] = destinationAddress[i] << 1 // AX.25 addresses are shifted left one bit
+	// append the addresses, destination first, with the C bit of the destination set for a command
+	field := destinationAddress.field(true, false)
+	ax25data = append(ax25data, field[:]...)
+	field = sourceAddress.field(false, len(digipeaters) == 0)
+	ax25data = append(ax25data, field[:]...)
+	for i, digipeater := range digipeaters {
+		field = digipeater.field(digipeater.Repeated, i == len(digipeaters)-1)
+		ax25data = append(ax25data, field[:]...)
 	}
-	ax25data = append(ax25data, destinationAddress[:]...)
-	for i := 0; i < 7; i++ {
-		sourceAddress[i] = sourceAddress[i] << 1 // AX.25 addresses are shifted left one bit
-	}
-
-	// note that AX25 data allows additional address fields here as well, not currently supported
-
-	sourceAddress[6]++ // the final address bit is set to one
-	ax25data = append(ax25data, sourceAddress[:]...)
 
 	// standard flags for APRS
 	ax25data = append(ax25data, controlFlag)
@@ -244,3 +275,129 @@ func appendFCS(ax25data []byte) []byte {
 	ax25data = append(ax25data, fcsLSB)
 	return ax25data
 }
+
+// Packet is a decoded AX.25 UI frame
+type Packet struct {
+	Destination string   // destination callsign with any non-zero SSID, e.g. APZ001
+	Source      string   // source callsign with any non-zero SSID, e.g. W1AW-7
+	Path        []string // digipeater addresses, with a trailing * once repeated
+	Information []byte   // the information field
+}
+
+// Decode checks the Frame Check Sequence and splits the ax25data into addresses and information
+func (ax25data AX25Data) Decode() (Packet, error) {
+	var packet Packet
+	frame, err := ax25data.checkFCS()
+	if err != nil {
+		return packet, err
+	}
+	addresses, offset, err := decodeAddresses(frame)
+	if err != nil {
+		return packet, err
+	}
+	if offset+2 > len(frame) || frame[offset] != controlFlag || frame[offset+1] != pID {
+		return packet, fmt.Errorf("not an APRS UI frame")
+	}
+
+	addresses[0].Repeated, addresses[1].Repeated = false, false // the C bits are not H bits for these
+	packet.Destination = addresses[0].String()
+	packet.Source = addresses[1].String()
+	for _, digipeater := range addresses[2:] {
+		packet.Path = append(packet.Path, digipeater.String())
+	}
+	packet.Information = frame[offset+2:]
+	return packet, nil
+}
+
+// checkFCS returns the frame without the Frame Check Sequence, or an error if the sequence does not match
+func (ax25data AX25Data) checkFCS() ([]byte, error) {
+	if len(ax25data) < 2*addressFieldSize+1+2 { // two addresses, control and FCS
+		return nil, fmt.Errorf("frame of %d bytes is too short", len(ax25data))
+	}
+	frame, fcs := ax25data[:len(ax25data)-2], ax25data[len(ax25data)-2:]
+	if crc := calcCRC(frame); byte(crc) != fcs[0] || byte(crc>>8) != fcs[1] {
+		return nil, fmt.Errorf("frame check sequence %02x%02x does not match %04x", fcs[1], fcs[0], crc)
+	}
+	return frame, nil
+}
+
+// decodeAddresses returns the destination, source and digipeater addresses at the start of the frame,
+// with the C/H bits in Repeated, and the offset of the control field
+func decodeAddresses(frame []byte) ([]Address, int, error) {
+	var addresses []Address
+	offset, last := 0, false
+	for !last {
+		if offset+addressFieldSize > len(frame) {
+			return nil, 0, fmt.Errorf("address field is not terminated")
+		}
+		var address Address
+		address, last = decodeAddressField(frame[offset : offset+addressFieldSize])
+		addresses = append(addresses, address)
+		offset += addressFieldSize
+	}
+	if len(addresses) < 2 || len(addresses) > 10 {
+		return nil, 0, fmt.Errorf("frame has %d addresses, 2 to 10 allowed", len(addresses))
+	}
+	return addresses, offset, nil
+}
+
+// String returns the packet in the TNC2 monitor format SRC>DEST,PATH:info
+func (packet Packet) String() string {
+	header := packet.Source + ">" + packet.Destination
+	for _, digipeater := range packet.Path {
+		header += "," + digipeater
+	}
+	return header + ":" + string(packet.Information)
+}
+
+// ParsePacket parses a packet in the TNC2 monitor format SRC>DEST,PATH:info
+func ParsePacket(tnc2 string) (Packet, error) {
+	var packet Packet
+	colon := strings.IndexByte(tnc2, ':')
+	if colon < 0 {
+		return packet, fmt.Errorf("packet %q has no information field", tnc2)
+	}
+	header := tnc2[:colon]
+	packet.Information = []byte(tnc2[colon+1:])
+	gt := strings.IndexByte(header, '>')
+	if gt <= 0 {
+		return packet, fmt.Errorf("packet header %q has no source", header)
+	}
+	packet.Source = header[:gt]
+	addresses := strings.Split(header[gt+1:], ",")
+	if addresses[0] == "" {
+		return packet, fmt.Errorf("packet header %q has no destination", header)
+	}
+	packet.Destination = addresses[0]
+	for _, digipeater := range addresses[1:] {
+		if digipeater == "" || digipeater == "*" {
+			return packet, fmt.Errorf("packet header %q has an empty digipeater", header)
+		}
+	}
+	packet.Path = addresses[1:]
+	return packet, nil
+}
+
+// AX25Data converts the packet to an AX.25 UI frame, the addresses must be valid AX.25 addresses
+func (packet Packet) AX25Data() (AX25Data, error) {
+	destinationAddress, err := ParseAddress(packet.Destination)
+	if err != nil {
+		return nil, err
+	}
+	sourceAddress, err := ParseAddress(packet.Source)
+	if err != nil {
+		return nil, err
+	}
+	var digipeaters []Address
+	for _, digipeater := range packet.Path {
+		address, err := ParseAddress(digipeater)
+		if err != nil {
+			return nil, err
+		}
+		digipeaters = append(digipeaters, address)
+	}
+	if len(digipeaters) > 8 {
+		return nil, fmt.Errorf("packet has %d digipeaters, at most 8 allowed", len(digipeaters))
+	}
+	return AX25Data(AssembleAX25Data(sourceAddress, destinationAddress, packet.Information, digipeaters...)), nil
+}
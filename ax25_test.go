@@ -12,7 +12,10 @@ func TestNewBasicAPRSAX25Data(t *testing.T) {
 		Comment:   "Test",
 	}
 
-	ax25data := report.BasicAPRSReport()
+	ax25data, err := report.BasicAPRSReport()
+	if err != nil {
+		t.Fatalf("Report failed validation: %v", err)
+	}
 	// decode the address fields
 	for i := 0; i < 14; i++ {
 		ax25data[i] = ax25data[i] >> 1
@@ -20,6 +23,23 @@ func TestNewBasicAPRSAX25Data(t *testing.T) {
 	t.Log(string(ax25data))
 }
 
+func TestBasicInformationFieldPadding(t *testing.T) {
+	tests := []struct {
+		lat, long float64
+		want      string
+	}{
+		{41.7147, -72.7272, "!4142.88N/07243.63W-"},
+		{1.1, -5.05, "!0106.00N/00503.00W-"},
+		{-33.86, 151.21, "!3351.60S/15112.60E-"},
+	}
+	for _, test := range tests {
+		report := PositionData{Latitude: test.lat, Longitude: test.long}
+		if got := string(report.CalculateBasicInformationField()); got != test.want {
+			t.Errorf("%v %v: expected %s got %s", test.lat, test.long, test.want, got)
+		}
+	}
+}
+
 func TestBase91Encode(t *testing.T) {
 	testCases := []struct {
 		In   uint32
@@ -41,6 +61,13 @@ func TestBase91Encode(t *testing.T) {
 		if got != testCase.Want {
 			t.Errorf("Encoding %d, expected: %s got: %s", testCase.In, testCase.Want, got)
 		}
+		decoded, err := Base91Decode(got)
+		if err != nil {
+			t.Errorf("Decoding %s failed with error %v", got, err)
+		}
+		if decoded != testCase.In {
+			t.Errorf("Decoding %s, expected: %d got: %d", got, testCase.In, decoded)
+		}
 		_, err = Base91Encode(testCase.In, 3) // too few digits to encode the number
 		if err == nil {
 			t.Errorf("Encoding %d expected to fail when using 3 digits, but didn't", testCase.In)
@@ -50,6 +77,25 @@ func TestBase91Encode(t *testing.T) {
 	}
 }
 
+func TestBase91Decode(t *testing.T) {
+	for _, number := range []uint32{0, 1, 90, 91, 8280, 8281, 753570, 68574960, 1<<32 - 1} {
+		encoded, err := Base91Encode(number, 5)
+		if err != nil {
+			t.Errorf("Encoding %d failed with error %v", number, err)
+			continue
+		}
+		decoded, err := Base91Decode(encoded)
+		if err != nil || decoded != number {
+			t.Errorf("Decoding %s, expected: %d got: %d, %v", encoded, number, decoded, err)
+		}
+	}
+	for _, bad := range []string{"", " !!!", "!!!!!!", "~~~~~", "{{{{{"} {
+		if _, err := Base91Decode(bad); err == nil {
+			t.Errorf("Decoding %q expected to fail, but didn't", bad)
+		}
+	}
+}
+
 func TestNewCompressedAPRSAX25Data(t *testing.T) {
 	report := PositionData{
 		Callsign:  "W1AW",
@@ -58,10 +104,43 @@ func TestNewCompressedAPRSAX25Data(t *testing.T) {
 		Comment:   "Test",
 	}
 
-	ax25data := report.CompressedAPRSReport()
+	ax25data, err := report.CompressedAPRSReport()
+	if err != nil {
+		t.Fatalf("Report failed validation: %v", err)
+	}
 	// decode the address fields
 	for i := 0; i < 14; i++ {
 		ax25data[i] = ax25data[i] >> 1
 	}
 	t.Log(string(ax25data))
 }
+
+func TestDecode(t *testing.T) {
+	report := PositionData{
+		Callsign:    "W1AW",
+		StationSSID: 7,
+		Latitude:    41.7147,
+		Longitude:   -72.7272,
+		Comment:     "Test",
+	}
+
+	ax25data, err := report.BasicAPRSReport()
+	if err != nil {
+		t.Fatalf("Report failed validation: %v", err)
+	}
+	packet, err := ax25data.Decode()
+	if err != nil {
+		t.Fatalf("Decoding failed with error %v", err)
+	}
+	if want := "W1AW-7>APZ001:!4142.88N/07243.63W-Test"; packet.String() != want {
+		t.Errorf("Expected %s got %s", want, packet.String())
+	}
+
+	ax25data[20] ^= 0x01 // corrupt a bit of the information field
+	if _, err := ax25data.Decode(); err == nil {
+		t.Errorf("Decoding a corrupted frame should fail the frame check sequence")
+	}
+	if _, err := AX25Data([]byte{0x00, 0x01}).Decode(); err == nil {
+		t.Errorf("Decoding a short frame should fail")
+	}
+}
@@ -0,0 +1,284 @@
+package aprsgo
+
+// bulletin.go contains routines for producing and parsing APRS bulletins, announcements
+// and NWS-style group messages, and a bulletin board that keeps the latest version of each
+// and schedules their re-transmission
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// BulletinKind distinguishes the types of bulletin addressees
+type BulletinKind int
+
+// Bulletin kinds by addressee
+const (
+	BulletinGeneral      BulletinKind = iota // BLN0 to BLN9
+	BulletinAnnouncement                     // BLNA to BLNZ
+	BulletinGroup                            // BLN followed by a line number and group name, e.g. BLN4WX
+	BulletinNWS                              // NWS-xxxxx weather service bulletins, e.g. NWS-WARN
+)
+
+const (
+	bulletinPrefix     = "BLN"
+	nwsPrefix          = "NWS-"
+	maxGroupNameLength = 5 // group names fill the rest of the 9 character addressee
+)
+
+var (
+	bulletinLifetime        = 4 * time.Hour    // bulletins not renewed within this time expire
+	bulletinInitialInterval = 30 * time.Second // the first re-transmission interval
+	bulletinMaxInterval     = 30 * time.Minute // the re-transmission interval doubles up to this
+)
+
+// BulletinData contains the data to construct an APRS bulletin or announcement
+type BulletinData struct {
+	Callsign    string // limited to 6 ASCII characters
+	StationSSID SSID
+	Addressee   string // BLN0-BLN9, BLNA-BLNZ, BLN#GROUP or NWS-xxxxx
+	Text        string // up to 67 characters
+}
+
+// NewBulletin returns general bulletin line 0-9
+func NewBulletin(callsign string, ssid SSID, line int, text string) (BulletinData, error) {
+	if line < 0 || line > 9 {
+		return BulletinData{}, fmt.Errorf("bulletin line %d is not 0 to 9", line)
+	}
+	return BulletinData{Callsign: callsign, StationSSID: ssid, Addressee: fmt.Sprintf("%s%d", bulletinPrefix, line), Text: text}, nil
+}
+
+// NewAnnouncement returns announcement A-Z
+func NewAnnouncement(callsign string, ssid SSID, id byte, text string) (BulletinData, error) {
+	if id < 'A' || id > 'Z' {
+		return BulletinData{}, fmt.Errorf("announcement identifier %q is not A to Z", id)
+	}
+	return BulletinData{Callsign: callsign, StationSSID: ssid, Addressee: bulletinPrefix + string(id), Text: text}, nil
+}
+
+// NewGroupBulletin returns line 0-9 of the bulletin for the named group, e.g. BLN4WX
+func NewGroupBulletin(callsign string, ssid SSID, line int, group string, text string) (BulletinData, error) {
+	if line < 0 || line > 9 {
+		return BulletinData{}, fmt.Errorf("bulletin line %d is not 0 to 9", line)
+	}
+	if group == "" || len(group) > maxGroupNameLength || strings.ContainsAny(group, " :") {
+		return BulletinData{}, fmt.Errorf("bulletin group %q is not 1 to %d characters", group, maxGroupNameLength)
+	}
+	return BulletinData{Callsign: callsign, StationSSID: ssid, Addressee: fmt.Sprintf("%s%d%s", bulletinPrefix, line, group), Text: text}, nil
+}
+
+// NewNWSBulletin returns a weather service bulletin of the given type, e.g. WARN for NWS-WARN
+func NewNWSBulletin(callsign string, ssid SSID, nwsType string, text string) (BulletinData, error) {
+	if nwsType == "" || len(nwsPrefix)+len(nwsType) > addresseeLength || strings.ContainsAny(nwsType, " :") {
+		return BulletinData{}, fmt.Errorf("NWS bulletin type %q is not 1 to %d characters", nwsType, addresseeLength-len(nwsPrefix))
+	}
+	return BulletinData{Callsign: callsign, StationSSID: ssid, Addressee: nwsPrefix + nwsType, Text: text}, nil
+}
+
+// bulletinKind returns the kind of bulletin addressed to addressee, and false if it is not a bulletin
+func bulletinKind(addressee string) (BulletinKind, bool) {
+	if strings.HasPrefix(addressee, nwsPrefix) && len(addressee) > len(nwsPrefix) {
+		return BulletinNWS, true
+	}
+	if !strings.HasPrefix(addressee, bulletinPrefix) || len(addressee) < len(bulletinPrefix)+1 {
+		return 0, false
+	}
+	id := addressee[len(bulletinPrefix)]
+	switch {
+	case id >= '0' && id <= '9' && len(addressee) == len(bulletinPrefix)+1:
+		return BulletinGeneral, true
+	case id >= '0' && id <= '9':
+		return BulletinGroup, true
+	case id >= 'A' && id <= 'Z' && len(addressee) == len(bulletinPrefix)+1:
+		return BulletinAnnouncement, true
+	}
+	return 0, false
+}
+
+// Kind returns the kind of the bulletin from its addressee
+func (data BulletinData) Kind() BulletinKind {
+	kind, _ := bulletinKind(data.Addressee)
+	return kind
+}
+
+// Group returns the group name of a group bulletin, or the type of an NWS bulletin
+func (data BulletinData) Group() string {
+	switch data.Kind() {
+	case BulletinGroup:
+		return data.Addressee[len(bulletinPrefix)+1:]
+	case BulletinNWS:
+		return data.Addressee[len(nwsPrefix):]
+	}
+	return ""
+}
+
+// BulletinAPRSReport constructs an APRS bulletin, sent as a message without a message number
+func (data BulletinData) BulletinAPRSReport() (AX25Data, error) {
+	if err := data.Validate(); err != nil {
+		return nil, err
+	}
+
+	message := MessageData{
+		Callsign:    data.Callsign,
+		StationSSID: data.StationSSID,
+		Addressee:   data.Addressee,
+		Text:        data.Text,
+	}
+	return message.MessageAPRSReport()
+}
+
+// CalculateBulletinInformationField returns the bulletin as :BLN0     :text
+func (data BulletinData) CalculateBulletinInformationField() []byte {
+	message := MessageData{Addressee: data.Addressee, Text: data.Text}
+	return message.CalculateMessageInformationField()
+}
+
+// ParseBulletinInformationField parses a bulletin, announcement or NWS bulletin from an information field
+func ParseBulletinInformationField(informationField []byte) (BulletinData, error) {
+	message, err := ParseMessageInformationField(informationField)
+	if err != nil {
+		return BulletinData{}, err
+	}
+	if _, ok := bulletinKind(message.Addressee); !ok {
+		return BulletinData{}, fmt.Errorf("addressee %q is not a bulletin", message.Addressee)
+	}
+	text := message.Text
+	if message.MessageID != "" { // bulletins are not acknowledged, keep any brace as text
+		text += "{" + message.MessageID
+	}
+	return BulletinData{Addressee: message.Addressee, Text: text}, nil
+}
+
+// BulletinBoard stores the latest version of each bulletin heard or posted, expires
+// them after their lifetime and schedules re-transmission of the posted ones on a
+// decaying schedule, the interval doubling after each transmission
+type BulletinBoard struct {
+	Lifetime        time.Duration // bulletins expire this long after they were last received or posted
+	InitialInterval time.Duration // the first re-transmission interval of a posted bulletin
+	MaxInterval     time.Duration // the longest re-transmission interval
+	entries         map[string]*bulletinEntry
+}
+
+type bulletinEntry struct {
+	data         BulletinData
+	updated      time.Time     // when this version was last received or posted
+	local        bool          // posted by this station and re-transmitted
+	nextTransmit time.Time     // when the posted bulletin is next due
+	interval     time.Duration // the current re-transmission interval
+}
+
+// NewBulletinBoard returns an empty bulletin board with the default lifetime and schedule
+func NewBulletinBoard() *BulletinBoard {
+	return &BulletinBoard{
+		Lifetime:        bulletinLifetime,
+		InitialInterval: bulletinInitialInterval,
+		MaxInterval:     bulletinMaxInterval,
+		entries:         make(map[string]*bulletinEntry),
+	}
+}
+
+// bulletins from different stations with the same addressee are different bulletins
+func bulletinKey(data BulletinData) string {
+	return fmt.Sprintf("%s-%d>%s", data.Callsign, data.StationSSID, data.Addressee)
+}
+
+// Post adds or replaces a bulletin sent by this station, a new or changed bulletin is due immediately
+func (b *BulletinBoard) Post(data BulletinData, now time.Time) error {
+	if err := data.Validate(); err != nil {
+		return err
+	}
+	key := bulletinKey(data)
+	if entry, ok := b.entries[key]; ok && entry.local && entry.data.Text == data.Text {
+		entry.updated = now // renew an unchanged bulletin without restarting its schedule
+		return nil
+	}
+	b.entries[key] = &bulletinEntry{data: data, updated: now, local: true, nextTransmit: now, interval: b.InitialInterval}
+	return nil
+}
+
+// Receive stores a bulletin heard from another station, replacing any earlier version
+func (b *BulletinBoard) Receive(packet Packet, now time.Time) error {
+	data, err := ParseBulletinInformationField(packet.Information)
+	if err != nil {
+		return err
+	}
+	source, err := ParseTacticalAddress(packet.Source)
+	if err != nil {
+		return err
+	}
+	data.Callsign, data.StationSSID = source.Callsign, source.SSID
+	key := bulletinKey(data)
+	if entry, ok := b.entries[key]; ok && entry.local {
+		return errors.New("bulletin was posted by this station")
+	}
+	b.entries[key] = &bulletinEntry{data: data, updated: now}
+	return nil
+}
+
+// Remove cancels a bulletin
+func (b *BulletinBoard) Remove(data BulletinData) {
+	delete(b.entries, bulletinKey(data))
+}
+
+// Expire removes all bulletins not received or posted within their lifetime
+func (b *BulletinBoard) Expire(now time.Time) {
+	for key, entry := range b.entries {
+		if now.Sub(entry.updated) >= b.Lifetime {
+			delete(b.entries, key)
+		}
+	}
+}
+
+// Bulletins returns the current bulletins sorted by station and addressee
+func (b *BulletinBoard) Bulletins(now time.Time) []BulletinData {
+	b.Expire(now)
+	var bulletins []BulletinData
+	for _, entry := range b.entries {
+		bulletins = append(bulletins, entry.data)
+	}
+	sort.Slice(bulletins, func(i, j int) bool {
+		return bulletinKey(bulletins[i]) < bulletinKey(bulletins[j])
+	})
+	return bulletins
+}
+
+// Due returns the packets for the posted bulletins due for transmission at now
+// and schedules their next transmission
+func (b *BulletinBoard) Due(now time.Time) []AX25Data {
+	b.Expire(now)
+	var due []*bulletinEntry
+	for _, entry := range b.entries {
+		if entry.local && !now.Before(entry.nextTransmit) {
+			due = append(due, entry)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		return bulletinKey(due[i].data) < bulletinKey(due[j].data)
+	})
+	var packets []AX25Data
+	for _, entry := range due {
+		ax25data, _ := entry.data.BulletinAPRSReport() // posted bulletins were validated by Post
+		packets = append(packets, ax25data)
+		entry.nextTransmit = now.Add(entry.interval)
+		entry.interval *= 2
+		if entry.interval > b.MaxInterval {
+			entry.interval = b.MaxInterval
+		}
+	}
+	return packets
+}
+
+// NextDue returns when the next posted bulletin is due, and false if there are none
+func (b *BulletinBoard) NextDue() (time.Time, bool) {
+	var next time.Time
+	found := false
+	for _, entry := range b.entries {
+		if entry.local && (!found || entry.nextTransmit.Before(next)) {
+			next, found = entry.nextTransmit, true
+		}
+	}
+	return next, found
+}
@@ -0,0 +1,131 @@
+package aprsgo
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBulletinInformationField(t *testing.T) {
+	general, _ := NewBulletin("W1AW", 0, 3, "Snow expected in Hartford")
+	announcement, _ := NewAnnouncement("W1AW", 0, 'Q', "Field day June 24")
+	group, _ := NewGroupBulletin("W1AW", 0, 4, "WX", "Net tonight at 8")
+	nws, _ := NewNWSBulletin("W1AW", 0, "WARN", "Tornado warning")
+
+	testCases := []struct {
+		In    BulletinData
+		Kind  BulletinKind
+		Group string
+		Want  string
+	}{
+		{In: general, Kind: BulletinGeneral, Want: ":BLN3     :Snow expected in Hartford"},
+		{In: announcement, Kind: BulletinAnnouncement, Want: ":BLNQ     :Field day June 24"},
+		{In: group, Kind: BulletinGroup, Group: "WX", Want: ":BLN4WX   :Net tonight at 8"},
+		{In: nws, Kind: BulletinNWS, Group: "WARN", Want: ":NWS-WARN :Tornado warning"},
+	}
+
+	for _, testCase := range testCases {
+		got := string(testCase.In.CalculateBulletinInformationField())
+		if got != testCase.Want {
+			t.Errorf("Expected %s got %s", testCase.Want, got)
+		}
+		parsed, err := ParseBulletinInformationField([]byte(got))
+		if err != nil {
+			t.Errorf("Parsing %s failed with error %v", got, err)
+			continue
+		}
+		if parsed.Addressee != testCase.In.Addressee || parsed.Text != testCase.In.Text {
+			t.Errorf("Parsing %s expected %+v got %+v", got, testCase.In, parsed)
+		}
+		if parsed.Kind() != testCase.Kind || parsed.Group() != testCase.Group {
+			t.Errorf("Parsing %s expected kind %v group %q got %v %q", got, testCase.Kind, testCase.Group, parsed.Kind(), parsed.Group())
+		}
+	}
+
+	if _, err := NewBulletin("W1AW", 0, 10, "x"); err == nil {
+		t.Errorf("Creating bulletin line 10 should fail")
+	}
+	if _, err := NewAnnouncement("W1AW", 0, 'a', "x"); err == nil {
+		t.Errorf("Creating announcement a should fail")
+	}
+	if _, err := NewGroupBulletin("W1AW", 0, 1, "TOOLONG", "x"); err == nil {
+		t.Errorf("Creating a group bulletin with a long group name should fail")
+	}
+	if _, err := NewNWSBulletin("W1AW", 0, "", "x"); err == nil {
+		t.Errorf("Creating an NWS bulletin without a type should fail")
+	}
+	for _, bad := range []string{":W1AW     :Not a bulletin", ":BLNAB    :Not a bulletin", ":NWS-     :x"} {
+		if _, err := ParseBulletinInformationField([]byte(bad)); err == nil {
+			t.Errorf("Parsing %s expected to fail, but didn't", bad)
+		}
+	}
+}
+
+func TestBulletinBoard(t *testing.T) {
+	start := time.Date(2017, 5, 1, 12, 0, 0, 0, time.UTC)
+	board := NewBulletinBoard()
+	board.InitialInterval, board.MaxInterval, board.Lifetime = time.Minute, 4*time.Minute, time.Hour
+
+	bulletin, _ := NewBulletin("W1AW", 0, 0, "Club meeting Tuesday")
+	if err := board.Post(bulletin, start); err != nil {
+		t.Fatalf("Posting failed with error %v", err)
+	}
+	if err := board.Post(BulletinData{Callsign: "W1AW", Addressee: "W1AW-1", Text: "x"}, start); err == nil {
+		t.Errorf("Posting a message that isn't a bulletin should fail")
+	}
+
+	// transmissions at 0, 1, 3, 7, 11, 15 minutes
+	var sent []time.Duration
+	for minute := 0; minute <= 15; minute++ {
+		now := start.Add(time.Duration(minute) * time.Minute)
+		for _, ax25data := range board.Due(now) {
+			packet, err := ax25data.Decode()
+			if err != nil || string(packet.Information) != ":BLN0     :Club meeting Tuesday" {
+				t.Errorf("Unexpected bulletin packet %v, %v", packet, err)
+			}
+			sent = append(sent, now.Sub(start))
+		}
+	}
+	want := []time.Duration{0, time.Minute, 3 * time.Minute, 7 * time.Minute, 11 * time.Minute, 15 * time.Minute}
+	if len(sent) != len(want) {
+		t.Fatalf("Expected transmissions at %v got %v", want, sent)
+	}
+	for i := range want {
+		if sent[i] != want[i] {
+			t.Errorf("Expected transmissions at %v got %v", want, sent)
+			break
+		}
+	}
+
+	// a changed bulletin replaces the old one and restarts the schedule
+	bulletin.Text = "Club meeting moved to Wednesday"
+	board.Post(bulletin, start.Add(16*time.Minute))
+	if next, ok := board.NextDue(); !ok || !next.Equal(start.Add(16*time.Minute)) {
+		t.Errorf("A changed bulletin should be due immediately, next due %v", next)
+	}
+
+	// heard bulletins are stored but not re-transmitted
+	heard, _ := ParsePacket("KB2ICI>APRS::BLNA     :Hamfest Saturday")
+	if err := board.Receive(heard, start.Add(16*time.Minute)); err != nil {
+		t.Fatalf("Receiving failed with error %v", err)
+	}
+	if err := board.Receive(Packet{Source: "KB2ICI-0A", Information: heard.Information}, start.Add(16*time.Minute)); err == nil {
+		t.Errorf("Receiving a bulletin from an invalid source should fail")
+	}
+	heard.Information = []byte(":BLNA     :Hamfest Sunday") // an updated version replaces the earlier one
+	board.Receive(heard, start.Add(17*time.Minute))
+	bulletins := board.Bulletins(start.Add(17 * time.Minute))
+	if len(bulletins) != 2 || bulletins[0].Text != "Hamfest Sunday" || bulletins[1].Text != bulletin.Text {
+		t.Errorf("Unexpected bulletins %+v", bulletins)
+	}
+	if due := board.Due(start.Add(17 * time.Minute)); len(due) != 1 {
+		t.Errorf("Only the posted bulletin should be due, got %d", len(due))
+	}
+
+	// everything expires after the lifetime
+	if bulletins := board.Bulletins(start.Add(80 * time.Minute)); len(bulletins) != 0 {
+		t.Errorf("Bulletins should have expired, got %+v", bulletins)
+	}
+	if _, ok := board.NextDue(); ok {
+		t.Errorf("Expired bulletins should not be due")
+	}
+}
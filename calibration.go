@@ -0,0 +1,115 @@
+package aprsgo
+
+// calibration.go contains the steady tone patterns for setting the deviation of a radio: either
+// tone alone, the tones alternating at the symbol rate and a preamble of flags
+
+import (
+	"fmt"
+	"time"
+)
+
+// CalibrationPattern is a steady signal for setting up a radio
+type CalibrationPattern int
+
+// Calibration patterns
+const (
+	CalibrateMark        CalibrationPattern = iota // the 1200 Hz mark tone only
+	CalibrateSpace                                 // the 2200 Hz space tone only
+	CalibrateAlternating                           // mark and space alternating every symbol, 600 Hz square wave FSK
+	CalibrateFlags                                 // HDLC flags, as sent in the preamble
+)
+
+func (pattern CalibrationPattern) String() string {
+	switch pattern {
+	case CalibrateMark:
+		return "mark"
+	case CalibrateSpace:
+		return "space"
+	case CalibrateAlternating:
+		return "alternating"
+	case CalibrateFlags:
+		return "flags"
+	}
+	return fmt.Sprintf("CalibrationPattern(%d)", int(pattern))
+}
+
+// Calibration is a calibration pattern sent for a duration at a level
+type Calibration struct {
+	Pattern  CalibrationPattern
+	Duration time.Duration
+	Level    float64 // between 0.0 and 1.0 of full scale, the volume set with SetVolume if zero
+}
+
+// Symbols returns the symbols of the pattern for the duration, rounded to whole symbols
+func (c Calibration) Symbols() SymbolStream {
+	n := int((c.Duration*time.Duration(symbolRate) + time.Second/2) / time.Second)
+	symbolStream := make(SymbolStream, 0, n)
+	switch c.Pattern {
+	case CalibrateFlags:
+		currentSymbol, consecutiveOnes := mark, 0
+		for len(symbolStream) < n {
+			symbolStream, currentSymbol, consecutiveOnes = writeByte(flag, currentSymbol, consecutiveOnes, false, symbolStream)
+		}
+		symbolStream = symbolStream[:n]
+	default:
+		for i := 0; i < n; i++ {
+			switch {
+			case c.Pattern == CalibrateSpace, c.Pattern == CalibrateAlternating && i%2 == 1:
+				symbolStream = append(symbolStream, space)
+			default:
+				symbolStream = append(symbolStream, mark)
+			}
+		}
+	}
+	return symbolStream
+}
+
+// level returns the level of the calibration, clipped to range
+func (c Calibration) level() float64 {
+	if c.Level == 0 {
+		return scalingFactor
+	}
+	if c.Level > 1.0 {
+		return 1.0
+	}
+	if c.Level < 0.0 {
+		return 0.0
+	}
+	return c.Level
+}
+
+// Modulate returns the audio of the calibration as samples between -1.0 and 1.0, shaped as set
+// with SetToneShaping so the deviation is set for the signal as sent on air, none below one
+// sample per symbol
+func (c Calibration) Modulate(samplesPerSecond uint32) []float64 {
+	w, err := newWaveWriter(samplesPerSecond, 16, 1) // only the timing, phase and shaping of the writer are used
+	if err != nil {
+		return nil
+	}
+	level := c.level()
+	var samples []float64
+	for _, sym := range c.Symbols() {
+		for i := w.nextSymbolSamples(); i > 0; i-- {
+			samples = append(samples, level*w.nextSample(sym))
+		}
+	}
+	return samples
+}
+
+// WriteWAV writes the calibration out to a WAV file with parameters given by params
+func (c Calibration) WriteWAV(params WAVParams) error {
+	wr, err := newWaveWriter(params.SamplesPerSecond, params.BitsPerSample, params.NumChannels)
+	if err != nil {
+		return err
+	}
+	if params.Float {
+		if err := wr.useFloat(); err != nil {
+			return err
+		}
+	}
+	wr.setLevel(c.level())
+	for _, sym := range c.Symbols() {
+		wr.writeSymbol(sym)
+	}
+	return wr.writeFile(params.Filename)
+}
@@ -0,0 +1,125 @@
+package aprsgo
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCalibrationSymbols(t *testing.T) {
+	tests := []struct {
+		pattern CalibrationPattern
+		prefix  SymbolStream
+	}{
+		{CalibrateMark, SymbolStream{mark, mark, mark, mark}},
+		{CalibrateSpace, SymbolStream{space, space, space, space}},
+		{CalibrateAlternating, SymbolStream{mark, space, mark, space}},
+	}
+	for _, test := range tests {
+		symbolStream := Calibration{Pattern: test.pattern, Duration: time.Second}.Symbols()
+		if len(symbolStream) != int(symbolRate) {
+			t.Errorf("%v: expected %d symbols, got %d", test.pattern, symbolRate, len(symbolStream))
+			continue
+		}
+		for n, sym := range symbolStream {
+			if sym != test.prefix[n%len(test.prefix)] {
+				t.Errorf("%v: unexpected symbol %d", test.pattern, n)
+				break
+			}
+		}
+	}
+
+	symbolStream := Calibration{Pattern: CalibrateFlags, Duration: 100 * time.Millisecond}.Symbols()
+	if len(symbolStream) != 120 {
+		t.Fatalf("flags: expected 120 symbols, got %d", len(symbolStream))
+	}
+	bits := symbolStream.bits()
+	for i := 0; i+8 <= len(bits); i += 8 {
+		var b byte
+		for j := 0; j < 8; j++ {
+			if bits[i+j] == one {
+				b |= 1 << j
+			}
+		}
+		if b != flag {
+			t.Errorf("flags: byte %d expected %#x, got %#x", i/8, flag, b)
+		}
+	}
+}
+
+func TestCalibrationModulate(t *testing.T) {
+	const samplesPerSecond = 48000
+	tests := []struct {
+		pattern CalibrationPattern
+		level   float64
+		mark    float64
+		space   float64
+		peak    float64
+	}{
+		{CalibrateMark, 0.5, 0.5, 0, 0.5},
+		{CalibrateSpace, 0.5, 0, 0.5, 0.5},
+		{CalibrateMark, 0, scalingFactor, 0, scalingFactor},
+		{CalibrateMark, 2, 1, 0, 1},
+	}
+	for _, test := range tests {
+		samples := Calibration{Pattern: test.pattern, Duration: time.Second, Level: test.level}.Modulate(samplesPerSecond)
+		if len(samples) != samplesPerSecond {
+			t.Errorf("%v level %v: expected %d samples, got %d", test.pattern, test.level, samplesPerSecond, len(samples))
+			continue
+		}
+		mark := toneAmplitude(samples, markFreq, samplesPerSecond)
+		space := toneAmplitude(samples, spaceFreq, samplesPerSecond)
+		if math.Abs(mark-test.mark) > 0.01 || math.Abs(space-test.space) > 0.01 {
+			t.Errorf("%v level %v: expected mark %v and space %v, got %v and %v", test.pattern, test.level, test.mark, test.space, mark, space)
+		}
+		peak := 0.0
+		for _, sample := range samples {
+			peak = math.Max(peak, math.Abs(sample))
+		}
+		if math.Abs(peak-test.peak) > 0.01 {
+			t.Errorf("%v level %v: expected peak %v, got %v", test.pattern, test.level, test.peak, peak)
+		}
+	}
+
+	// alternating tones are the symbols modulated as for a packet, at the calibration level
+	calibration := Calibration{Pattern: CalibrateAlternating, Duration: time.Second, Level: 0.5}
+	samples := calibration.Modulate(samplesPerSecond)
+	expected := calibration.Symbols().Modulate(samplesPerSecond)
+	if len(samples) != len(expected) {
+		t.Fatalf("alternating: expected %d samples, got %d", len(expected), len(samples))
+	}
+	for n := range samples {
+		if math.Abs(samples[n]-expected[n]*0.5/scalingFactor) > 1e-12 {
+			t.Fatalf("alternating: sample %d expected %v, got %v", n, expected[n]*0.5/scalingFactor, samples[n])
+		}
+	}
+	if decoded := (Receiver{}).Receive(Calibration{Pattern: CalibrateFlags, Duration: time.Second}.Modulate(samplesPerSecond), samplesPerSecond); len(decoded) != 0 {
+		t.Errorf("flags: expected no frames, got %d", len(decoded))
+	}
+}
+
+func TestCalibrationWriteWAV(t *testing.T) {
+	calibration := Calibration{Pattern: CalibrateSpace, Duration: 500 * time.Millisecond, Level: 0.25}
+	for _, float := range []bool{false, true} {
+		params := WAVParams{Filename: filepath.Join(t.TempDir(), "cal.wav"), SamplesPerSecond: 44100, BitsPerSample: 32, NumChannels: 2, Float: float}
+		if err := calibration.WriteWAV(params); err != nil {
+			t.Fatal(err)
+		}
+		audio, err := ReadWAVFile(params.Filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := calibration.Modulate(params.SamplesPerSecond)
+		for c, channel := range audio.Channels {
+			if len(channel) != len(expected) {
+				t.Fatalf("float %t: expected %d samples in channel %d, got %d", float, len(expected), c, len(channel))
+			}
+			for n := range expected {
+				if math.Abs(channel[n]-expected[n]) > 1e-7 {
+					t.Fatalf("float %t: channel %d sample %d expected %v, got %v", float, c, n, expected[n], channel[n])
+				}
+			}
+		}
+	}
+}
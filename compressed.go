@@ -0,0 +1,142 @@
+package aprsgo
+
+// compressed.go contains routines for the course/speed, altitude and range of the compressed
+// position format and for decoding compressed position reports
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// CompressionType is the compression type byte T of a compressed position report, without the offset of 33
+type CompressionType byte
+
+// Compression type bit fields
+const (
+	CompressionCurrentFix CompressionType = 0x20 // the GPS fix is current, not old (last)
+
+	CompressionNMEAMask  CompressionType = 0x18 // the NMEA source of the position
+	CompressionNMEAOther CompressionType = 0x00
+	CompressionNMEAGLL   CompressionType = 0x08
+	CompressionNMEAGGA   CompressionType = 0x10 // the cs bytes hold the altitude
+	CompressionNMEARMC   CompressionType = 0x18
+
+	CompressionOriginMask     CompressionType = 0x07 // the origin of the compressed position
+	CompressionOriginCompress CompressionType = 0x00
+	CompressionOriginTNCBText CompressionType = 0x01
+	CompressionOriginSoftware CompressionType = 0x02
+	CompressionOriginTBD      CompressionType = 0x03
+	CompressionOriginKPC3     CompressionType = 0x04
+	CompressionOriginPico     CompressionType = 0x05
+	CompressionOriginOther    CompressionType = 0x06
+	CompressionOriginDigipeat CompressionType = 0x07
+)
+
+const (
+	compressedRangeIndicator = '{' // the c byte marking a pre-calculated radio range
+	compressedNoCourseSpeed  = ' ' // the c byte marking no course/speed, altitude or range
+	compressedLength         = 13  // /YYYYXXXX$csT
+)
+
+// symbol returns the symbol table identifier and symbol code, defaulting to a house in the primary table
+func (data PositionData) symbol() (byte, byte) {
+	symbolTable, symbol := data.SymbolTable, data.Symbol
+	if symbolTable == 0 {
+		symbolTable = '/' // primary table
+	}
+	if symbol == 0 {
+		symbol = '-' // house
+	}
+	return symbolTable, symbol
+}
+
+// compressedCourseSpeed returns the cs bytes and the compression type byte T. The course and speed
+// are sent if either is set, otherwise the altitude, otherwise the radio range.
+func (data PositionData) compressedCourseSpeed() (string, string) {
+	var c, s int
+	var compressionType CompressionType
+	switch {
+	case data.Course > 0 || data.Speed > 0:
+		c = int(math.Floor(math.Mod(data.Course, 360)/4+0.5)) % 90
+		s = clampBase91(math.Log(data.Speed+1) / math.Log(1.08))
+		compressionType = CompressionCurrentFix | CompressionNMEARMC | CompressionOriginSoftware
+	case data.Altitude >= 1:
+		cs := int(math.Floor(math.Log(data.Altitude)/math.Log(1.002) + 0.5))
+		if cs > 91*91-1 {
+			cs = 91*91 - 1
+		}
+		c, s = cs/91, cs%91
+		compressionType = CompressionCurrentFix | CompressionNMEAGGA | CompressionOriginSoftware
+	case data.Range > 0:
+		c = compressedRangeIndicator - 33
+		s = clampBase91(math.Log(data.Range/2) / math.Log(1.08))
+		compressionType = CompressionCurrentFix | CompressionNMEAOther | CompressionOriginSoftware
+	default:
+		return " s", "T" // " " indicates no information in this field "s" and "T" are just filler
+	}
+	if data.CompressionType != 0 {
+		compressionType = data.CompressionType
+	}
+	return string([]byte{byte(c + 33), byte(s + 33)}), string([]byte{byte(compressionType) + 33})
+}
+
+// clampBase91 rounds a value to a single base-91 digit from 0 to 89
+func clampBase91(value float64) int {
+	digit := int(math.Floor(value + 0.5))
+	if digit < 0 {
+		return 0
+	}
+	if digit > 89 {
+		return 89
+	}
+	return digit
+}
+
+// parseCompressedPosition parses /YYYYXXXX$csT followed by a comment
+func parseCompressedPosition(data PositionData, body string) (PositionData, error) {
+	if len(body) < compressedLength {
+		return data, errors.New("compressed position report is too short")
+	}
+	data.SymbolTable, data.Symbol = body[0], body[9]
+	if data.SymbolTable >= 'a' && data.SymbolTable <= 'j' {
+		data.SymbolTable -= 'a' - '0' // numeric overlays are sent as a-j
+	}
+	if !isSymbolTable(data.SymbolTable) {
+		return data, fmt.Errorf("invalid symbol table %q", body[0])
+	}
+
+	y, err := Base91Decode(body[1:5])
+	if err != nil {
+		return data, err
+	}
+	x, err := Base91Decode(body[5:9])
+	if err != nil {
+		return data, err
+	}
+	data.Latitude = 90 - float64(y)/380926
+	data.Longitude = -180 + float64(x)/190463
+	if data.Latitude < -90 || data.Longitude > 180 {
+		return data, fmt.Errorf("compressed position %v, %v out of range", data.Latitude, data.Longitude)
+	}
+
+	c, s, t := body[10], body[11], body[12]
+	data.Comment = body[compressedLength:]
+	if c == compressedNoCourseSpeed {
+		return data, nil // the cs and T bytes carry no information
+	}
+	if c < 33 || c > compressedRangeIndicator || s < 33 || s > 33+90 || t < 33 || t > 33+63 {
+		return data, fmt.Errorf("malformed compressed extension %q", body[10:13])
+	}
+	data.CompressionType = CompressionType(t - 33)
+	switch {
+	case c == compressedRangeIndicator:
+		data.Range = 2 * math.Pow(1.08, float64(s-33))
+	case data.CompressionType&CompressionNMEAMask == CompressionNMEAGGA:
+		data.Altitude = math.Pow(1.002, float64(int(c-33)*91+int(s-33)))
+	default:
+		data.Course = float64(c-33) * 4
+		data.Speed = math.Pow(1.08, float64(s-33)) - 1
+	}
+	return data, nil
+}
@@ -0,0 +1,97 @@
+package aprsgo
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseCompressedPosition(t *testing.T) {
+	testCases := []struct {
+		In   string
+		Want PositionData
+	}{ // examples from the APRS specification
+		{In: "=/5L!!<*e7>7P[",
+			Want: PositionData{Latitude: 49.5, Longitude: -72.75, SymbolTable: '/', Symbol: '>', Course: 88, Speed: 36.2,
+				CompressionType: CompressionCurrentFix | CompressionNMEARMC | CompressionOriginSoftware},
+		},
+		{In: "!/5L!!<*e7OS]S",
+			Want: PositionData{Latitude: 49.5, Longitude: -72.75, SymbolTable: '/', Symbol: 'O', Altitude: 10004,
+				CompressionType: CompressionCurrentFix | CompressionNMEAGGA | CompressionOriginSoftware},
+		},
+		{In: "!\\5L!!<*e7>{?!Comment",
+			Want: PositionData{Latitude: 49.5, Longitude: -72.75, SymbolTable: '\\', Symbol: '>', Range: 20.1, Comment: "Comment"},
+		},
+		{In: "@092345zc5L!!<*e7- sT",
+			Want: PositionData{Latitude: 49.5, Longitude: -72.75, SymbolTable: '2', Symbol: '-'},
+		},
+	}
+
+	for _, testCase := range testCases {
+		got, err := ParsePositionInformationField([]byte(testCase.In))
+		if err != nil {
+			t.Errorf("Parsing %s failed with error %v", testCase.In, err)
+			continue
+		}
+		want := testCase.Want
+		if math.Abs(got.Latitude-want.Latitude) > 1e-5 || math.Abs(got.Longitude-want.Longitude) > 1e-5 {
+			t.Errorf("Parsing %s expected position %v, %v got %v, %v", testCase.In, want.Latitude, want.Longitude, got.Latitude, got.Longitude)
+		}
+		if got.SymbolTable != want.SymbolTable || got.Symbol != want.Symbol || got.Comment != want.Comment {
+			t.Errorf("Parsing %s expected symbol %c%c comment %q got %c%c %q", testCase.In,
+				want.SymbolTable, want.Symbol, want.Comment, got.SymbolTable, got.Symbol, got.Comment)
+		}
+		if got.Course != want.Course || math.Abs(got.Speed-want.Speed) > 0.05 ||
+			math.Abs(got.Altitude-want.Altitude) > 1 || math.Abs(got.Range-want.Range) > 0.05 {
+			t.Errorf("Parsing %s expected course %v speed %v altitude %v range %v got %v %v %v %v", testCase.In,
+				want.Course, want.Speed, want.Altitude, want.Range, got.Course, got.Speed, got.Altitude, got.Range)
+		}
+		if want.CompressionType != 0 && got.CompressionType != want.CompressionType {
+			t.Errorf("Parsing %s expected compression type %06b got %06b", testCase.In, want.CompressionType, got.CompressionType)
+		}
+	}
+
+	for _, bad := range []string{"!/5L!!<*e7>7P", "!x5L!!<*e7>7P[", "!/5L! <*e7>7P[", "!/5L!!<*e7>\x7f"} {
+		if _, err := ParsePositionInformationField([]byte(bad)); err == nil {
+			t.Errorf("Parsing %q expected to fail, but didn't", bad)
+		}
+	}
+}
+
+func TestCompressedRoundTrip(t *testing.T) {
+	testCases := []PositionData{
+		{Latitude: 41.7147, Longitude: -72.7272, Comment: "Test"},
+		{Latitude: -33.8568, Longitude: 151.2153, SymbolTable: '\\', Symbol: 'k', Course: 271, Speed: 55},
+		{Latitude: 89.9999, Longitude: 179.9999, SymbolTable: '/', Symbol: 'O', Altitude: 98000},
+		{Latitude: -89.9999, Longitude: -179.9999, SymbolTable: '7', Symbol: '#', Range: 50},
+		{Latitude: 0, Longitude: 0, Course: 360, Speed: 0.5},
+	}
+
+	for _, report := range testCases {
+		info := report.CalculateCompressedInformationField()
+		got, err := ParsePositionInformationField(info)
+		if err != nil {
+			t.Errorf("Parsing %s failed with error %v", info, err)
+			continue
+		}
+		// the compressed position resolution is 1/380926 degree of latitude and 1/190463 of longitude
+		if math.Abs(got.Latitude-report.Latitude) > 1.0/380926 || math.Abs(got.Longitude-report.Longitude) > 1.0/190463 {
+			t.Errorf("Round trip of %s expected %v, %v got %v, %v", info, report.Latitude, report.Longitude, got.Latitude, got.Longitude)
+		}
+		symbolTable, symbol := report.symbol()
+		if got.SymbolTable != symbolTable || got.Symbol != symbol || got.Comment != report.Comment {
+			t.Errorf("Round trip of %s expected symbol %c%c got %c%c", info, symbolTable, symbol, got.SymbolTable, got.Symbol)
+		}
+		if math.Abs(math.Mod(got.Course-report.Course, 360)) > 2 {
+			t.Errorf("Round trip of %s expected course %v got %v", info, report.Course, got.Course)
+		}
+		if math.Abs(got.Speed-report.Speed) > 0.04*(report.Speed+1) {
+			t.Errorf("Round trip of %s expected speed %v got %v", info, report.Speed, got.Speed)
+		}
+		if math.Abs(got.Altitude-report.Altitude) > 0.001*report.Altitude {
+			t.Errorf("Round trip of %s expected altitude %v got %v", info, report.Altitude, got.Altitude)
+		}
+		if math.Abs(got.Range-report.Range) > 0.04*report.Range {
+			t.Errorf("Round trip of %s expected range %v got %v", info, report.Range, got.Range)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package aprsgo
+
+// csma.go contains channel access with Carrier Sense Multiple Access: queued frames wait for the
+// Data Carrier Detect to report a clear channel, then go out in a free slot with the KISS
+// p-persistence chance, keyed up with the PTT and sent with TXDELAY flags before the frames and
+// TXTAIL flags after
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// ChannelAccess holds the KISS channel access parameters
+type ChannelAccess struct {
+	TXDelay     time.Duration // flags sent before the first frame while the transmitter settles
+	Persistence byte          // P, a free slot is used with the chance (P+1)/256
+	SlotTime    time.Duration // the wait between attempts to use the channel
+	TXTail      time.Duration // flags sent after the last frame before the transmitter is unkeyed
+	FullDuplex  bool          // transmit as soon as frames are queued, ignoring the carrier
+}
+
+// DefaultChannelAccess holds the usual KISS defaults for a 1200 baud channel
+var DefaultChannelAccess = ChannelAccess{
+	TXDelay:     300 * time.Millisecond,
+	Persistence: 63,
+	SlotTime:    100 * time.Millisecond,
+	TXTail:      30 * time.Millisecond,
+}
+
+// KISSChannelAccess converts KISS parameter bytes, with TXDELAY, slot time and TXTAIL in units of 10 ms
+func KISSChannelAccess(txDelay, persistence, slotTime, txTail byte, fullDuplex bool) ChannelAccess {
+	const unit = 10 * time.Millisecond
+	return ChannelAccess{
+		TXDelay:     time.Duration(txDelay) * unit,
+		Persistence: persistence,
+		SlotTime:    time.Duration(slotTime) * unit,
+		TXTail:      time.Duration(txTail) * unit,
+		FullDuplex:  fullDuplex,
+	}
+}
+
+// flags returns the number of flags that fill duration at the symbol rate, rounded up
+func flags(duration time.Duration) int {
+	bits := (duration*time.Duration(symbolRate) + time.Second - 1) / time.Second
+	return int((bits + 7) / 8)
+}
+
+// Clock provides the time, a fake clock lets channel access be driven step by step
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time                         { return time.Now() }
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// SystemClock is the wall clock
+var SystemClock Clock = systemClock{}
+
+// AudioSink plays transmitted audio samples between -1.0 and 1.0, returning once they are played
+// so the transmitter can be unkeyed
+type AudioSink interface {
+	WriteSamples(samples []float64) error
+}
+
+// ChannelController queues frames and modulates them when channel access allows
+type ChannelController struct {
+	access           ChannelAccess
+	carrier          CarrierSense
+	sink             AudioSink
+	ptt              PTT
+	samplesPerSecond uint32
+	clock            Clock
+	random           *rand.Rand
+
+	mu           sync.Mutex
+	queue        []AX25Data
+	nextAttempt  time.Time // the start of the next slot
+	transmitting time.Time // the end of the audio last written to the sink
+}
+
+// NewChannelController returns a controller keying ptt and writing audio at samplesPerSecond to sink,
+// deferring to the carrier unless the access is full duplex, a nil carrier is never busy and a nil
+// ptt is VOX
+func NewChannelController(access ChannelAccess, carrier CarrierSense, sink AudioSink, ptt PTT, samplesPerSecond uint32, clock Clock) *ChannelController {
+	if ptt == nil {
+		ptt = VOX{}
+	}
+	return &ChannelController{
+		access:           access,
+		carrier:          carrier,
+		sink:             sink,
+		ptt:              ptt,
+		samplesPerSecond: samplesPerSecond,
+		clock:            clock,
+		random:           rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// Queue adds frames to send in the next transmission
+func (c *ChannelController) Queue(frames ...AX25Data) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.queue = append(c.queue, frames...)
+}
+
+// Queued returns the number of frames waiting to be sent
+func (c *ChannelController) Queued() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.queue)
+}
+
+// Transmitting returns whether the audio of the last transmission is still playing
+func (c *ChannelController) Transmitting() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.clock.Now().Before(c.transmitting)
+}
+
+// Poll attempts to access the channel if a slot has started, sending every queued frame in one
+// transmission when the channel is clear and the persistence draw allows, and returns whether it transmitted.
+// The transmitter is keyed before the TXDELAY flags and unkeyed once the sink has played the TXTAIL flags,
+// the controller is not locked while the audio plays so frames can be queued for the next transmission.
+func (c *ChannelController) Poll() (bool, error) {
+	c.mu.Lock()
+	now := c.clock.Now()
+	if len(c.queue) == 0 || now.Before(c.nextAttempt) || now.Before(c.transmitting) {
+		c.mu.Unlock()
+		return false, nil
+	}
+	if !c.access.FullDuplex {
+		if (c.carrier != nil && c.carrier.Busy()) || byte(c.random.Intn(256)) > c.access.Persistence {
+			c.nextAttempt = now.Add(c.access.SlotTime)
+			c.mu.Unlock()
+			return false, nil
+		}
+	}
+	frames := c.queue
+	samples := EncodeBurst(frames, flags(c.access.TXDelay), flags(c.access.TXTail)).Modulate(c.samplesPerSecond)
+	c.queue = nil
+	c.transmitting = now.Add(time.Duration(len(samples)) * time.Second / time.Duration(c.samplesPerSecond))
+	c.mu.Unlock()
+
+	if err := c.ptt.Key(); err != nil {
+		c.mu.Lock()
+		c.queue = append(frames, c.queue...) // keep the frames for the next attempt
+		c.transmitting = time.Time{}
+		c.mu.Unlock()
+		return false, fmt.Errorf("keying the transmitter: %v", err)
+	}
+	err := c.sink.WriteSamples(samples)
+	if unkeyErr := c.ptt.Unkey(); err == nil && unkeyErr != nil {
+		err = fmt.Errorf("unkeying the transmitter: %v", unkeyErr)
+	}
+	return true, err
+}
+
+// Run polls at every slot time until done is closed or the sink fails
+func (c *ChannelController) Run(done <-chan struct{}) error {
+	for {
+		if _, err := c.Poll(); err != nil {
+			return err
+		}
+		select {
+		case <-done:
+			return nil
+		case <-c.clock.After(c.access.SlotTime):
+		}
+	}
+}
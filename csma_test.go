@@ -0,0 +1,362 @@
+package aprsgo
+
+import (
+	"errors"
+	"math/rand"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock only moves when advanced
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []fakeTimer
+}
+
+type fakeTimer struct {
+	at time.Time
+	c  chan time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	timer := fakeTimer{at: c.now.Add(d), c: make(chan time.Time, 1)}
+	c.waiters = append(c.waiters, timer)
+	return timer.c
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	waiting := c.waiters[:0]
+	for _, timer := range c.waiters {
+		if timer.at.After(c.now) {
+			waiting = append(waiting, timer)
+		} else {
+			timer.c <- c.now
+		}
+	}
+	c.waiters = waiting
+}
+
+// simulatedChannel mixes the audio written by every station at the time on the clock
+type simulatedChannel struct {
+	clock            *fakeClock
+	samplesPerSecond uint32
+	start            time.Time
+	audio            []float64
+	transmissions    []time.Time
+}
+
+func newSimulatedChannel(clock *fakeClock, samplesPerSecond uint32) *simulatedChannel {
+	return &simulatedChannel{clock: clock, samplesPerSecond: samplesPerSecond, start: clock.Now()}
+}
+
+func (s *simulatedChannel) sample(at time.Time) int {
+	return int(at.Sub(s.start) * time.Duration(s.samplesPerSecond) / time.Second)
+}
+
+func (s *simulatedChannel) WriteSamples(samples []float64) error {
+	now := s.clock.Now()
+	s.transmissions = append(s.transmissions, now)
+	offset := s.sample(now)
+	for len(s.audio) < offset+len(samples) {
+		s.audio = append(s.audio, 0)
+	}
+	for i, sample := range samples {
+		s.audio[offset+i] += sample
+	}
+	return nil
+}
+
+// listen returns the audio on the channel between two times, silence where nothing was sent
+func (s *simulatedChannel) listen(from, to time.Time) []float64 {
+	heard := make([]float64, s.sample(to)-s.sample(from))
+	for i := range heard {
+		if n := s.sample(from) + i; n < len(s.audio) {
+			heard[i] = s.audio[n]
+		}
+	}
+	return heard
+}
+
+// discardSink counts transmissions without keeping the audio
+type discardSink struct {
+	transmissions int
+}
+
+func (d *discardSink) WriteSamples(samples []float64) error {
+	d.transmissions++
+	return nil
+}
+
+// recordingPTT logs keying and the audio written in order
+type recordingPTT struct {
+	events  []string
+	failKey bool
+}
+
+func (r *recordingPTT) Key() error {
+	if r.failKey {
+		return errors.New("no radio")
+	}
+	r.events = append(r.events, "key")
+	return nil
+}
+func (r *recordingPTT) Unkey() error { r.events = append(r.events, "unkey"); return nil }
+func (r *recordingPTT) Close() error { return nil }
+func (r *recordingPTT) WriteSamples(samples []float64) error {
+	r.events = append(r.events, "audio")
+	return nil
+}
+
+// blockingSink plays audio until released by the test
+type blockingSink struct {
+	playing chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingSink) WriteSamples(samples []float64) error {
+	b.playing <- struct{}{}
+	<-b.release
+	return nil
+}
+
+// busyCarrier is a carrier sense set by the test
+type busyCarrier bool
+
+func (b busyCarrier) Busy() bool { return bool(b) }
+
+func TestKISSChannelAccess(t *testing.T) {
+	access := KISSChannelAccess(30, 63, 10, 3, false)
+	if access != DefaultChannelAccess {
+		t.Errorf("expected %+v, got %+v", DefaultChannelAccess, access)
+	}
+}
+
+func TestFlags(t *testing.T) {
+	tests := []struct {
+		duration time.Duration
+		flags    int
+	}{
+		{0, 0},
+		{time.Millisecond, 1},
+		{300 * time.Millisecond, 45},
+		{30 * time.Millisecond, 5},
+		{31 * time.Millisecond, 5},
+		{34 * time.Millisecond, 6},
+	}
+	for _, test := range tests {
+		if n := flags(test.duration); n != test.flags {
+			t.Errorf("%v: expected %d flags, got %d", test.duration, test.flags, n)
+		}
+	}
+}
+
+func TestEncodeBurst(t *testing.T) {
+	first, second := testAX25Data(t, "Test"), testAX25Data(t, "}}} stuffing }}}")
+	symbolStream := EncodeBurst([]AX25Data{first, second}, 10, 3)
+	expected := 8 * (10 + 1 + 3)
+	for _, ax25data := range []AX25Data{first, second} {
+		expected += len(stuffBits(ax25data)) - 16
+	}
+	if len(symbolStream) != expected {
+		t.Errorf("expected %d symbols, got %d", expected, len(symbolStream))
+	}
+	decoded := symbolStream.Decode()
+	if len(decoded) != 2 || string(decoded[0]) != string(first) || string(decoded[1]) != string(second) {
+		t.Errorf("expected both frames decoded, got %d", len(decoded))
+	}
+}
+
+func TestChannelControllerPersistence(t *testing.T) {
+	tests := []struct {
+		persistence byte
+		firstSlot   float64 // the expected fraction sent in the first slot
+	}{
+		{255, 1},
+		{127, 0.5},
+		{63, 0.25},
+		{0, 1.0 / 256},
+	}
+	const trials = 2000
+	for _, test := range tests {
+		clock, sink := newFakeClock(), &discardSink{}
+		access := ChannelAccess{Persistence: test.persistence, SlotTime: 100 * time.Millisecond}
+		c := NewChannelController(access, nil, sink, nil, 9600, clock)
+		c.random = rand.New(rand.NewSource(1))
+		first := 0
+		for i := 0; i < trials; i++ {
+			c.Queue(AX25Data{0x01})
+			for slot := 0; ; slot++ {
+				sent, err := c.Poll()
+				if err != nil {
+					t.Fatalf("Poll failed: %v", err)
+				}
+				if sent {
+					if slot == 0 {
+						first++
+					}
+					break
+				}
+				clock.Advance(access.SlotTime)
+			}
+			for c.Transmitting() {
+				clock.Advance(access.SlotTime)
+			}
+		}
+		fraction := float64(first) / trials
+		if fraction < test.firstSlot-0.05 || fraction > test.firstSlot+0.05 {
+			t.Errorf("persistence %d: expected %.3f sent in the first slot, got %.3f", test.persistence, test.firstSlot, fraction)
+		}
+		if sink.transmissions != trials {
+			t.Errorf("persistence %d: expected %d transmissions, got %d", test.persistence, trials, sink.transmissions)
+		}
+	}
+}
+
+func TestChannelControllerCarrier(t *testing.T) {
+	tests := []struct {
+		name       string
+		busy       bool
+		fullDuplex bool
+		sent       bool
+	}{
+		{"clear", false, false, true},
+		{"busy", true, false, false},
+		{"busy full duplex", true, true, true},
+	}
+	for _, test := range tests {
+		clock, sink := newFakeClock(), &discardSink{}
+		access := ChannelAccess{Persistence: 255, SlotTime: 100 * time.Millisecond, FullDuplex: test.fullDuplex}
+		c := NewChannelController(access, busyCarrier(test.busy), sink, nil, 9600, clock)
+		if sent, _ := c.Poll(); sent {
+			t.Errorf("%s: sent with nothing queued", test.name)
+		}
+		c.Queue(testAX25Data(t, "Test"))
+		if sent, _ := c.Poll(); sent != test.sent {
+			t.Errorf("%s: expected sent %t, got %t", test.name, test.sent, sent)
+		}
+		if sent, _ := c.Poll(); sent {
+			t.Errorf("%s: sent twice in one slot", test.name)
+		}
+	}
+}
+
+func TestChannelControllerPTT(t *testing.T) {
+	recorder := &recordingPTT{failKey: true}
+	c := NewChannelController(ChannelAccess{Persistence: 255}, nil, recorder, recorder, 9600, newFakeClock())
+	c.Queue(testAX25Data(t, "Test"))
+	if sent, err := c.Poll(); sent || err == nil {
+		t.Errorf("expected a keying failure to stop the transmission, got sent %t error %v", sent, err)
+	}
+	if c.Queued() != 1 {
+		t.Errorf("expected the frame to stay queued")
+	}
+	recorder.failKey = false
+	if sent, err := c.Poll(); !sent || err != nil {
+		t.Errorf("expected the transmission to be sent, got sent %t error %v", sent, err)
+	}
+	if events := strings.Join(recorder.events, " "); events != "key audio unkey" {
+		t.Errorf("expected key audio unkey, got %s", events)
+	}
+}
+
+func TestChannelControllerUnlockedWhilePlaying(t *testing.T) {
+	sink := &blockingSink{playing: make(chan struct{}), release: make(chan struct{})}
+	c := NewChannelController(ChannelAccess{Persistence: 255}, nil, sink, nil, 9600, newFakeClock())
+	c.Queue(testAX25Data(t, "one"))
+	polled := make(chan bool)
+	go func() {
+		sent, _ := c.Poll()
+		polled <- sent
+	}()
+	<-sink.playing
+	if !c.Transmitting() {
+		t.Errorf("expected the controller to report transmitting while the audio plays")
+	}
+	c.Queue(testAX25Data(t, "two"))
+	if c.Queued() != 1 {
+		t.Errorf("expected the frame queued during the transmission to wait, got %d queued", c.Queued())
+	}
+	if sent, _ := c.Poll(); sent {
+		t.Errorf("expected no second transmission while the first plays")
+	}
+	close(sink.release)
+	if !<-polled {
+		t.Errorf("expected the first transmission to be sent")
+	}
+}
+
+func TestChannelControllerSimulatedChannel(t *testing.T) {
+	const samplesPerSecond = 48000
+	const tick = 10 * time.Millisecond
+	clock := newFakeClock()
+	channel := newSimulatedChannel(clock, samplesPerSecond)
+	access := DefaultChannelAccess
+	access.Persistence = 255
+
+	// the first station is deaf, the second listens to the channel
+	first := NewChannelController(access, nil, channel, nil, samplesPerSecond, clock)
+	detector := NewCarrierDetector(samplesPerSecond)
+	second := NewChannelController(access, detector, channel, nil, samplesPerSecond, clock)
+	firstFrame, secondFrame := testAX25Data(t, "first"), testAX25Data(t, "second")
+	first.Queue(firstFrame)
+	if sent, _ := first.Poll(); !sent {
+		t.Fatal("expected the first station to send on a clear channel")
+	}
+	firstEnd := clock.Now().Add(time.Duration(len(channel.audio)) * time.Second / samplesPerSecond)
+	second.Queue(secondFrame)
+
+	for i := 0; i < 300 && second.Queued() > 0; i++ {
+		now := clock.Now()
+		clock.Advance(tick)
+		detector.Process(channel.listen(now, clock.Now()))
+		if _, err := second.Poll(); err != nil {
+			t.Fatalf("Poll failed: %v", err)
+		}
+	}
+	if len(channel.transmissions) != 2 {
+		t.Fatalf("expected both stations to transmit, got %d transmissions", len(channel.transmissions))
+	}
+	if !channel.transmissions[1].After(firstEnd) {
+		t.Errorf("expected the second station to wait for the first to finish")
+	}
+
+	received := Receiver{}.Receive(channel.audio, samplesPerSecond)
+	if len(received) != 2 || string(received[0].AX25Data) != string(firstFrame) || string(received[1].AX25Data) != string(secondFrame) {
+		t.Errorf("expected both frames received without a collision, got %d", len(received))
+	}
+}
+
+func TestChannelControllerRun(t *testing.T) {
+	clock := newFakeClock()
+	channel := newSimulatedChannel(clock, 9600)
+	c := NewChannelController(ChannelAccess{Persistence: 255, SlotTime: time.Second}, nil, channel, nil, 9600, clock)
+	c.Queue(testAX25Data(t, "Test"))
+	done, finished := make(chan struct{}), make(chan error)
+	go func() { finished <- c.Run(done) }()
+	for c.Queued() > 0 {
+		time.Sleep(time.Millisecond)
+	}
+	close(done)
+	clock.Advance(time.Second)
+	if err := <-finished; err != nil {
+		t.Errorf("Run failed: %v", err)
+	}
+}
@@ -0,0 +1,93 @@
+package aprsgo
+
+// dcd.go contains the Data Carrier Detect, deciding from the receive audio whether another station
+// is transmitting AFSK1200 by the share of the audio energy at the mark and space tones
+
+import (
+	"math"
+)
+
+// CarrierSense reports whether the channel is in use by another station
+type CarrierSense interface {
+	Busy() bool
+}
+
+var (
+	dcdOnLevel    = 0.5  // the tone energy fraction a carrier is detected above
+	dcdOffLevel   = 0.3  // the tone energy fraction a carrier is lost below
+	dcdSmoothing  = 0.25 // the weight of each new symbol in the running tone energy fraction
+	dcdMinimumRMS = 0.01 // quieter audio is never a carrier, the fraction of silence is meaningless
+)
+
+// CarrierDetector is a Data Carrier Detect fed with receive audio, one symbol of samples is measured at a time
+type CarrierDetector struct {
+	samplesPerSecond uint32
+	window           []float64 // the samples of the symbol being collected
+	n                int       // the samples processed, keeping the tone phase continuous
+	level            float64   // the running tone energy fraction
+	busy             bool
+}
+
+// NewCarrierDetector returns a Data Carrier Detect for audio sampled at samplesPerSecond
+func NewCarrierDetector(samplesPerSecond uint32) *CarrierDetector {
+	return &CarrierDetector{samplesPerSecond: samplesPerSecond}
+}
+
+// Process feeds receive audio to the detector and returns whether a carrier is detected after it
+func (d *CarrierDetector) Process(samples []float64) bool {
+	size := int(d.samplesPerSecond / symbolRate)
+	for _, sample := range samples {
+		d.window = append(d.window, sample)
+		if len(d.window) < size {
+			continue
+		}
+		d.level += dcdSmoothing * (d.toneFraction() - d.level)
+		d.n += len(d.window)
+		d.window = d.window[:0]
+		if d.busy && d.level < dcdOffLevel {
+			d.busy = false
+		} else if !d.busy && d.level > dcdOnLevel {
+			d.busy = true
+		}
+	}
+	return d.busy
+}
+
+// Busy returns whether a carrier was detected in the audio processed so far
+func (d *CarrierDetector) Busy() bool {
+	return d.busy
+}
+
+// Level returns the running fraction of the audio energy at the mark and space tones, between 0.0 and 1.0
+func (d *CarrierDetector) Level() float64 {
+	return d.level
+}
+
+// toneFraction returns the share of the energy of the window at the mark and space tones, a pure
+// tone at either is 1.0 while white noise spreads its energy and gives about 2 / samples per symbol
+func (d *CarrierDetector) toneFraction() float64 {
+	var energy float64
+	for _, sample := range d.window {
+		energy += sample * sample
+	}
+	size := float64(len(d.window))
+	if math.Sqrt(energy/size) < dcdMinimumRMS {
+		return 0
+	}
+	tone := 0.0
+	for _, frequency := range []float64{markFreq, spaceFreq} {
+		increment := 2 * math.Pi * frequency / float64(d.samplesPerSecond)
+		var inPhase, quadrature float64
+		for i, sample := range d.window {
+			angle := increment * float64(d.n+i)
+			inPhase += sample * math.Cos(angle)
+			quadrature += sample * math.Sin(angle)
+		}
+		tone += inPhase*inPhase + quadrature*quadrature
+	}
+	fraction := tone / (size / 2 * energy)
+	if fraction > 1 {
+		fraction = 1
+	}
+	return fraction
+}
@@ -0,0 +1,64 @@
+package aprsgo
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestCarrierDetector(t *testing.T) {
+	const samplesPerSecond = 48000
+	afsk := testAX25Data(t, "Test").Encode().Modulate(samplesPerSecond)
+	random := rand.New(rand.NewSource(1))
+	noise := make([]float64, len(afsk))
+	noisyAFSK := make([]float64, len(afsk))
+	for i := range noise {
+		noise[i] = 0.3 * random.NormFloat64()
+		noisyAFSK[i] = afsk[i] + noise[i]
+	}
+	silence := make([]float64, samplesPerSecond/10)
+
+	tests := []struct {
+		name    string
+		samples [][]float64
+		busy    bool
+	}{
+		{"silence", [][]float64{silence}, false},
+		{"noise", [][]float64{noise}, false},
+		{"AFSK", [][]float64{afsk}, true},
+		{"noisy AFSK", [][]float64{noisyAFSK}, true},
+		{"AFSK then noise", [][]float64{afsk, noise}, false},
+		{"AFSK then silence", [][]float64{afsk, silence}, false},
+		{"noise then AFSK", [][]float64{noise, afsk}, true},
+	}
+	for _, test := range tests {
+		d := NewCarrierDetector(samplesPerSecond)
+		busy := false
+		for _, samples := range test.samples {
+			busy = d.Process(samples)
+		}
+		if busy != test.busy || d.Busy() != test.busy {
+			t.Errorf("%s: expected busy %t, got %t (level %.2f)", test.name, test.busy, busy, d.Level())
+		}
+	}
+}
+
+func TestCarrierDetectorHysteresis(t *testing.T) {
+	const samplesPerSecond = 48000
+	afsk := testAX25Data(t, "Test").Encode().Modulate(samplesPerSecond)
+	d := NewCarrierDetector(samplesPerSecond)
+	changes, busy := 0, false
+	samplesPerSymbol := samplesPerSecond / int(symbolRate)
+	for start := 0; start < len(afsk); start += samplesPerSymbol {
+		end := start + samplesPerSymbol
+		if end > len(afsk) {
+			end = len(afsk)
+		}
+		if d.Process(afsk[start:end]) != busy {
+			busy = !busy
+			changes++
+		}
+	}
+	if changes != 1 || !busy {
+		t.Errorf("expected the carrier detected once and held, got %d changes ending busy %t", changes, busy)
+	}
+}
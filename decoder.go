@@ -0,0 +1,255 @@
+package aprsgo
+
+// decoder.go handles decoding of received symbols, reversing the Non-Return to Zero Inverted (NRZI)
+// encoding and bit stuffing to recover the frames between flags
+
+import (
+	"sort"
+)
+
+// receivedFrame is a frame recovered from a symbol stream and the bit where it ended
+type receivedFrame struct {
+	ax25data AX25Data
+	end      int
+	fixed    int // the bits flipped to pass the Frame Check Sequence
+}
+
+// Decode recovers the AX.25 frames with a valid Frame Check Sequence from received symbols,
+// FX.25 frames are corrected before their Frame Check Sequence is checked
+func (symbolStream SymbolStream) Decode() []AX25Data {
+	var decoded []AX25Data
+	for _, frame := range decodeFrames(symbolStream.bits(), 0) {
+		decoded = append(decoded, frame.ax25data)
+	}
+	return decoded
+}
+
+// decodeFrames finds the FX.25 and AX.25 frames in received bits in the order they ended,
+// repairing AX.25 frames with up to fixBits bits wrong
+func decodeFrames(bits []byte, fixBits int) []receivedFrame {
+	decoder := frameDecoder{hdlc: hdlcDecoder{fixBits: fixBits}}
+	return decoder.decode(bits, true)
+}
+
+// frameDecoder finds the FX.25 and AX.25 frames in bits received a block at a time. The HDLC decoder
+// follows the FX.25 scanner by a tag so it knows the codeblocks its frames may end in, frames
+// inside a codeblock are already decoded
+type frameDecoder struct {
+	hdlc   hdlcDecoder
+	fx25   fx25Scanner
+	bits   []byte   // the bits from index base not yet passed to the HDLC decoder
+	base   int      // every frame ending before base has been returned
+	blocks [][2]int // the FX.25 codeblocks found ending after base
+}
+
+// decode adds the next received bits and returns the frames found in the order they ended, final
+// at the end of the bits returns the frames still held back
+func (d *frameDecoder) decode(bits []byte, final bool) []receivedFrame {
+	d.bits = append(d.bits, bits...)
+	frames, blocks := d.fx25.scan(d.bits, d.base, final)
+	d.blocks = append(d.blocks, blocks...)
+
+	end := d.fx25.next - 64
+	if final {
+		end = d.base + len(d.bits)
+	}
+	for i := d.base; i < end; i++ {
+		frame, ok := d.hdlc.decode(d.bits[i-d.base], i)
+		if !ok {
+			continue
+		}
+		inBlock := false
+		for _, block := range d.blocks {
+			inBlock = inBlock || (frame.end >= block[0] && frame.end < block[1])
+		}
+		if !inBlock {
+			frames = append(frames, frame)
+		}
+	}
+	if end > d.base {
+		d.bits = d.bits[:copy(d.bits, d.bits[end-d.base:])]
+		d.base = end
+	}
+	for len(d.blocks) > 0 && d.blocks[0][1] <= d.base {
+		d.blocks = d.blocks[1:]
+	}
+	sort.Slice(frames, func(i, j int) bool { return frames[i].end < frames[j].end })
+	return frames
+}
+
+// bits reverses the NRZI encoding, an unchanged symbol is a one
+func (symbolStream SymbolStream) bits() []byte {
+	bits := make([]byte, len(symbolStream))
+	previous := mark // the encoder starts from mark
+	for i, symbol := range symbolStream {
+		if symbol == previous {
+			bits[i] = one
+		} else {
+			bits[i] = zero
+		}
+		previous = symbol
+	}
+	return bits
+}
+
+// hdlcFrames finds the bit stuffed frames between flags with a valid Frame Check Sequence
+func hdlcFrames(bits []byte) []receivedFrame {
+	return hdlcScan(bits, 0)
+}
+
+// hdlcScan finds the bit stuffed frames between flags, repairing frames with up to fixBits bits wrong
+func hdlcScan(bits []byte, fixBits int) []receivedFrame {
+	var frames []receivedFrame
+	decoder := hdlcDecoder{fixBits: fixBits}
+	for i, bit := range bits {
+		if frame, ok := decoder.decode(bit, i); ok {
+			frames = append(frames, frame)
+		}
+	}
+	return frames
+}
+
+// hdlcDecoder finds the bit stuffed frames between flags a bit at a time, repairing frames with up
+// to fixBits bits wrong
+type hdlcDecoder struct {
+	fixBits   int
+	frameBits []byte
+	ones      int
+	synced    bool
+}
+
+// decode takes the bit at index i and returns the frame it ends, if any
+func (h *hdlcDecoder) decode(bit byte, i int) (receivedFrame, bool) {
+	if bit == one {
+		h.ones++
+		h.frameBits = append(h.frameBits, one)
+		if h.ones > 6 { // abort, wait for the next flag
+			h.synced = false
+		}
+		h.limit()
+		return receivedFrame{}, false
+	}
+	var frame receivedFrame
+	found := false
+	switch h.ones {
+	case 6: // a flag ends the frame, its first seven bits were already collected
+		if h.synced && len(h.frameBits) > 7 {
+			if ax25data := packBits(h.frameBits[:len(h.frameBits)-7]); ax25data != nil {
+				if _, err := ax25data.checkFCS(); err == nil {
+					frame, found = receivedFrame{ax25data: ax25data, end: i}, true
+				} else if repaired, fixed, ok := repairFCS(ax25data, h.fixBits); ok {
+					frame, found = receivedFrame{ax25data: repaired, end: i, fixed: fixed}, true
+				}
+			}
+		}
+		h.synced, h.frameBits = true, h.frameBits[:0]
+	case 5: // a stuffed zero is dropped
+	default:
+		h.frameBits = append(h.frameBits, zero)
+	}
+	h.ones = 0
+	h.limit()
+	return frame, found
+}
+
+var maxFrameLength = 4096 // bytes between flags, longer runs are noise and are dropped
+
+// limit drops the collected bits while out of sync, they are never decoded, so the bits held
+// do not grow through silence or noise
+func (h *hdlcDecoder) limit() {
+	if len(h.frameBits) > 8*maxFrameLength+7 {
+		h.synced = false
+	}
+	if !h.synced {
+		h.frameBits = h.frameBits[:0]
+	}
+}
+
+// packBits packs bits least significant first into bytes, returning nil if there are left over bits
+func packBits(bits []byte) AX25Data {
+	if len(bits)%8 != 0 {
+		return nil
+	}
+	data := make(AX25Data, len(bits)/8)
+	for i, bit := range bits {
+		data[i/8] |= bit << uint(i%8)
+	}
+	return data
+}
+
+// Repairs of frames failing their Frame Check Sequence, the FixBits of a Receiver
+const (
+	FixNone         = 0 // only intact frames are received
+	FixOneBit       = 1 // any one bit is flipped
+	FixAdjacentBits = 2 // any one bit or two adjacent bits are flipped, the two bits a wrong symbol changes after NRZI
+)
+
+var maxRepairLength = 330 // longer frames are not repaired, the chance of a false repair grows with the length
+
+// repairFCS flips one or, with FixAdjacentBits, two adjacent bits of a frame failing its Frame Check Sequence
+// until it passes and still looks like a frame, returning the repaired copy and the number of bits flipped.
+// Two bits apart are never flipped: a frame has more pairs of bits than the 16 bit check has values, so
+// the check cannot tell the true pair from the false ones.
+func repairFCS(ax25data AX25Data, fixBits int) (AX25Data, int, bool) {
+	n := len(ax25data)
+	if fixBits < FixOneBit || n < 2*addressFieldSize+1+2 || n > maxRepairLength {
+		return nil, 0, false
+	}
+	// the CRC is linear: flipping a bit changes the residual by a fixed amount for its position
+	residual := calcCRC(ax25data[:n-2]) ^ (uint16(ax25data[n-2]) | uint16(ax25data[n-1])<<8)
+	deltas := make([]uint16, 8*n)
+	delta := uint16(0)
+	for i := 8*(n-2) - 1; i >= 0; i-- { // a one followed by zeros, from the last data bit back
+		if delta == 0 {
+			delta = 0x8408
+		} else if delta&1 != 0 {
+			delta = delta>>1 ^ 0x8408
+		} else {
+			delta >>= 1
+		}
+		deltas[i] = delta
+	}
+	for i := 0; i < 16; i++ { // bits of the Frame Check Sequence itself
+		deltas[8*(n-2)+i] = 1 << uint(i)
+	}
+
+	flip := func(positions ...int) (AX25Data, bool) {
+		repaired := append(AX25Data{}, ax25data...)
+		for _, i := range positions {
+			repaired[i/8] ^= 1 << uint(i%8)
+		}
+		return repaired, plausibleFrame(repaired)
+	}
+	for i, delta := range deltas {
+		if delta == residual {
+			if repaired, ok := flip(i); ok {
+				return repaired, 1, true
+			}
+		}
+	}
+	if fixBits < FixAdjacentBits {
+		return nil, 0, false
+	}
+	for i := 1; i < len(deltas); i++ {
+		if deltas[i-1]^deltas[i] == residual {
+			if repaired, ok := flip(i-1, i); ok {
+				return repaired, 2, true
+			}
+		}
+	}
+	return nil, 0, false
+}
+
+// plausibleFrame checks a repaired frame decodes with valid callsigns, rejecting most false repairs
+func plausibleFrame(ax25data AX25Data) bool {
+	frame, err := DecodeFrame(ax25data)
+	if err != nil {
+		return false
+	}
+	for _, address := range append([]Address{frame.Destination, frame.Source}, frame.Path...) {
+		if address.Callsign == "" || validateCallsignCharacters(address.Callsign) != nil {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,41 @@
+package aprsgo
+
+import (
+	"bytes"
+	"testing"
+)
+
+func testAX25Data(t *testing.T, comment string) AX25Data {
+	report := PositionData{Callsign: "W1AW", Latitude: 41.7147, Longitude: -72.7272, Comment: comment}
+	ax25data, err := report.BasicAPRSReport()
+	if err != nil {
+		t.Fatalf("Report failed validation: %v", err)
+	}
+	return ax25data
+}
+
+func TestDecodeSymbolStream(t *testing.T) {
+	first, second := testAX25Data(t, "Test"), testAX25Data(t, "}}} stuffing }}}")
+	symbolStream := append(first.Encode(), second.Encode()...)
+
+	decoded := symbolStream.Decode()
+	if len(decoded) != 2 || !bytes.Equal(decoded[0], first) || !bytes.Equal(decoded[1], second) {
+		t.Fatalf("Expected both frames got %q", decoded)
+	}
+
+	symbolStream[len(first.Encode())/2] = !symbolStream[len(first.Encode())/2]
+	decoded = symbolStream.Decode()
+	if len(decoded) != 1 || !bytes.Equal(decoded[0], second) {
+		t.Errorf("Expected only the uncorrupted frame got %q", decoded)
+	}
+}
+
+func TestPackBits(t *testing.T) {
+	data := []byte{0x7e, 0x01, 0xa5}
+	if packed := packBits(unpackBits(data)); !bytes.Equal(packed, data) {
+		t.Errorf("Expected % x got % x", data, packed)
+	}
+	if packBits([]byte{1, 0, 1}) != nil {
+		t.Errorf("Left over bits should not be packed")
+	}
+}
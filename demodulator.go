@@ -0,0 +1,391 @@
+package aprsgo
+
+// demodulator.go contains the AFSK1200 demodulator, comparing the mark and space tone energy
+// over a sliding window and recovering the symbol clock from the transitions, and the receiver
+// that runs a bank of demodulator variants over the same audio
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+)
+
+// Demodulator is a variant of the AFSK1200 demodulator
+type Demodulator struct {
+	Name         string
+	FilterWidth  float64 // the tone detection window in symbols, narrower windows follow faster transitions
+	SpaceGain    float64 // the space tone energy is scaled by this to balance twist, 1.0 for none
+	SampleOffset float64 // the fraction of a symbol the sampling point is moved from the middle of the symbol
+}
+
+var clockRecoveryGain = 0.3 // the fraction of the timing error corrected at each transition
+
+// DemodulatorBank returns a demodulator for every combination of filter width, space gain and sample offset
+func DemodulatorBank(filterWidths, spaceGains, sampleOffsets []float64) []Demodulator {
+	var bank []Demodulator
+	for _, width := range filterWidths {
+		for _, gain := range spaceGains {
+			for _, offset := range sampleOffsets {
+				name := fmt.Sprintf("width %.2f gain %.2f offset %+.2f", width, gain, offset)
+				bank = append(bank, Demodulator{Name: name, FilterWidth: width, SpaceGain: gain, SampleOffset: offset})
+			}
+		}
+	}
+	return bank
+}
+
+// DefaultDemodulators covers typical twist from pre-emphasis and de-emphasis either way
+var DefaultDemodulators = DemodulatorBank([]float64{0.8, 1.0}, []float64{0.5, 1.0, 2.0}, []float64{0, 0.2})
+
+// Demodulate returns the symbols received in the audio samples, none below one sample per symbol
+func (d Demodulator) Demodulate(samples []float64, samplesPerSecond uint32) SymbolStream {
+	if samplesPerSecond < symbolRate {
+		return nil
+	}
+	mixer := newToneMixer(samplesPerSecond, 0)
+	mixer.mix(samples)
+	state := newDemodulatorState(d, samplesPerSecond)
+	return state.demodulate(mixer, nil)
+}
+
+// toneMixer mixes audio a block at a time with the mark and space tones, once for every demodulator
+// of a receiver. The running sums of the samples multiplied by the in phase and quadrature tones
+// are kept, the sums over any window are the difference of two entries, and the last entries of
+// the previous block are carried over so windows reach back across blocks
+type toneMixer struct {
+	samplesPerSecond uint32
+	keep             int          // the samples kept from the previous block, the widest window
+	n                int          // the position in the second of audio being mixed, keeping the tone phase continuous
+	sums             [4][]float64 // mark in phase, mark quadrature, space in phase and space quadrature
+	block            int          // the entry of the running sums before the first sample of the block
+}
+
+func newToneMixer(samplesPerSecond uint32, keep int) *toneMixer {
+	return &toneMixer{samplesPerSecond: samplesPerSecond, keep: keep}
+}
+
+// mix replaces the block mixed with samples
+func (m *toneMixer) mix(samples []float64) {
+	for k, sums := range m.sums {
+		if len(sums) > m.keep+1 {
+			sums = sums[:copy(sums, sums[len(sums)-m.keep-1:])]
+		}
+		if len(sums) == 0 {
+			sums = append(sums, 0)
+		}
+		for j := len(sums) - 1; j >= 0; j-- { // rebased so the sums do not grow with the audio
+			sums[j] -= sums[0]
+		}
+		m.sums[k] = sums
+	}
+	m.block = len(m.sums[0]) - 1
+	for _, sample := range samples {
+		for k, frequency := range []float64{markFreq, spaceFreq} {
+			// the tones are whole Hertz so their phase repeats every second
+			angle := 2 * math.Pi * frequency * float64(m.n) / float64(m.samplesPerSecond)
+			inPhase, quadrature := m.sums[2*k], m.sums[2*k+1]
+			m.sums[2*k] = append(inPhase, inPhase[len(inPhase)-1]+sample*math.Cos(angle))
+			m.sums[2*k+1] = append(quadrature, quadrature[len(quadrature)-1]+sample*math.Sin(angle))
+		}
+		m.n = (m.n + 1) % int(m.samplesPerSecond)
+	}
+}
+
+// demodulatorState is the clock and symbol decisions of a demodulator carried between blocks
+type demodulatorState struct {
+	Demodulator
+	samplesPerSymbol float64
+	window           int
+	target           float64 // the clock phase transitions are expected at
+	phase            float64
+	previous         bool         // the tone decided for the last sample
+	symbol           Symbol       // the last symbol, reversing the NRZI encoding across blocks
+	symbolStream     SymbolStream // reused for the symbols of each block
+	bits             []byte       // reused for the bits of each block
+	n                int          // the index of the next sample demodulated
+	taken            []int        // the sample each symbol from symbol first was taken at
+	first            int
+}
+
+func newDemodulatorState(d Demodulator, samplesPerSecond uint32) *demodulatorState {
+	samplesPerSymbol := float64(samplesPerSecond) / float64(symbolRate)
+	window := int(d.FilterWidth*samplesPerSymbol + 0.5)
+	if window < 1 {
+		window = 1
+	}
+	// the clock phase runs from 0 to 1 over a symbol, transitions are expected half way and the symbol
+	// is sampled as the phase wraps
+	target := math.Mod(0.5+d.SampleOffset+1, 1)
+	return &demodulatorState{Demodulator: d, samplesPerSymbol: samplesPerSymbol, window: window, target: target, previous: true, symbol: mark}
+}
+
+// demodulate appends the symbols received in the block last mixed to symbolStream
+func (d *demodulatorState) demodulate(m *toneMixer, symbolStream SymbolStream) SymbolStream {
+	markI, markQ, spaceI, spaceQ := m.sums[0], m.sums[1], m.sums[2], m.sums[3]
+	isMark := func(n int) bool { // compares the tone energy over the window ending at entry n
+		start := n - d.window
+		if start < 0 {
+			start = 0
+		}
+		markI, markQ := markI[n]-markI[start], markQ[n]-markQ[start]
+		spaceI, spaceQ := spaceI[n]-spaceI[start], spaceQ[n]-spaceQ[start]
+		return markI*markI+markQ*markQ > d.SpaceGain*(spaceI*spaceI+spaceQ*spaceQ)
+	}
+
+	for n := m.block + 1; n < len(markI); n++ {
+		current := isMark(n)
+		if current != d.previous {
+			phaseError := d.target - d.phase
+			if phaseError > 0.5 {
+				phaseError--
+			} else if phaseError < -0.5 {
+				phaseError++
+			}
+			d.phase += clockRecoveryGain * phaseError
+		}
+		d.previous = current
+		d.phase += 1 / d.samplesPerSymbol
+		for d.phase >= 1 {
+			d.phase--
+			d.taken = append(d.taken, d.n)
+			if current {
+				symbolStream = append(symbolStream, mark)
+			} else {
+				symbolStream = append(symbolStream, space)
+			}
+		}
+		if d.phase < 0 {
+			d.phase++
+		}
+		d.n++
+	}
+	return symbolStream
+}
+
+// sample returns the sample the symbol at index i was taken at, before any symbol still held the
+// samples demodulated
+func (d *demodulatorState) sample(i int) int {
+	if i-d.first < len(d.taken) {
+		return d.taken[i-d.first]
+	}
+	return d.n
+}
+
+// release drops the samples symbols before index i were taken at
+func (d *demodulatorState) release(i int) {
+	if i > d.first {
+		d.taken = d.taken[:copy(d.taken, d.taken[i-d.first:])]
+		d.first = i
+	}
+}
+
+// demodulateBits returns the bits received in the block last mixed, reversing the NRZI encoding
+// across blocks. The returned bits are reused by the next block
+func (d *demodulatorState) demodulateBits(m *toneMixer) []byte {
+	d.symbolStream = d.demodulate(m, d.symbolStream[:0])
+	d.bits = d.bits[:0]
+	for _, symbol := range d.symbolStream {
+		if symbol == d.symbol {
+			d.bits = append(d.bits, one)
+		} else {
+			d.bits = append(d.bits, zero)
+		}
+		d.symbol = symbol
+	}
+	return d.bits
+}
+
+// Receiver decodes frames from audio with a bank of demodulators
+type Receiver struct {
+	Demodulators []Demodulator // DefaultDemodulators if empty
+	FixBits      int           // FixNone, FixOneBit or FixAdjacentBits to repair a frame failing its Frame Check Sequence
+}
+
+// ReceivedPacket is a frame decoded by one or more demodulators of a Receiver
+type ReceivedPacket struct {
+	AX25Data  AX25Data
+	Sample    int      // the sample where the frame ended
+	Decoders  []string // the names of the demodulators that decoded the frame, intact decodes first
+	FixedBits int      // the fewest bits flipped by any decoder to repair the frame, 0 if received intact
+	fixedBits []int    // the bits flipped by each decoder
+	decoders  []int    // the index of each decoder in the bank
+}
+
+var duplicateWindow = 64 // symbols apart the same frame from different demodulators is one packet
+
+var receiveBlockSize = 4096 // samples Receive passes to a ReceiverStream at a time
+
+// Receive runs every demodulator over the audio in parallel and returns each distinct frame once,
+// in the order received
+func (r Receiver) Receive(samples []float64, samplesPerSecond uint32) []ReceivedPacket {
+	stream := NewReceiverStream(r, samplesPerSecond)
+	var received []ReceivedPacket
+	for start := 0; start < len(samples); start += receiveBlockSize {
+		end := start + receiveBlockSize
+		if end > len(samples) {
+			end = len(samples)
+		}
+		received = append(received, stream.Process(samples[start:end])...)
+	}
+	return append(received, stream.Flush()...)
+}
+
+// ReceiverStream is a Receiver fed with audio a block at a time. The tones are mixed once for every
+// demodulator and each demodulator's window, clock and frame state carry over between blocks, so
+// the memory used does not grow with the length of the audio
+type ReceiverStream struct {
+	samplesPerSecond uint32
+	mixer            *toneMixer
+	states           []*demodulatorState
+	decoders         []frameDecoder
+	results          [][]receivedFrame
+	packets          []*ReceivedPacket // the packets a duplicate may still be decoded for
+}
+
+// NewReceiverStream returns a stream of the receiver for audio sampled at samplesPerSecond,
+// nothing is received below one sample per symbol
+func NewReceiverStream(r Receiver, samplesPerSecond uint32) *ReceiverStream {
+	demodulators := r.Demodulators
+	if len(demodulators) == 0 {
+		demodulators = DefaultDemodulators
+	}
+	s := &ReceiverStream{samplesPerSecond: samplesPerSecond}
+	if samplesPerSecond < symbolRate {
+		return s
+	}
+	keep := 0
+	for _, demodulator := range demodulators {
+		state := newDemodulatorState(demodulator, samplesPerSecond)
+		if state.window > keep {
+			keep = state.window
+		}
+		s.states = append(s.states, state)
+		s.decoders = append(s.decoders, frameDecoder{hdlc: hdlcDecoder{fixBits: r.FixBits}})
+	}
+	s.mixer = newToneMixer(samplesPerSecond, keep)
+	s.results = make([][]receivedFrame, len(demodulators))
+	return s
+}
+
+// Process runs every demodulator over the next block of audio in parallel and returns the packets
+// no demodulator can decode a duplicate of any more, in the order received
+func (s *ReceiverStream) Process(samples []float64) []ReceivedPacket {
+	if len(s.states) == 0 {
+		return nil
+	}
+	s.mixer.mix(samples)
+	s.decode(false)
+	// packets a window before the slowest decoder can have no more duplicates
+	return s.complete(s.decoded() - s.duplicateWindow() - 1)
+}
+
+// Flush returns the packets still held at the end of the audio, in the order received
+func (s *ReceiverStream) Flush() []ReceivedPacket {
+	if len(s.states) == 0 {
+		return nil
+	}
+	s.decode(true)
+	return s.complete(s.decoded() + 1)
+}
+
+// decoded returns the sample every frame ending before has been decoded by every decoder
+func (s *ReceiverStream) decoded() int {
+	decoded := s.states[0].n
+	for i, decoder := range s.decoders {
+		if sample := s.states[i].sample(decoder.base); sample < decoded {
+			decoded = sample
+		}
+	}
+	return decoded
+}
+
+// duplicateWindow returns the samples apart the same frame from different demodulators is one packet
+func (s *ReceiverStream) duplicateWindow() int {
+	return int(float64(duplicateWindow) * float64(s.samplesPerSecond) / float64(symbolRate))
+}
+
+// decode passes the bits of the block last mixed to each decoder and collects the frames into packets
+func (s *ReceiverStream) decode(final bool) {
+	var wg sync.WaitGroup
+	for i := range s.states {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var bits []byte
+			if !final {
+				bits = s.states[i].demodulateBits(s.mixer)
+			}
+			s.results[i] = s.decoders[i].decode(bits, final)
+		}(i)
+	}
+	wg.Wait()
+
+	window := s.duplicateWindow()
+	for i, frames := range s.results {
+		for _, frame := range frames {
+			sample := s.states[i].sample(frame.end)
+			packet := findDuplicate(s.packets, frame.ax25data, sample, window)
+			if packet == nil {
+				packet = &ReceivedPacket{AX25Data: frame.ax25data, Sample: sample, FixedBits: frame.fixed}
+				s.packets = append(s.packets, packet)
+			}
+			packet.Decoders = append(packet.Decoders, s.states[i].Name)
+			packet.fixedBits = append(packet.fixedBits, frame.fixed)
+			packet.decoders = append(packet.decoders, i)
+			if frame.fixed < packet.FixedBits {
+				packet.FixedBits = frame.fixed
+			}
+		}
+		s.results[i] = nil
+		s.states[i].release(s.decoders[i].base)
+	}
+}
+
+// complete returns the packets ending before sample, removing them from those held
+func (s *ReceiverStream) complete(sample int) []ReceivedPacket {
+	var received []ReceivedPacket
+	held := s.packets[:0]
+	for _, packet := range s.packets {
+		if packet.Sample < sample {
+			sort.Sort(decodersByFixedBits{packet})
+			received = append(received, *packet)
+		} else {
+			held = append(held, packet)
+		}
+	}
+	for i := len(held); i < len(s.packets); i++ {
+		s.packets[i] = nil
+	}
+	s.packets = held
+	sort.SliceStable(received, func(i, j int) bool { return received[i].Sample < received[j].Sample })
+	return received
+}
+
+// findDuplicate returns the packet with the same frame ending within window samples, or nil
+func findDuplicate(packets []*ReceivedPacket, ax25data AX25Data, sample int, window int) *ReceivedPacket {
+	for _, packet := range packets {
+		if string(packet.AX25Data) == string(ax25data) && packet.Sample-window <= sample && sample <= packet.Sample+window {
+			return packet
+		}
+	}
+	return nil
+}
+
+// decodersByFixedBits sorts the decoders of a packet with intact decodes first, then in bank order
+type decodersByFixedBits struct {
+	*ReceivedPacket
+}
+
+func (d decodersByFixedBits) Len() int { return len(d.Decoders) }
+func (d decodersByFixedBits) Less(i, j int) bool {
+	if d.fixedBits[i] != d.fixedBits[j] {
+		return d.fixedBits[i] < d.fixedBits[j]
+	}
+	return d.decoders[i] < d.decoders[j]
+}
+func (d decodersByFixedBits) Swap(i, j int) {
+	d.Decoders[i], d.Decoders[j] = d.Decoders[j], d.Decoders[i]
+	d.fixedBits[i], d.fixedBits[j] = d.fixedBits[j], d.fixedBits[i]
+	d.decoders[i], d.decoders[j] = d.decoders[j], d.decoders[i]
+}
@@ -0,0 +1,170 @@
+package aprsgo
+
+import (
+	"bytes"
+	"math/rand"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestDemodulate(t *testing.T) {
+	ax25data := testAX25Data(t, "Test")
+	for _, samplesPerSecond := range []uint32{48000, 44100, 22050, 9600} {
+		samples := ax25data.Encode().Modulate(samplesPerSecond)
+		demodulator := Demodulator{FilterWidth: 1, SpaceGain: 1}
+		if decoded := demodulator.Demodulate(samples, samplesPerSecond).Decode(); len(decoded) != 1 || !bytes.Equal(decoded[0], ax25data) {
+			t.Errorf("%d Hz expected the frame got %q", samplesPerSecond, decoded)
+		}
+	}
+}
+
+func TestDemodulateSampleRate(t *testing.T) {
+	samples := testAX25Data(t, "Test").Encode().Modulate(9600)
+	for _, samplesPerSecond := range []uint32{0, 600} {
+		if symbolStream := (Demodulator{FilterWidth: 1, SpaceGain: 1}).Demodulate(samples, samplesPerSecond); len(symbolStream) != 0 {
+			t.Errorf("%d Hz expected no symbols got %d", samplesPerSecond, len(symbolStream))
+		}
+		if received := (Receiver{}).Receive(samples, samplesPerSecond); len(received) != 0 {
+			t.Errorf("%d Hz expected no packets got %d", samplesPerSecond, len(received))
+		}
+	}
+}
+
+func TestReceiverNoise(t *testing.T) {
+	first, second := testAX25Data(t, "first"), testAX25Data(t, "second")
+	samplesPerSecond := uint32(48000)
+	samples := append(first.Encode(), second.Encode()...).Modulate(samplesPerSecond)
+	random := rand.New(rand.NewSource(1))
+	for i := range samples {
+		samples[i] += 0.4 * random.NormFloat64()
+	}
+
+	packets := Receiver{}.Receive(samples, samplesPerSecond)
+	if len(packets) != 2 || !bytes.Equal(packets[0].AX25Data, first) || !bytes.Equal(packets[1].AX25Data, second) {
+		t.Fatalf("Expected both frames once each got %d packets", len(packets))
+	}
+	for _, packet := range packets {
+		if len(packet.Decoders) == 0 || packet.FixedBits != 0 {
+			t.Errorf("Expected the decoders of an intact frame got %v with %d bits fixed", packet.Decoders, packet.FixedBits)
+		}
+	}
+}
+
+func TestReceiverFixBits(t *testing.T) {
+	ax25data := testAX25Data(t, "Test")
+	symbolStream := ax25data.Encode()
+	corrupt := len(symbolStream) - 8*flagPadding - 40 // in the information field
+	symbolStream[corrupt] = !symbolStream[corrupt]    // a wrong symbol is two wrong bits after NRZI
+	samples := symbolStream.Modulate(48000)
+	bank := []Demodulator{{Name: "only", FilterWidth: 1, SpaceGain: 1}}
+
+	if packets := (Receiver{Demodulators: bank}).Receive(samples, 48000); len(packets) != 0 {
+		t.Errorf("Expected no frames without repair got %d", len(packets))
+	}
+	if packets := (Receiver{Demodulators: bank, FixBits: 1}).Receive(samples, 48000); len(packets) != 0 {
+		t.Errorf("Expected no frames repairing one bit got %d", len(packets))
+	}
+	packets := Receiver{Demodulators: bank, FixBits: 2}.Receive(samples, 48000)
+	if len(packets) != 1 || !bytes.Equal(packets[0].AX25Data, ax25data) || packets[0].FixedBits != 2 || packets[0].Decoders[0] != "only" {
+		t.Errorf("Expected the frame repaired by flipping two bits got %+v", packets)
+	}
+}
+
+func TestRepairFCS(t *testing.T) {
+	ax25data := testAX25Data(t, "Test")
+	for _, flips := range [][]int{{20}, {8 * (len(ax25data) - 1)}, {100, 101}, {8*len(ax25data) - 9, 8*len(ax25data) - 8}} {
+		corrupted := append(AX25Data{}, ax25data...)
+		for _, i := range flips {
+			corrupted[i/8] ^= 1 << uint(i%8)
+		}
+		repaired, fixed, ok := repairFCS(corrupted, 2)
+		if !ok || fixed != len(flips) || !bytes.Equal(repaired, ax25data) {
+			t.Errorf("Flipping bits %v expected repair with %d bits got %d, %v", flips, len(flips), fixed, ok)
+		}
+		if _, _, ok := repairFCS(corrupted, len(flips)-1); ok {
+			t.Errorf("Flipping bits %v should not be repaired with %d bits", flips, len(flips)-1)
+		}
+	}
+
+	// two bits apart are not repaired, even where a single pair would pass the check
+	for _, flips := range [][2]int{{32, 240}, {100, 102}, {8, 8*len(ax25data) - 1}} {
+		corrupted := append(AX25Data{}, ax25data...)
+		for _, i := range flips {
+			corrupted[i/8] ^= 1 << uint(i%8)
+		}
+		if repaired, fixed, ok := repairFCS(corrupted, FixAdjacentBits); ok {
+			t.Errorf("Flipping bits %v apart should not be repaired, got %d bits fixed, intact %v", flips, fixed, bytes.Equal(repaired, ax25data))
+		}
+	}
+}
+
+func TestReceiverStreamBlocks(t *testing.T) {
+	ax25data, fx25data := testAX25Data(t, "AX.25"), testAX25Data(t, "FX.25")
+	fx25Stream, err := fx25data.EncodeFX25(16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	samplesPerSecond := uint32(22050)
+	symbolStream := append(append(ax25data.Encode(), fx25Stream...), ax25data.Encode()...)
+	samples := symbolStream.Modulate(samplesPerSecond)
+	random := rand.New(rand.NewSource(1))
+	for i := range samples {
+		samples[i] += 0.1 * random.NormFloat64()
+	}
+
+	expected := Receiver{}.Receive(samples, samplesPerSecond)
+	if len(expected) != 3 || !bytes.Equal(expected[1].AX25Data, fx25data) {
+		t.Fatalf("Expected three frames with the FX.25 frame second got %d", len(expected))
+	}
+	for _, size := range []int{1, 100, 1000, len(samples)} {
+		stream := NewReceiverStream(Receiver{}, samplesPerSecond)
+		var received []ReceivedPacket
+		for start := 0; start < len(samples); start += size {
+			end := start + size
+			if end > len(samples) {
+				end = len(samples)
+			}
+			received = append(received, stream.Process(samples[start:end])...)
+		}
+		received = append(received, stream.Flush()...)
+		if !reflect.DeepEqual(received, expected) {
+			t.Errorf("Blocks of %d samples expected the packets of Receive got %d packets", size, len(received))
+		}
+	}
+}
+
+func TestReceiverAllocation(t *testing.T) {
+	const samplesPerSecond = 48000
+	frame := testAX25Data(t, "Test").Encode().Modulate(samplesPerSecond)
+	random := rand.New(rand.NewSource(1))
+	audio := func(seconds int) []float64 { // a frame every half second of noise
+		samples := make([]float64, 0, seconds*samplesPerSecond)
+		for len(samples)+len(frame)+samplesPerSecond/2 <= cap(samples) {
+			samples = append(samples, frame...)
+			for i := 0; i < samplesPerSecond/2; i++ {
+				samples = append(samples, 0.1*random.NormFloat64())
+			}
+		}
+		return samples
+	}
+	allocated := func(samples []float64) (uint64, int) {
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		packets := Receiver{}.Receive(samples, samplesPerSecond)
+		runtime.ReadMemStats(&after)
+		return after.TotalAlloc - before.TotalAlloc, len(packets)
+	}
+
+	short, long := audio(5), audio(25)
+	shortBytes, shortPackets := allocated(short)
+	longBytes, longPackets := allocated(long)
+	if shortPackets == 0 || longPackets != shortPackets*len(long)/len(short) {
+		t.Fatalf("Expected every frame once got %d and %d packets", shortPackets, longPackets)
+	}
+	// a second of audio is 384000 bytes of samples, the receiver allocates a small part of that
+	perSecond := float64(longBytes-shortBytes) * samplesPerSecond / float64(len(long)-len(short))
+	if perSecond > 32*1024 {
+		t.Errorf("Expected at most 32 KiB allocated per second of audio got %.0f bytes", perSecond)
+	}
+}
@@ -48,6 +48,36 @@ func (ax25data AX25Data) Encode() SymbolStream {
 	return symbolStream
 }
 
+// EncodeBurst converts frames sent in one transmission to symbols, starting with leadFlags flags while
+// the transmitter settles, the TXDELAY, separating the frames with flags and ending with tailFlags flags,
+// at least one flag is always sent before and after the frames
+func EncodeBurst(frames []AX25Data, leadFlags, tailFlags int) SymbolStream {
+	var symbolStream SymbolStream
+	if leadFlags < 1 {
+		leadFlags = 1
+	}
+	if tailFlags < 1 {
+		tailFlags = 1
+	}
+
+	currentSymbol, consecutiveOnes := mark, 0
+	for i := 0; i < leadFlags; i++ {
+		symbolStream, currentSymbol, consecutiveOnes = writeByte(flag, currentSymbol, consecutiveOnes, false, symbolStream)
+	}
+	for i, ax25data := range frames {
+		if i > 0 { // a flag ends one frame and starts the next
+			symbolStream, currentSymbol, consecutiveOnes = writeByte(flag, currentSymbol, consecutiveOnes, false, symbolStream)
+		}
+		for _, dataByte := range ax25data {
+			symbolStream, currentSymbol, consecutiveOnes = writeByte(dataByte, currentSymbol, consecutiveOnes, true, symbolStream)
+		}
+	}
+	for i := 0; i < tailFlags; i++ {
+		symbolStream, currentSymbol, consecutiveOnes = writeByte(flag, currentSymbol, consecutiveOnes, false, symbolStream)
+	}
+	return symbolStream
+}
+
 func nrzi(currentSymbol Symbol, bit byte) Symbol {
 	// non-return to zero inverted encoding of bit
 	// i.e. if bit is 0 switch symbols
@@ -0,0 +1,250 @@
+package aprsgo
+
+// frame.go contains the encoding and decoding of AX.25 frames of every type,
+// the information (I), supervisory (S) and unnumbered (U) frames used by connected mode links,
+// with the modulo 8 control field of version 2.0 or the modulo 128 control field of version 2.2
+
+import (
+	"fmt"
+)
+
+// FrameType is the kind of AX.25 frame given by its control field
+type FrameType byte
+
+// AX.25 frame types
+const (
+	FrameI     FrameType = iota // information
+	FrameRR                     // receive ready
+	FrameRNR                    // receive not ready
+	FrameREJ                    // reject
+	FrameSABM                   // set asynchronous balanced mode, connect
+	FrameDISC                   // disconnect
+	FrameDM                     // disconnected mode
+	FrameUA                     // unnumbered acknowledge
+	FrameFRMR                   // frame reject
+	FrameUI                     // unnumbered information
+	FrameSREJ                   // selective reject, version 2.2
+	FrameSABME                  // set asynchronous balanced mode extended, connect modulo 128, version 2.2
+	FrameXID                    // exchange identification, negotiates link parameters, version 2.2
+)
+
+// control field values with the poll/final bit clear
+const (
+	controlRR    byte = 0x01
+	controlRNR   byte = 0x05
+	controlREJ   byte = 0x09
+	controlSREJ  byte = 0x0d
+	controlSABM  byte = 0x2f
+	controlSABME byte = 0x6f
+	controlDISC  byte = 0x43
+	controlDM    byte = 0x0f
+	controlUA    byte = 0x63
+	controlFRMR  byte = 0x87
+	controlUI         = controlFlag
+	controlXID   byte = 0xaf
+	controlPF    byte = 0x10 // the poll bit of commands and final bit of responses
+)
+
+var frameTypeNames = map[FrameType]string{
+	FrameI: "I", FrameRR: "RR", FrameRNR: "RNR", FrameREJ: "REJ",
+	FrameSABM: "SABM", FrameDISC: "DISC", FrameDM: "DM", FrameUA: "UA", FrameFRMR: "FRMR", FrameUI: "UI",
+	FrameSREJ: "SREJ", FrameSABME: "SABME", FrameXID: "XID",
+}
+
+var supervisoryControls = map[FrameType]byte{FrameRR: controlRR, FrameRNR: controlRNR, FrameREJ: controlREJ, FrameSREJ: controlSREJ}
+
+var unnumberedControls = map[FrameType]byte{
+	FrameSABM: controlSABM, FrameDISC: controlDISC, FrameDM: controlDM, FrameUA: controlUA, FrameFRMR: controlFRMR, FrameUI: controlUI,
+	FrameSABME: controlSABME, FrameXID: controlXID,
+}
+
+func (t FrameType) String() string {
+	if name, ok := frameTypeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("FrameType(%d)", byte(t))
+}
+
+// Frame is an AX.25 frame of any type
+type Frame struct {
+	Destination Address
+	Source      Address
+	Path        []Address // digipeaters, Repeated is the H bit
+	Command     bool      // a command, otherwise a response
+	Type        FrameType
+	PollFinal   bool   // the poll bit of a command or final bit of a response
+	Extended    bool   // I and S frames have a two byte modulo 128 control field
+	NS          int    // the send sequence number of an I frame
+	NR          int    // the receive sequence number of I and S frames, the next frame expected
+	PID         byte   // the protocol identifier of I and UI frames
+	Info        []byte // the information field of I, UI, FRMR and XID frames
+}
+
+// isSupervisory returns true for RR, RNR, REJ and SREJ frames
+func (t FrameType) isSupervisory() bool {
+	_, ok := supervisoryControls[t]
+	return ok
+}
+
+// AX25Data assembles the frame including the Frame Check Sequence
+func (frame Frame) AX25Data() AX25Data {
+	var ax25data []byte
+
+	// commands set the C bit of the destination, responses the C bit of the source
+	field := frame.Destination.field(frame.Command, false)
+	ax25data = append(ax25data, field[:]...)
+	field = frame.Source.field(!frame.Command, len(frame.Path) == 0)
+	ax25data = append(ax25data, field[:]...)
+	for i, digipeater := range frame.Path {
+		field = digipeater.field(digipeater.Repeated, i == len(frame.Path)-1)
+		ax25data = append(ax25data, field[:]...)
+	}
+
+	ax25data = append(ax25data, frame.controlField()...)
+	if frame.Type == FrameI || frame.Type == FrameUI {
+		ax25data = append(ax25data, frame.PID)
+	}
+	ax25data = append(ax25data, frame.Info...)
+
+	return AX25Data(appendFCS(ax25data))
+}
+
+// controlField returns the one byte control field, or two bytes for extended I and S frames
+func (frame Frame) controlField() []byte {
+	if !frame.Extended || (frame.Type != FrameI && !frame.Type.isSupervisory()) {
+		return []byte{frame.control()}
+	}
+	nr := byte(frame.NR&0x7f) << 1
+	if frame.PollFinal {
+		nr |= 0x01 // the poll/final bit is the low bit of the second byte
+	}
+	if frame.Type == FrameI {
+		return []byte{byte(frame.NS&0x7f) << 1, nr}
+	}
+	return []byte{supervisoryControls[frame.Type], nr}
+}
+
+// control returns the modulo 8 control field
+func (frame Frame) control() byte {
+	var control byte
+	switch {
+	case frame.Type == FrameI:
+		control = byte(frame.NR&7)<<5 | byte(frame.NS&7)<<1
+	case frame.Type.isSupervisory():
+		control = byte(frame.NR&7)<<5 | supervisoryControls[frame.Type]
+	default:
+		control = unnumberedControls[frame.Type]
+	}
+	if frame.PollFinal {
+		control |= controlPF
+	}
+	return control
+}
+
+// DecodeFrame checks the Frame Check Sequence and decodes a frame of any type with a modulo 8 control field
+func DecodeFrame(ax25data AX25Data) (Frame, error) {
+	return decodeFrame(ax25data, false)
+}
+
+// DecodeExtendedFrame decodes a frame from a modulo 128 link, I and S frames have a two byte control field
+func DecodeExtendedFrame(ax25data AX25Data) (Frame, error) {
+	return decodeFrame(ax25data, true)
+}
+
+func decodeFrame(ax25data AX25Data, extended bool) (Frame, error) {
+	var frame Frame
+	data, err := ax25data.checkFCS()
+	if err != nil {
+		return frame, err
+	}
+	addresses, offset, err := decodeAddresses(data)
+	if err != nil {
+		return frame, err
+	}
+	frame.Destination, frame.Source, frame.Path = addresses[0], addresses[1], addresses[2:]
+	// version 2 frames have exactly one C bit set, older frames are treated as commands
+	frame.Command = frame.Destination.Repeated || !frame.Source.Repeated
+	frame.Destination.Repeated, frame.Source.Repeated = false, false // the C bits are not H bits for these
+
+	if offset >= len(data) {
+		return frame, fmt.Errorf("frame has no control field")
+	}
+	control := data[offset]
+	offset++
+	frame.PollFinal = control&controlPF != 0
+	if extended && control&0x03 != 0x03 { // I and S frames have a second control byte
+		if offset >= len(data) {
+			return frame, fmt.Errorf("extended frame has a one byte control field")
+		}
+		frame.Extended = true
+		frame.PollFinal = data[offset]&0x01 != 0
+		frame.NR = int(data[offset] >> 1)
+		offset++
+	}
+	switch {
+	case control&0x01 == 0:
+		frame.Type = FrameI
+		if frame.Extended {
+			frame.NS = int(control >> 1)
+		} else {
+			frame.NS, frame.NR = int(control>>1)&7, int(control>>5)
+		}
+	case control&0x03 == 0x01:
+		if !frame.Extended {
+			frame.NR = int(control >> 5)
+		}
+		found := false
+		for frameType, value := range supervisoryControls {
+			if control&0x0f == value {
+				frame.Type, found = frameType, true
+			}
+		}
+		if !found || (frame.Extended && control&0xf0 != 0) {
+			return frame, fmt.Errorf("unknown supervisory control field %02x", control)
+		}
+	default:
+		found := false
+		for frameType, value := range unnumberedControls {
+			if control&^controlPF == value {
+				frame.Type, found = frameType, true
+			}
+		}
+		if !found {
+			return frame, fmt.Errorf("unknown unnumbered control field %02x", control)
+		}
+	}
+
+	if frame.Type == FrameI || frame.Type == FrameUI {
+		if offset >= len(data) {
+			return frame, fmt.Errorf("%s frame has no protocol identifier", frame.Type)
+		}
+		frame.PID = data[offset]
+		offset++
+	}
+	frame.Info = data[offset:]
+	return frame, nil
+}
+
+// String returns the frame in a monitor format, e.g. W1AW>N0CALL <I C P S1 R2>:info
+func (frame Frame) String() string {
+	header := frame.Source.String() + ">" + frame.Destination.String()
+	for _, digipeater := range frame.Path {
+		header += "," + digipeater.String()
+	}
+	kind := "R"
+	if frame.Command {
+		kind = "C"
+	}
+	if frame.PollFinal && frame.Command {
+		kind += " P"
+	} else if frame.PollFinal {
+		kind += " F"
+	}
+	switch frame.Type {
+	case FrameI:
+		kind += fmt.Sprintf(" S%d R%d", frame.NS, frame.NR)
+	case FrameRR, FrameRNR, FrameREJ, FrameSREJ:
+		kind += fmt.Sprintf(" R%d", frame.NR)
+	}
+	return fmt.Sprintf("%s <%s %s>:%s", header, frame.Type, kind, frame.Info)
+}
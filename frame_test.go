@@ -0,0 +1,106 @@
+package aprsgo
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFrameRoundTrip(t *testing.T) {
+	local := Address{Callsign: "W1AW"}
+	remote := Address{Callsign: "N0CALL", SSID: 7}
+
+	testCases := []struct {
+		In      Frame
+		Control byte
+	}{
+		{In: Frame{Type: FrameSABM, Command: true, PollFinal: true}, Control: 0x3f},
+		{In: Frame{Type: FrameUA, PollFinal: true}, Control: 0x73},
+		{In: Frame{Type: FrameDISC, Command: true, PollFinal: true}, Control: 0x53},
+		{In: Frame{Type: FrameDM}, Control: 0x0f},
+		{In: Frame{Type: FrameFRMR, Info: []byte{0x00, 0x22, 0x08}}, Control: 0x87},
+		{In: Frame{Type: FrameUI, Command: true, PID: pID, Info: []byte("Test")}, Control: 0x03},
+		{In: Frame{Type: FrameI, Command: true, NS: 3, NR: 5, PID: pID, Info: []byte("hello")}, Control: 0xa6},
+		{In: Frame{Type: FrameRR, NR: 2, PollFinal: true}, Control: 0x51},
+		{In: Frame{Type: FrameRNR, Command: true, NR: 7}, Control: 0xe5},
+		{In: Frame{Type: FrameREJ, NR: 1}, Control: 0x29},
+	}
+
+	for _, testCase := range testCases {
+		frame := testCase.In
+		frame.Destination, frame.Source = remote, local
+		frame.Path = []Address{{Callsign: "WIDE1", SSID: 1, Repeated: true}}
+		ax25data := frame.AX25Data()
+		if control := ax25data[3*addressFieldSize]; control != testCase.Control {
+			t.Errorf("%s expected control field %02x got %02x", frame.Type, testCase.Control, control)
+		}
+
+		decoded, err := DecodeFrame(ax25data)
+		if err != nil {
+			t.Errorf("Decoding %s failed with error %v", frame, err)
+			continue
+		}
+		if len(decoded.Info) == 0 {
+			decoded.Info = frame.Info // nil and empty compare unequal
+		}
+		if !reflect.DeepEqual(decoded, frame) {
+			t.Errorf("Expected %s got %s", frame, decoded)
+		}
+	}
+}
+
+func TestExtendedFrameRoundTrip(t *testing.T) {
+	testCases := []struct {
+		In      Frame
+		Control []byte
+	}{
+		{In: Frame{Type: FrameI, Command: true, NS: 100, NR: 127, PollFinal: true, PID: pID, Info: []byte("hello")}, Control: []byte{0xc8, 0xff}},
+		{In: Frame{Type: FrameRR, NR: 64}, Control: []byte{0x01, 0x80}},
+		{In: Frame{Type: FrameREJ, NR: 3, PollFinal: true}, Control: []byte{0x09, 0x07}},
+		{In: Frame{Type: FrameSREJ, NR: 90}, Control: []byte{0x0d, 0xb4}},
+		{In: Frame{Type: FrameSABME, Command: true, PollFinal: true}, Control: []byte{0x7f}},
+		{In: Frame{Type: FrameXID, Command: true, PollFinal: true, Info: []byte{0x82, 0x80, 0x00, 0x00}}, Control: []byte{0xbf}},
+	}
+
+	for _, testCase := range testCases {
+		frame := testCase.In
+		frame.Destination, frame.Source = Address{Callsign: "N0CALL", SSID: 7}, Address{Callsign: "W1AW"}
+		frame.Path = []Address{}
+		frame.Extended = frame.Type == FrameI || frame.Type.isSupervisory()
+		ax25data := frame.AX25Data()
+		if control := []byte(ax25data[2*addressFieldSize : 2*addressFieldSize+len(testCase.Control)]); !reflect.DeepEqual(control, testCase.Control) {
+			t.Errorf("%s expected control field % x got % x", frame.Type, testCase.Control, control)
+		}
+
+		decoded, err := DecodeExtendedFrame(ax25data)
+		if err != nil {
+			t.Errorf("Decoding %s failed with error %v", frame, err)
+			continue
+		}
+		if len(decoded.Info) == 0 {
+			decoded.Info = frame.Info
+		}
+		if !reflect.DeepEqual(decoded, frame) {
+			t.Errorf("Expected %s got %s", frame, decoded)
+		}
+	}
+}
+
+func TestDecodeFrameUIPacket(t *testing.T) {
+	report := PositionData{Callsign: "W1AW", Latitude: 41.7147, Longitude: -72.7272, Comment: "Test"}
+	ax25data, err := report.BasicAPRSReport()
+	if err != nil {
+		t.Fatalf("Report failed validation: %v", err)
+	}
+	frame, err := DecodeFrame(ax25data)
+	if err != nil {
+		t.Fatalf("Decoding failed with error %v", err)
+	}
+	if frame.Type != FrameUI || !frame.Command || frame.Source.String() != "W1AW" || string(frame.Info) != "!4142.88N/07243.63W-Test" {
+		t.Errorf("Unexpected frame %s", frame)
+	}
+
+	ax25data[len(ax25data)-1] ^= 0x01
+	if _, err := DecodeFrame(ax25data); err == nil {
+		t.Errorf("Decoding a corrupted frame should fail the frame check sequence")
+	}
+}
@@ -0,0 +1,184 @@
+package aprsgo
+
+// fx25.go contains FX.25 forward error correction: the bit stuffed AX.25 frame is sent as the data
+// of a Reed-Solomon codeblock after a correlation tag identifying the code, receivers that do not
+// know FX.25 still find the AX.25 frame between its flags
+
+import (
+	"fmt"
+	"math/bits"
+)
+
+// fx25Mode is a Reed-Solomon code identified by its correlation tag
+type fx25Mode struct {
+	tag      uint64 // sent least significant bit first
+	codeSize int    // bytes in the codeblock, data and check bytes
+	dataSize int
+}
+
+// the FX.25 correlation tags Tag_01 to Tag_0B, shortened from RS(255,239), RS(255,223) and RS(255,191)
+var fx25Modes = []fx25Mode{
+	{tag: 0xB74DB7DF8A532F3E, codeSize: 255, dataSize: 239},
+	{tag: 0x26FF60A600CC8FDE, codeSize: 144, dataSize: 128},
+	{tag: 0xC7DC0508F3D9B09E, codeSize: 80, dataSize: 64},
+	{tag: 0x8F056EB4369660EE, codeSize: 48, dataSize: 32},
+	{tag: 0x6E260B1AC5835FAE, codeSize: 255, dataSize: 223},
+	{tag: 0xFF94DC634F1CFF4E, codeSize: 160, dataSize: 128},
+	{tag: 0x1EB7B9CDBC09C00E, codeSize: 96, dataSize: 64},
+	{tag: 0xDBF869BD2DBB1776, codeSize: 64, dataSize: 32},
+	{tag: 0x3ADB0C13DEAE2836, codeSize: 255, dataSize: 191},
+	{tag: 0xAB69DB6A543188D6, codeSize: 192, dataSize: 128},
+	{tag: 0x4A4ABEC4A724B796, codeSize: 128, dataSize: 64},
+}
+
+const fx25FirstRoot = 1 // FX.25 generator polynomial roots start at alpha^1
+
+var fx25Codes = map[int]*reedSolomon{
+	16: newReedSolomon(16, fx25FirstRoot),
+	32: newReedSolomon(32, fx25FirstRoot),
+	64: newReedSolomon(64, fx25FirstRoot),
+}
+
+var fx25TagTolerance = 8 // the number of bits of a received correlation tag that may be wrong
+
+func (mode fx25Mode) checkBytes() int {
+	return mode.codeSize - mode.dataSize
+}
+
+// EncodeFX25 converts ax25data to symbols for transmission as FX.25 with 16, 32 or 64 check bytes,
+// using the smallest codeblock the bit stuffed frame fits in
+func (ax25data AX25Data) EncodeFX25(checkBytes int) (SymbolStream, error) {
+	if _, ok := fx25Codes[checkBytes]; !ok {
+		return nil, fmt.Errorf("FX.25 supports 16, 32 or 64 check bytes, not %d", checkBytes)
+	}
+	frameBits := stuffBits(ax25data)
+	needed := (len(frameBits) + 7) / 8
+	var mode fx25Mode
+	for _, m := range fx25Modes {
+		if m.checkBytes() == checkBytes && m.dataSize >= needed && (mode.dataSize == 0 || m.dataSize < mode.dataSize) {
+			mode = m
+		}
+	}
+	if mode.dataSize == 0 {
+		return nil, fmt.Errorf("frame of %d bytes after bit stuffing is too long for FX.25 with %d check bytes", needed, checkBytes)
+	}
+	for i := 0; len(frameBits) < 8*mode.dataSize; i++ { // fill with the flag pattern
+		frameBits = append(frameBits, (flag>>uint(i%8))&1)
+	}
+	codeblock := packBits(frameBits)
+	codeblock = append(codeblock, fx25Codes[checkBytes].encode(codeblock)...)
+
+	var symbolStream SymbolStream
+	currentSymbol, consecutiveOnes := mark, 0
+	for i := 0; i < clockPadding; i++ {
+		symbolStream, currentSymbol, consecutiveOnes = writeByte(0x00, currentSymbol, consecutiveOnes, false, symbolStream)
+	}
+	for i := 0; i < flagPadding; i++ {
+		symbolStream, currentSymbol, consecutiveOnes = writeByte(flag, currentSymbol, consecutiveOnes, false, symbolStream)
+	}
+	for i := 0; i < 8; i++ { // the correlation tag
+		symbolStream, currentSymbol, consecutiveOnes = writeByte(byte(mode.tag>>uint(8*i)), currentSymbol, consecutiveOnes, false, symbolStream)
+	}
+	for _, dataByte := range codeblock { // already bit stuffed
+		symbolStream, currentSymbol, consecutiveOnes = writeByte(dataByte, currentSymbol, consecutiveOnes, false, symbolStream)
+	}
+	for i := 0; i < flagPadding; i++ {
+		symbolStream, currentSymbol, consecutiveOnes = writeByte(flag, currentSymbol, consecutiveOnes, false, symbolStream)
+	}
+	return symbolStream, nil
+}
+
+// stuffBits returns the bits of the frame between flags, least significant first, with a zero after five ones
+func stuffBits(ax25data AX25Data) []byte {
+	var frameBits []byte
+	for i := 0; i < 8; i++ {
+		frameBits = append(frameBits, (flag>>uint(i))&1)
+	}
+	consecutiveOnes := 0
+	for _, dataByte := range ax25data {
+		for i := 0; i < 8; i++ {
+			bit := (dataByte >> uint(i)) & 1
+			frameBits = append(frameBits, bit)
+			if bit == zero {
+				consecutiveOnes = 0
+				continue
+			}
+			consecutiveOnes++
+			if consecutiveOnes == 5 {
+				frameBits = append(frameBits, zero)
+				consecutiveOnes = 0
+			}
+		}
+	}
+	for i := 0; i < 8; i++ {
+		frameBits = append(frameBits, (flag>>uint(i))&1)
+	}
+	return frameBits
+}
+
+// unpackBits returns the bits of data least significant first
+func unpackBits(data []byte) []byte {
+	unpacked := make([]byte, 0, 8*len(data))
+	for _, dataByte := range data {
+		for i := 0; i < 8; i++ {
+			unpacked = append(unpacked, (dataByte>>uint(i))&1)
+		}
+	}
+	return unpacked
+}
+
+// matchFX25Tag returns the mode of a correlation tag received with at most fx25TagTolerance bits wrong
+func matchFX25Tag(received uint64) (fx25Mode, bool) {
+	for _, mode := range fx25Modes {
+		if bits.OnesCount64(received^mode.tag) <= fx25TagTolerance {
+			return mode, true
+		}
+	}
+	return fx25Mode{}, false
+}
+
+var maxFX25CodeSize = 255 // the longest codeblock, the bits after a tag the scanner waits for
+
+// fx25Scanner finds correlation tags in bits received a block at a time, corrects the codeblock
+// following each and returns the frames with a valid Frame Check Sequence
+type fx25Scanner struct {
+	next   int    // the index of the next bit to check
+	window uint64 // the last 64 bits before next, the earliest in the least significant bit
+	filled int
+}
+
+// scan checks the received bits from next, received holding the bits from index base on. Unless final it
+// stops where a whole codeblock may not yet follow. It returns the frames found along with the bits from
+// each tag to the end of its codeblock
+func (s *fx25Scanner) scan(received []byte, base int, final bool) ([]receivedFrame, [][2]int) {
+	var frames []receivedFrame
+	var blocks [][2]int
+	total := base + len(received)
+	for ; s.next < total; s.next++ {
+		i := s.next
+		if !final && i+1+8*maxFX25CodeSize > total {
+			break
+		}
+		s.window = s.window>>1 | uint64(received[i-base])<<63
+		if s.filled++; s.filled < 64 {
+			continue
+		}
+		mode, ok := matchFX25Tag(s.window)
+		start, end := i+1, i+1+8*mode.codeSize
+		if !ok || end > total {
+			continue
+		}
+		codeblock := []byte(packBits(received[start-base : end-base]))
+		if _, err := fx25Codes[mode.checkBytes()].decode(codeblock); err != nil {
+			continue
+		}
+		found := hdlcFrames(unpackBits(codeblock[:mode.dataSize]))
+		if len(found) == 0 {
+			continue
+		}
+		frames = append(frames, receivedFrame{ax25data: found[0].ax25data, end: end})
+		blocks = append(blocks, [2]int{start - 64, end})
+		s.next, s.window, s.filled = end-1, 0, 0
+	}
+	return frames, blocks
+}
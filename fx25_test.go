@@ -0,0 +1,76 @@
+package aprsgo
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// fx25CodeblockStart is the symbol where the codeblock begins after the preamble and correlation tag
+var fx25CodeblockStart = 8 * (clockPadding + flagPadding + 8)
+
+func TestEncodeFX25(t *testing.T) {
+	ax25data := testAX25Data(t, "Test")
+	testCases := []struct {
+		CheckBytes int
+		CodeSize   int
+	}{
+		{CheckBytes: 16, CodeSize: 80},
+		{CheckBytes: 32, CodeSize: 96},
+		{CheckBytes: 64, CodeSize: 128},
+	}
+	for _, testCase := range testCases {
+		symbolStream, err := ax25data.EncodeFX25(testCase.CheckBytes)
+		if err != nil {
+			t.Errorf("Encoding with %d check bytes failed with error %v", testCase.CheckBytes, err)
+			continue
+		}
+		if size := len(symbolStream)/8 - clockPadding - 2*flagPadding - 8; size != testCase.CodeSize {
+			t.Errorf("Expected a codeblock of %d bytes with %d check bytes got %d", testCase.CodeSize, testCase.CheckBytes, size)
+		}
+
+		// plain AX.25 receivers still find the frame
+		if frames := hdlcFrames(symbolStream.bits()); len(frames) != 1 || !bytes.Equal(frames[0].ax25data, ax25data) {
+			t.Errorf("Expected the AX.25 frame without FX.25 decoding got %d frames", len(frames))
+		}
+
+		// flipping a symbol changes two bits, so each flip is at most two byte errors
+		for i := 0; i < testCase.CheckBytes/4; i++ {
+			symbol := fx25CodeblockStart + i*8*testCase.CodeSize/(testCase.CheckBytes/4) + 3
+			symbolStream[symbol] = !symbolStream[symbol]
+		}
+		symbolStream[8*clockPadding+8*flagPadding+5] = !symbolStream[8*clockPadding+8*flagPadding+5] // in the tag
+		if frames := hdlcFrames(symbolStream.bits()); len(frames) != 0 {
+			t.Errorf("Expected the errors to corrupt the AX.25 frame")
+		}
+		if decoded := symbolStream.Decode(); len(decoded) != 1 || !bytes.Equal(decoded[0], ax25data) {
+			t.Errorf("Expected the corrected frame with %d check bytes got %q", testCase.CheckBytes, decoded)
+		}
+	}
+}
+
+func TestEncodeFX25Errors(t *testing.T) {
+	ax25data := testAX25Data(t, "Test")
+	if _, err := ax25data.EncodeFX25(8); err == nil {
+		t.Errorf("Encoding with 8 check bytes should fail")
+	}
+	long := AX25Data(append([]byte{}, ax25data...))
+	long = append(long, []byte(strings.Repeat("x", 160))...)
+	if _, err := long.EncodeFX25(64); err == nil {
+		t.Errorf("Encoding a frame longer than RS(255,191) holds should fail")
+	}
+	if _, err := long.EncodeFX25(16); err != nil {
+		t.Errorf("Encoding a frame fitting RS(255,239) failed with error %v", err)
+	}
+}
+
+func TestMatchFX25Tag(t *testing.T) {
+	for _, mode := range fx25Modes {
+		if matched, ok := matchFX25Tag(mode.tag ^ 0xff); !ok || matched != mode {
+			t.Errorf("Tag %016x with 8 bits wrong should match", mode.tag)
+		}
+	}
+	if _, ok := matchFX25Tag(fx25Modes[0].tag ^ 0x1ff); ok {
+		t.Errorf("Tag with 9 bits wrong should not match")
+	}
+}
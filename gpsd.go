@@ -0,0 +1,207 @@
+package aprsgo
+
+// gpsd.go contains a client for the gpsd JSON protocol that streams
+// position fixes from a running gpsd daemon as PositionData
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"sync"
+	"time"
+)
+
+// FixMode is the type of fix reported by gpsd in a TPV report
+type FixMode int
+
+// Fix modes as reported in the gpsd TPV mode field
+const (
+	FixModeUnknown FixMode = iota // mode not yet seen
+	FixModeNone                   // no fix
+	FixMode2D                     // latitude and longitude only
+	FixMode3D                     // latitude, longitude and altitude
+)
+
+// GPSDDefaultAddress is the address gpsd listens on by default
+const GPSDDefaultAddress = "localhost:2947"
+
+const (
+	metersToFeet = 3.28084   // conversion factor from gpsd meters to APRS feet
+	mpsToKnots   = 1.9438445 // conversion factor from gpsd m/s to APRS knots
+)
+
+var (
+	gpsdWatchCommand   = []byte("?WATCH={\"enable\":true,\"json\":true};\n") // start streaming JSON reports
+	gpsdReconnectDelay = 2 * time.Second                                     // delay between reconnection attempts
+	gpsdDialTimeout    = 5 * time.Second                                     // timeout for connecting to gpsd
+)
+
+// GPSFix is a single position fix received from gpsd
+type GPSFix struct {
+	Position          PositionData // Latitude, Longitude, Altitude, Course, Speed and Timestamp from the TPV
+	Mode              FixMode      // the fix mode, altitude is only meaningful for FixMode3D
+	SatellitesVisible int          // number of satellites in the last SKY report
+	SatellitesUsed    int          // number of satellites used in the last SKY report
+}
+
+// HasFix returns true if the fix contains a valid latitude and longitude
+func (fix GPSFix) HasFix() bool {
+	return fix.Mode >= FixMode2D
+}
+
+// GPSDClient streams position fixes from gpsd, reconnecting automatically
+type GPSDClient struct {
+	addr  string
+	fixes chan GPSFix
+	quit  chan struct{}
+	once  sync.Once // closes quit
+	wg    sync.WaitGroup
+
+	mu   sync.Mutex // protects conn
+	conn net.Conn
+}
+
+// gpsdReport holds the fields of the TPV and SKY reports that are used
+type gpsdReport struct {
+	Class      string   `json:"class"`
+	Mode       int      `json:"mode"`
+	Time       string   `json:"time"`
+	Lat        float64  `json:"lat"`
+	Lon        float64  `json:"lon"`
+	Alt        float64  `json:"alt"`
+	AltMSL     *float64 `json:"altMSL"` // nil if not reported
+	Track      float64  `json:"track"`
+	Speed      float64  `json:"speed"`
+	NSat       int      `json:"nSat"`
+	USat       int      `json:"uSat"`
+	Satellites []struct {
+		Used bool `json:"used"`
+	} `json:"satellites"`
+}
+
+// DialGPSD starts a client connected to the gpsd daemon at addr.
+// Fixes are delivered on the Fixes channel until Close is called,
+// the connection is re-established after gpsdReconnectDelay whenever it is lost.
+func DialGPSD(addr string) *GPSDClient {
+	c := &GPSDClient{
+		addr:  addr,
+		fixes: make(chan GPSFix),
+		quit:  make(chan struct{}),
+	}
+	c.wg.Add(1)
+	go c.run()
+	return c
+}
+
+// Fixes returns the channel of position fixes, it is closed after Close
+func (c *GPSDClient) Fixes() <-chan GPSFix {
+	return c.fixes
+}
+
+// Close disconnects from gpsd and stops reconnecting
+func (c *GPSDClient) Close() error {
+	c.once.Do(func() {
+		close(c.quit)
+		c.mu.Lock()
+		if c.conn != nil {
+			c.conn.Close() // unblock any pending read
+		}
+		c.mu.Unlock()
+	})
+	c.wg.Wait()
+	return nil
+}
+
+func (c *GPSDClient) run() {
+	defer c.wg.Done()
+	defer close(c.fixes)
+	for {
+		conn, err := net.DialTimeout("tcp", c.addr, gpsdDialTimeout)
+		if err == nil {
+			c.mu.Lock()
+			select {
+			case <-c.quit: // closed while dialing
+				c.mu.Unlock()
+				conn.Close()
+				return
+			default:
+			}
+			c.conn = conn
+			c.mu.Unlock()
+			c.watch(conn)
+			c.mu.Lock()
+			c.conn = nil
+			c.mu.Unlock()
+			conn.Close()
+		}
+		select {
+		case <-c.quit:
+			return
+		case <-time.After(gpsdReconnectDelay):
+		}
+	}
+}
+
+// watch enables streaming on conn and forwards fixes until the connection fails
+func (c *GPSDClient) watch(conn net.Conn) {
+	if _, err := conn.Write(gpsdWatchCommand); err != nil {
+		return
+	}
+	var visible, used int
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		var report gpsdReport
+		if err := json.Unmarshal(scanner.Bytes(), &report); err != nil {
+			continue // skip anything that isn't a JSON report
+		}
+		switch report.Class {
+		case "SKY":
+			visible, used = report.satelliteCounts()
+		case "TPV":
+			fix := report.fix()
+			fix.SatellitesVisible, fix.SatellitesUsed = visible, used
+			select {
+			case c.fixes <- fix:
+			case <-c.quit:
+				return
+			}
+		}
+	}
+}
+
+// satelliteCounts returns the visible and used satellites from a SKY report
+func (report gpsdReport) satelliteCounts() (int, int) {
+	if report.NSat > 0 || report.USat > 0 { // newer gpsd reports the counts directly
+		return report.NSat, report.USat
+	}
+	used := 0
+	for _, sat := range report.Satellites {
+		if sat.Used {
+			used++
+		}
+	}
+	return len(report.Satellites), used
+}
+
+// fix converts a TPV report to a GPSFix
+func (report gpsdReport) fix() GPSFix {
+	fix := GPSFix{Mode: FixMode(report.Mode)}
+	if t, err := time.Parse(time.RFC3339Nano, report.Time); err == nil {
+		fix.Position.Timestamp = t
+	}
+	if fix.Mode < FixMode2D {
+		return fix
+	}
+	fix.Position.Latitude = report.Lat
+	fix.Position.Longitude = report.Lon
+	fix.Position.Course = report.Track
+	fix.Position.Speed = report.Speed * mpsToKnots
+	if fix.Mode == FixMode3D {
+		alt := report.Alt // older gpsd only reports alt
+		if report.AltMSL != nil {
+			alt = *report.AltMSL
+		}
+		fix.Position.Altitude = alt * metersToFeet
+	}
+	return fix
+}
@@ -0,0 +1,142 @@
+package aprsgo
+
+import (
+	"bufio"
+	"encoding/json"
+	"math"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var gpsdTestStream = []string{
+	`{"class":"VERSION","release":"3.17","rev":"3.17","proto_major":3,"proto_minor":12}`,
+	`{"class":"DEVICES","devices":[{"class":"DEVICE","path":"/dev/ttyUSB0","activated":"2017-05-01T12:00:00.000Z"}]}`,
+	`{"class":"WATCH","enable":true,"json":true,"nmea":false}`,
+	`{"class":"TPV","device":"/dev/ttyUSB0","mode":1,"time":"2017-05-01T12:00:00.000Z"}`,
+	`{"class":"SKY","device":"/dev/ttyUSB0","satellites":[{"PRN":2,"used":true},{"PRN":5,"used":true},{"PRN":7,"used":false},{"PRN":12,"used":true}]}`,
+	`{"class":"TPV","device":"/dev/ttyUSB0","mode":2,"time":"2017-05-01T12:00:01.000Z","lat":41.7147,"lon":-72.7272,"track":90.0,"speed":10.0}`,
+	`not a json report`,
+	`{"class":"TPV","device":"/dev/ttyUSB0","mode":3,"time":"2017-05-01T12:00:02.000Z","lat":41.7148,"lon":-72.7271,"alt":100.0,"track":180.5,"speed":0.0}`,
+}
+
+// fakeGPSD accepts connections, waits for the WATCH command and replays the stream,
+// then drops the connection to exercise reconnecting
+func fakeGPSD(t *testing.T, stream []string) (net.Listener, <-chan string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error starting fake gpsd: %v", err)
+	}
+	commands := make(chan string, 10)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			line, err := bufio.NewReader(conn).ReadString('\n')
+			if err != nil {
+				conn.Close()
+				continue
+			}
+			commands <- strings.TrimSpace(line)
+			for _, report := range stream {
+				conn.Write([]byte(report + "\r\n"))
+			}
+			conn.Close()
+		}
+	}()
+	return ln, commands
+}
+
+func TestGPSDClient(t *testing.T) {
+	defer func(saved time.Duration) { gpsdReconnectDelay = saved }(gpsdReconnectDelay)
+	gpsdReconnectDelay = 10 * time.Millisecond
+	ln, commands := fakeGPSD(t, gpsdTestStream)
+	defer ln.Close()
+
+	client := DialGPSD(ln.Addr().String())
+	var fixes []GPSFix
+	timeout := time.After(5 * time.Second)
+	for len(fixes) < 6 { // two connections worth of fixes
+		select {
+		case fix := <-client.Fixes():
+			fixes = append(fixes, fix)
+		case <-timeout:
+			t.Fatalf("Timed out after %d fixes", len(fixes))
+		}
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ { // closing concurrently must not panic
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			client.Close()
+		}()
+	}
+	wg.Wait()
+	if _, ok := <-client.Fixes(); ok {
+		t.Errorf("Fixes channel should be closed after Close")
+	}
+	for i := 0; i < 2; i++ {
+		if cmd := <-commands; !strings.HasPrefix(cmd, "?WATCH=") {
+			t.Errorf("Expected a ?WATCH command, got %q", cmd)
+		}
+	}
+
+	if fixes[0].HasFix() || fixes[0].Mode != FixModeNone {
+		t.Errorf("First report should have no fix, got mode %v", fixes[0].Mode)
+	}
+	second := fixes[1]
+	if !second.HasFix() || second.Mode != FixMode2D {
+		t.Errorf("Second report should be a 2D fix, got mode %v", second.Mode)
+	}
+	if second.SatellitesVisible != 4 || second.SatellitesUsed != 3 {
+		t.Errorf("Expected 4 visible and 3 used satellites, got %d and %d", second.SatellitesVisible, second.SatellitesUsed)
+	}
+	if second.Position.Latitude != 41.7147 || second.Position.Longitude != -72.7272 {
+		t.Errorf("Wrong position %v, %v", second.Position.Latitude, second.Position.Longitude)
+	}
+	if math.Abs(second.Position.Speed-19.438) > 0.01 {
+		t.Errorf("10 m/s should be 19.44 knots, got %v", second.Position.Speed)
+	}
+	if second.Position.Altitude != 0 {
+		t.Errorf("2D fix should have no altitude, got %v", second.Position.Altitude)
+	}
+	if want := time.Date(2017, 5, 1, 12, 0, 1, 0, time.UTC); !second.Position.Timestamp.Equal(want) {
+		t.Errorf("Expected time %v, got %v", want, second.Position.Timestamp)
+	}
+	third := fixes[2]
+	if third.Mode != FixMode3D || math.Abs(third.Position.Altitude-328.084) > 0.01 {
+		t.Errorf("Expected a 3D fix at 328 feet, got mode %v at %v", third.Mode, third.Position.Altitude)
+	}
+	if third.Position.Course != 180.5 {
+		t.Errorf("Expected course 180.5, got %v", third.Position.Course)
+	}
+	if fixes[5] != third {
+		t.Errorf("Reconnected stream should repeat the same fixes, got %v", fixes[5])
+	}
+}
+
+func TestGPSDAltitude(t *testing.T) {
+	testCases := []struct {
+		TPV  string
+		Want float64 // feet
+	}{
+		{`{"class":"TPV","mode":3,"lat":41.7,"lon":-72.7,"alt":30.0}`, 30 * metersToFeet},
+		{`{"class":"TPV","mode":3,"lat":41.7,"lon":-72.7,"alt":30.0,"altMSL":100.0}`, 100 * metersToFeet},
+		{`{"class":"TPV","mode":3,"lat":41.7,"lon":-72.7,"alt":30.0,"altMSL":0}`, 0}, // at sea level
+		{`{"class":"TPV","mode":2,"lat":41.7,"lon":-72.7,"alt":30.0,"altMSL":100.0}`, 0},
+	}
+	for _, testCase := range testCases {
+		var report gpsdReport
+		if err := json.Unmarshal([]byte(testCase.TPV), &report); err != nil {
+			t.Fatalf("Parsing %s failed with error %v", testCase.TPV, err)
+		}
+		if altitude := report.fix().Position.Altitude; math.Abs(altitude-testCase.Want) > 1e-9 {
+			t.Errorf("%s expected altitude %v got %v", testCase.TPV, testCase.Want, altitude)
+		}
+	}
+}
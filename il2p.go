@@ -0,0 +1,505 @@
+package aprsgo
+
+// il2p.go contains the Improved Layer 2 Protocol (IL2P): the AX.25 addresses, control field and PID
+// are translated to a compact header, and the header and payload are scrambled and protected with
+// Reed-Solomon parity after a sync word, sent most significant bit first without NRZI or bit stuffing
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+)
+
+// IL2POptions select the IL2P variant, both stations must use the same options
+type IL2POptions struct {
+	MaxFEC      bool // 16 parity bytes for every payload block, otherwise 2 or more depending on the block size
+	TrailingCRC bool // the Frame Check Sequence follows the payload, Hamming encoded
+}
+
+const (
+	il2pSyncWord       = 0xf15e48 // 24 bits before the header
+	il2pHeaderSize     = 13
+	il2pHeaderParity   = 2
+	il2pMaxPayload     = 1023 // the payload byte count has 10 bits
+	il2pMaxBlock       = 247  // payload bytes in a block with normal FEC
+	il2pMaxBlockMaxFEC = 239  // payload bytes in a block with max FEC
+	il2pMaxFECParity   = 16
+	il2pFirstRoot      = 0 // IL2P generator polynomial roots start at alpha^0
+
+	il2pPIDSupervisory = 0x0 // the translated PID of S frames
+	il2pPIDUnnumbered  = 0x1 // the translated PID of U frames other than UI
+)
+
+var (
+	il2pPreamble      = 8 // bytes of 0x55 sent before the sync word
+	il2pPostamble     = 2 // bytes of 0x55 sent after the frame
+	il2pSyncTolerance = 1 // the number of bits of a received sync word that may be wrong
+)
+
+// il2pPIDs translates the 4 bit IL2P PID of I and UI frames to the AX.25 PID, zero is not translated
+var il2pPIDs = [16]byte{
+	0x2: 0x20, // AX.25 layer 3
+	0x3: 0x01, // ISO 8208 / X.25 PLP
+	0x4: 0x06, // compressed TCP/IP
+	0x5: 0x07, // uncompressed TCP/IP
+	0x6: 0x08, // segmentation fragment
+	0xb: 0xcc, // ARPA Internet Protocol
+	0xc: 0xcd, // ARPA Address Resolution
+	0xd: 0xce, // FlexNet
+	0xe: 0xcf, // NET/ROM
+	0xf: 0xf0, // no layer 3
+}
+
+// il2pOpcodes are the 3 bit IL2P opcodes of U frames
+var il2pOpcodes = map[FrameType]int{FrameSABM: 0, FrameDISC: 1, FrameDM: 2, FrameUA: 3, FrameFRMR: 4, FrameUI: 5, FrameXID: 6}
+
+// il2pSupervisory are the 2 bit IL2P codes of S frames
+var il2pSupervisory = map[FrameType]int{FrameRR: 0, FrameRNR: 1, FrameREJ: 2, FrameSREJ: 3}
+
+// il2pHamming encodes a nibble as a Hamming(7,4) codeword for the trailing CRC
+var il2pHamming = [16]byte{0x00, 0x71, 0x62, 0x13, 0x54, 0x25, 0x36, 0x47, 0x38, 0x49, 0x5a, 0x2b, 0x6c, 0x1d, 0x0e, 0x7f}
+
+var il2pCodes = il2pReedSolomonCodes()
+
+// il2pReedSolomonCodes returns the codes for every number of parity bytes IL2P uses
+func il2pReedSolomonCodes() map[int]*reedSolomon {
+	codes := map[int]*reedSolomon{il2pMaxFECParity: newReedSolomon(il2pMaxFECParity, il2pFirstRoot)}
+	for nroots := il2pHeaderParity; nroots <= il2pHeaderParity+il2pMaxBlock/32; nroots++ {
+		codes[nroots] = newReedSolomon(nroots, il2pFirstRoot)
+	}
+	return codes
+}
+
+// EncodeIL2P converts ax25data to symbols for transmission as IL2P, frames the compact header
+// cannot describe, e.g. with digipeaters, are sent whole as the payload
+func (ax25data AX25Data) EncodeIL2P(options IL2POptions) (SymbolStream, error) {
+	if _, err := ax25data.checkFCS(); err != nil {
+		return nil, err
+	}
+	header, payload := il2pTranslate(ax25data, options.MaxFEC)
+	if len(payload) > il2pMaxPayload {
+		return nil, fmt.Errorf("IL2P payload of %d bytes is longer than %d", len(payload), il2pMaxPayload)
+	}
+
+	var data []byte
+	for i := 0; i < il2pPreamble; i++ {
+		data = append(data, 0x55)
+	}
+	data = append(data, il2pSyncWord>>16, il2pSyncWord>>8&0xff, il2pSyncWord&0xff)
+	data = append(data, il2pEncodeBlock(header[:], il2pHeaderParity)...)
+	sizes, parity := il2pBlockSizes(len(payload), options.MaxFEC)
+	for _, size := range sizes {
+		data = append(data, il2pEncodeBlock(payload[:size], parity)...)
+		payload = payload[size:]
+	}
+	if options.TrailingCRC {
+		crc := calcCRC(ax25data[:len(ax25data)-2])
+		for shift := 12; shift >= 0; shift -= 4 {
+			data = append(data, il2pHamming[(crc>>uint(shift))&0xf])
+		}
+	}
+	for i := 0; i < il2pPostamble; i++ {
+		data = append(data, 0x55)
+	}
+
+	var symbolStream SymbolStream
+	for _, dataByte := range data {
+		for mask := byte(0x80); mask != 0; mask >>= 1 {
+			if dataByte&mask != 0 {
+				symbolStream = append(symbolStream, mark)
+			} else {
+				symbolStream = append(symbolStream, space)
+			}
+		}
+	}
+	return symbolStream, nil
+}
+
+// DecodeIL2P recovers the AX.25 frames sent as IL2P from received symbols, correcting errors,
+// a sync word received inverted inverts the frame following it
+func (symbolStream SymbolStream) DecodeIL2P(options IL2POptions) []AX25Data {
+	received := make([]byte, len(symbolStream))
+	for i, symbol := range symbolStream {
+		if symbol == mark {
+			received[i] = one
+		}
+	}
+
+	var frames []AX25Data
+	var window uint32 // the last 24 bits received, the latest in the least significant bit
+	filled := 0
+	for i := 0; i < len(received); i++ {
+		window = (window<<1 | uint32(received[i])) & 0xffffff
+		if filled++; filled < 24 {
+			continue
+		}
+		var inverted bool
+		switch {
+		case bits.OnesCount32(window^il2pSyncWord) <= il2pSyncTolerance:
+		case bits.OnesCount32(window^il2pSyncWord^0xffffff) <= il2pSyncTolerance:
+			inverted = true
+		default:
+			continue
+		}
+		ax25data, end, err := il2pDecodeFrame(received, i+1, inverted, options)
+		if err != nil {
+			continue
+		}
+		frames = append(frames, ax25data)
+		i, window, filled = end-1, 0, 0
+	}
+	return frames
+}
+
+// il2pDecodeFrame decodes the frame starting at the bit start after the sync word, returning the bit after it
+func il2pDecodeFrame(received []byte, start int, inverted bool, options IL2POptions) (AX25Data, int, error) {
+	reader := il2pReader{received: received, position: start, inverted: inverted}
+	header, err := reader.block(il2pHeaderSize, il2pHeaderParity)
+	if err != nil {
+		return nil, 0, err
+	}
+	count := il2pGetBits(header, 2, 10, 7)
+	sizes, parity := il2pBlockSizes(count, header[0]&0x80 != 0)
+	var payload []byte
+	for _, size := range sizes {
+		block, err := reader.block(size, parity)
+		if err != nil {
+			return nil, 0, err
+		}
+		payload = append(payload, block...)
+	}
+
+	ax25data, err := il2pReconstruct(header, payload)
+	if err != nil {
+		return nil, 0, err
+	}
+	if options.TrailingCRC {
+		encoded, ok := reader.bytes(4)
+		if !ok {
+			return nil, 0, errors.New("IL2P trailing CRC is truncated")
+		}
+		crc := 0
+		for _, codeword := range encoded {
+			nibble, ok := il2pHammingDecode(codeword)
+			if !ok {
+				return nil, 0, errors.New("IL2P trailing CRC has more than one bit error in a nibble")
+			}
+			crc = crc<<4 | nibble
+		}
+		if uint16(crc) != calcCRC(ax25data[:len(ax25data)-2]) {
+			return nil, 0, errors.New("IL2P trailing CRC does not match the frame")
+		}
+	}
+	return ax25data, reader.position, nil
+}
+
+// il2pReader reads bytes most significant bit first from received bits
+type il2pReader struct {
+	received []byte
+	position int
+	inverted bool
+}
+
+func (r *il2pReader) bytes(n int) ([]byte, bool) {
+	if r.position+8*n > len(r.received) {
+		return nil, false
+	}
+	data := make([]byte, n)
+	for i := range data {
+		for j := 0; j < 8; j++ {
+			bit := r.received[r.position]
+			if r.inverted {
+				bit ^= 1
+			}
+			data[i] = data[i]<<1 | bit
+			r.position++
+		}
+	}
+	return data, true
+}
+
+// block reads a block of size bytes and its parity, corrects and descrambles it
+func (r *il2pReader) block(size int, parity int) ([]byte, error) {
+	codeword, ok := r.bytes(size + parity)
+	if !ok {
+		return nil, errors.New("IL2P block is truncated")
+	}
+	if _, err := il2pCodes[parity].decode(codeword); err != nil {
+		return nil, err
+	}
+	return il2pDescramble(codeword[:size]), nil
+}
+
+// il2pEncodeBlock scrambles data and appends its parity
+func il2pEncodeBlock(data []byte, parity int) []byte {
+	scrambled := il2pScramble(data)
+	return append(scrambled, il2pCodes[parity].encode(scrambled)...)
+}
+
+// il2pBlockSizes returns the sizes of the payload blocks, the larger first, and the parity bytes of each
+func il2pBlockSizes(count int, maxFEC bool) ([]int, int) {
+	if count == 0 {
+		return nil, 0
+	}
+	maxBlock := il2pMaxBlock
+	if maxFEC {
+		maxBlock = il2pMaxBlockMaxFEC
+	}
+	blockCount := (count + maxBlock - 1) / maxBlock
+	smallSize := count / blockCount
+	largeCount := count - blockCount*smallSize
+	sizes := make([]int, blockCount)
+	for i := range sizes {
+		sizes[i] = smallSize
+		if i < largeCount {
+			sizes[i]++
+		}
+	}
+	if maxFEC {
+		return sizes, il2pMaxFECParity
+	}
+	return sizes, smallSize/32 + il2pHeaderParity
+}
+
+// il2pTranslate returns the compact header and payload of a frame, using the translated header
+// only if the frame is rebuilt exactly from it, otherwise the whole frame is the payload
+func il2pTranslate(ax25data AX25Data, maxFEC bool) ([il2pHeaderSize]byte, []byte) {
+	var header [il2pHeaderSize]byte
+	if maxFEC {
+		header[0] |= 0x80
+	}
+	if frame, err := DecodeFrame(ax25data); err == nil {
+		if translated, payload, ok := il2pTranslateFrame(frame, header); ok {
+			if rebuilt, err := il2pReconstruct(translated[:], payload); err == nil && string(rebuilt) == string(ax25data) {
+				return translated, payload
+			}
+		}
+	}
+	payload := []byte(ax25data[:len(ax25data)-2]) // the Frame Check Sequence is recalculated
+	il2pSetBits(header[:], 2, 10, 7, len(payload))
+	return header, payload
+}
+
+// il2pTranslateFrame fills in the type 1 header of a frame without digipeaters
+func il2pTranslateFrame(frame Frame, header [il2pHeaderSize]byte) ([il2pHeaderSize]byte, []byte, bool) {
+	if len(frame.Path) > 0 {
+		return header, nil, false
+	}
+	for i, address := range []Address{frame.Destination, frame.Source} {
+		for j := 0; j < len(address.Callsign); j++ {
+			c := address.Callsign[j]
+			if c < 0x20 || c > 0x5f {
+				return header, nil, false
+			}
+			header[6*i+j] |= c - 0x20
+		}
+	}
+	header[12] = byte(frame.Destination.SSID)<<4 | byte(frame.Source.SSID)&0x0f
+
+	var ui, pid, control int
+	if frame.PollFinal {
+		control = 0x40
+	}
+	if frame.Command {
+		control |= 0x04 // not used by I frames, which are always commands
+	}
+	switch {
+	case frame.Type == FrameI:
+		control = control&0x40 | (frame.NR&7)<<3 | frame.NS&7
+		pid = il2pTranslatePID(frame.PID)
+	case frame.Type == FrameUI:
+		ui = 1
+		control |= il2pOpcodes[FrameUI] << 3
+		pid = il2pTranslatePID(frame.PID)
+	case frame.Type.isSupervisory():
+		control |= (frame.NR&7)<<3 | il2pSupervisory[frame.Type]
+		pid = il2pPIDSupervisory
+	default:
+		opcode, ok := il2pOpcodes[frame.Type]
+		if !ok {
+			return header, nil, false
+		}
+		control |= opcode << 3
+		pid = il2pPIDUnnumbered
+	}
+	if pid < 0 {
+		return header, nil, false
+	}
+
+	header[1] |= 0x80 // header type 1
+	il2pSetBits(header[:], 0, 1, 6, ui)
+	il2pSetBits(header[:], 1, 4, 6, pid)
+	il2pSetBits(header[:], 5, 7, 6, control)
+	il2pSetBits(header[:], 2, 10, 7, len(frame.Info))
+	return header, frame.Info, true
+}
+
+// il2pTranslatePID returns the IL2P PID of an AX.25 PID, or -1 if there is none
+func il2pTranslatePID(pid byte) int {
+	for i, value := range il2pPIDs {
+		if value == pid && value != 0 {
+			return i
+		}
+	}
+	return -1
+}
+
+// il2pReconstruct rebuilds the AX.25 frame including the Frame Check Sequence from the header and payload
+func il2pReconstruct(header []byte, payload []byte) (AX25Data, error) {
+	if header[1]&0x80 == 0 { // type 0, the payload is the frame
+		ax25data := AX25Data(appendFCS(append([]byte{}, payload...)))
+		if _, err := ax25data.checkFCS(); err != nil {
+			return nil, err
+		}
+		return ax25data, nil
+	}
+
+	var frame Frame
+	for i, address := range []*Address{&frame.Destination, &frame.Source} {
+		callsign := make([]byte, 0, 6)
+		for _, c := range header[6*i : 6*i+6] {
+			callsign = append(callsign, c&0x3f+0x20)
+		}
+		address.Callsign = string(trimSpaces(callsign))
+	}
+	frame.Destination.SSID, frame.Source.SSID = SSID(header[12]>>4), SSID(header[12]&0x0f)
+	if frame.Destination.Callsign == "" || frame.Source.Callsign == "" {
+		return nil, errors.New("IL2P header has no callsign")
+	}
+
+	ui := il2pGetBits(header, 0, 1, 6)
+	pid := il2pGetBits(header, 1, 4, 6)
+	control := il2pGetBits(header, 5, 7, 6)
+	frame.PollFinal, frame.Command = control&0x40 != 0, control&0x04 != 0
+	switch {
+	case ui == 1:
+		frame.Type, frame.PID = FrameUI, il2pPIDs[pid]
+	case pid == il2pPIDSupervisory:
+		frame.NR = control >> 3 & 7
+		for frameType, code := range il2pSupervisory {
+			if code == control&3 {
+				frame.Type = frameType
+			}
+		}
+	case pid == il2pPIDUnnumbered:
+		frame.Type = FrameType(255)
+		for frameType, opcode := range il2pOpcodes {
+			if opcode == control>>3&7 {
+				frame.Type = frameType
+			}
+		}
+		if _, ok := il2pOpcodes[frame.Type]; !ok {
+			return nil, fmt.Errorf("unknown IL2P U frame opcode %d", control>>3&7)
+		}
+	default:
+		frame.Type, frame.Command, frame.PID = FrameI, true, il2pPIDs[pid]
+		frame.NR, frame.NS = control>>3&7, control&7
+	}
+	if (frame.Type == FrameI || frame.Type == FrameUI) && frame.PID == 0 {
+		return nil, fmt.Errorf("unknown IL2P PID %x", pid)
+	}
+	if len(payload) > 0 {
+		frame.Info = payload
+	}
+	return frame.AX25Data(), nil
+}
+
+// trimSpaces removes the spaces padding a callsign
+func trimSpaces(callsign []byte) []byte {
+	for len(callsign) > 0 && callsign[len(callsign)-1] == ' ' {
+		callsign = callsign[:len(callsign)-1]
+	}
+	return callsign
+}
+
+// il2pSetBits spreads value over the given bit of count header bytes from first, most significant first
+func il2pSetBits(header []byte, first int, count int, bit uint, value int) {
+	for i := 0; i < count; i++ {
+		if value>>uint(count-1-i)&1 != 0 {
+			header[first+i] |= 1 << bit
+		} else {
+			header[first+i] &^= 1 << bit
+		}
+	}
+}
+
+// il2pGetBits collects the given bit of count header bytes from first, most significant first
+func il2pGetBits(header []byte, first int, count int, bit uint) int {
+	value := 0
+	for i := 0; i < count; i++ {
+		value = value<<1 | int(header[first+i]>>bit&1)
+	}
+	return value
+}
+
+// il2pHammingDecode returns the nibble of a Hamming(7,4) codeword with at most one bit wrong
+func il2pHammingDecode(codeword byte) (int, bool) {
+	for nibble, valid := range il2pHamming {
+		if bits.OnesCount8((codeword^valid)&0x7f) <= 1 {
+			return nibble, true
+		}
+	}
+	return 0, false
+}
+
+const (
+	il2pScramblerTx = 0x00f // the initial scrambler state
+	il2pScramblerRx = 0x1f0 // the initial descrambler state
+)
+
+// il2pScramble scrambles a block with the x^9 + x^4 + 1 linear feedback shift register, most significant bit first,
+// the output is delayed five bits so the descrambler, started from its own state, lines up with the block
+func il2pScramble(data []byte) []byte {
+	out := make([]byte, len(data))
+	state := il2pScramblerTx
+	skipping, position := 5, 0
+	put := func(bit int) {
+		if bit != 0 {
+			out[position/8] |= 0x80 >> uint(position%8)
+		}
+		position++
+	}
+	for _, dataByte := range data {
+		for mask := byte(0x80); mask != 0; mask >>= 1 {
+			in := 0
+			if dataByte&mask != 0 {
+				in = 1
+			}
+			bit := il2pScrambleBit(in, &state)
+			if skipping > 0 {
+				skipping--
+				continue
+			}
+			put(bit)
+		}
+	}
+	for position < 8*len(data) { // flush the delayed bits
+		put(il2pScrambleBit(0, &state))
+	}
+	return out
+}
+
+func il2pScrambleBit(in int, state *int) int {
+	out := (*state>>4 ^ *state) & 1
+	*state = ((in^*state)&1<<9 | (*state ^ (*state&1)<<4)) >> 1
+	return out
+}
+
+// il2pDescramble reverses il2pScramble
+func il2pDescramble(data []byte) []byte {
+	out := make([]byte, len(data))
+	state := il2pScramblerRx
+	for i, dataByte := range data {
+		for mask := byte(0x80); mask != 0; mask >>= 1 {
+			in := 0
+			if dataByte&mask != 0 {
+				in = 1
+			}
+			if (in^state)&1 != 0 {
+				out[i] |= mask
+			}
+			state = (state>>1 | in<<8) ^ in<<3
+		}
+	}
+	return out
+}
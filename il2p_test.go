@@ -0,0 +1,170 @@
+package aprsgo
+
+import (
+	"bytes"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestIL2PScramble(t *testing.T) {
+	data := []byte("The quick brown fox jumps over the lazy dog")
+	scrambled := il2pScramble(data)
+	if bytes.Equal(scrambled, data) {
+		t.Errorf("Scrambling left the data unchanged")
+	}
+	if descrambled := il2pDescramble(scrambled); !bytes.Equal(descrambled, data) {
+		t.Errorf("Expected %q got %q", data, descrambled)
+	}
+}
+
+func TestIL2PBlockSizes(t *testing.T) {
+	testCases := []struct {
+		Count  int
+		MaxFEC bool
+		Sizes  []int
+		Parity int
+	}{
+		{Count: 0, Sizes: nil, Parity: 0},
+		{Count: 30, Sizes: []int{30}, Parity: 2},
+		{Count: 100, Sizes: []int{100}, Parity: 5},
+		{Count: 300, Sizes: []int{150, 150}, Parity: 6},
+		{Count: 1023, Sizes: []int{205, 205, 205, 204, 204}, Parity: 8},
+		{Count: 240, MaxFEC: true, Sizes: []int{120, 120}, Parity: 16},
+		{Count: 241, MaxFEC: true, Sizes: []int{121, 120}, Parity: 16},
+	}
+	for _, testCase := range testCases {
+		sizes, parity := il2pBlockSizes(testCase.Count, testCase.MaxFEC)
+		if parity != testCase.Parity || len(sizes) != len(testCase.Sizes) {
+			t.Errorf("%d bytes expected blocks %v with %d parity got %v with %d", testCase.Count, testCase.Sizes, testCase.Parity, sizes, parity)
+			continue
+		}
+		for i := range sizes {
+			if sizes[i] != testCase.Sizes[i] {
+				t.Errorf("%d bytes expected blocks %v got %v", testCase.Count, testCase.Sizes, sizes)
+			}
+		}
+	}
+}
+
+func TestIL2PRoundTrip(t *testing.T) {
+	local, remote := Address{Callsign: "W1AW"}, Address{Callsign: "N0CALL", SSID: 7}
+	frames := []struct {
+		AX25Data AX25Data
+		Type1    bool
+	}{
+		{AX25Data: testAX25Data(t, "Test"), Type1: true},
+		{AX25Data: Frame{Destination: remote, Source: local, Type: FrameI, Command: true, NS: 3, NR: 5, PollFinal: true, PID: noLayer3, Info: []byte("hello")}.AX25Data(), Type1: true},
+		{AX25Data: Frame{Destination: remote, Source: local, Type: FrameREJ, NR: 2}.AX25Data(), Type1: true},
+		{AX25Data: Frame{Destination: remote, Source: local, Type: FrameSABM, Command: true, PollFinal: true}.AX25Data(), Type1: true},
+		{AX25Data: Frame{Destination: remote, Source: local, Type: FrameXID, Command: true, Info: XIDParameters{Window: 7}.Encode()}.AX25Data(), Type1: true},
+		{AX25Data: Frame{Destination: remote, Source: local, Type: FrameSABME, Command: true, PollFinal: true}.AX25Data()},
+		{AX25Data: AssembleAX25Data(local, Address{Callsign: "APZ001"}, []byte(">digipeated"), Address{Callsign: "WIDE1", SSID: 1})},
+		{AX25Data: Frame{Destination: remote, Source: local, Type: FrameUI, Command: true, PID: 0x99, Info: []byte("x")}.AX25Data()},
+		{AX25Data: Frame{Destination: remote, Source: local, Type: FrameUI, Command: true, PID: noLayer3, Info: []byte(strings.Repeat("long ", 100))}.AX25Data(), Type1: true},
+	}
+	optionSets := []IL2POptions{{}, {MaxFEC: true}, {TrailingCRC: true}, {MaxFEC: true, TrailingCRC: true}}
+
+	for i, frame := range frames {
+		header, _ := il2pTranslate(frame.AX25Data, false)
+		if type1 := header[1]&0x80 != 0; type1 != frame.Type1 {
+			t.Errorf("Frame %d expected type 1 header %v got %v", i, frame.Type1, type1)
+		}
+		for _, options := range optionSets {
+			symbolStream, err := frame.AX25Data.EncodeIL2P(options)
+			if err != nil {
+				t.Errorf("Frame %d with %+v failed encoding with error %v", i, options, err)
+				continue
+			}
+			if decoded := symbolStream.DecodeIL2P(options); len(decoded) != 1 || !bytes.Equal(decoded[0], frame.AX25Data) {
+				t.Errorf("Frame %d with %+v expected % x got % x", i, options, frame.AX25Data, decoded)
+			}
+		}
+	}
+}
+
+func TestIL2PErrorCorrection(t *testing.T) {
+	ax25data := testAX25Data(t, "Test")
+	options := IL2POptions{MaxFEC: true, TrailingCRC: true}
+	symbolStream, err := ax25data.EncodeIL2P(options)
+	if err != nil {
+		t.Fatalf("Encoding failed with error %v", err)
+	}
+	random := rand.New(rand.NewSource(1))
+	received := append(SymbolStream{}, symbolStream...)
+	start := 8 * (il2pPreamble + 3 + il2pHeaderSize + il2pHeaderParity)
+	for i := 0; i < 6; i++ { // scattered bit errors in the payload block
+		symbol := start + random.Intn(len(symbolStream)-start-8*(4+il2pPostamble))
+		received[symbol] = !received[symbol]
+	}
+	received[8*il2pPreamble+10] = !received[8*il2pPreamble+10] // in the sync word
+	received[8*il2pPreamble+30] = !received[8*il2pPreamble+30] // in the header
+	if decoded := received.DecodeIL2P(options); len(decoded) != 1 || !bytes.Equal(decoded[0], ax25data) {
+		t.Errorf("Expected the corrected frame got % x", decoded)
+	}
+
+	for i := range received { // reversed polarity
+		received[i] = !symbolStream[i]
+	}
+	if decoded := append(received, symbolStream...).DecodeIL2P(options); len(decoded) != 2 {
+		t.Errorf("Expected the inverted and normal frames got %d", len(decoded))
+	}
+}
+
+func TestIL2PHamming(t *testing.T) {
+	for nibble, codeword := range il2pHamming {
+		for bit := uint(0); bit < 7; bit++ {
+			if decoded, ok := il2pHammingDecode(codeword ^ 1<<bit); !ok || decoded != nibble {
+				t.Errorf("Nibble %x with bit %d wrong decoded as %x, %v", nibble, bit, decoded, ok)
+			}
+		}
+	}
+}
+
+func TestIL2PSpecExamples(t *testing.T) {
+	// the example S frame from KK4HEJ-7 to KA2DEW-2 and UI frame from KK4HEJ-15 to CQ in the IL2P specification,
+	// the UI frame is given with both C bits clear which is sent here as the response it is in IL2P
+	sFrame := []byte{0x96, 0x82, 0x64, 0x88, 0x8a, 0xae, 0xe4, 0x96, 0x96, 0x68, 0x90, 0x8a, 0x94, 0x6f, 0xb1}
+	uiFrame := Frame{Destination: Address{Callsign: "CQ"}, Source: Address{Callsign: "KK4HEJ", SSID: 15}, Type: FrameUI, PID: noLayer3}
+	examples := []struct {
+		Name      string
+		AX25Data  AX25Data
+		Header    []byte
+		Scrambled []byte // the header scrambled and followed by its parity
+	}{
+		{"S frame", AX25Data(appendFCS(sFrame)),
+			[]byte{0x2b, 0xa1, 0x12, 0x24, 0x25, 0x77, 0x6b, 0x2b, 0x54, 0x68, 0x25, 0x2a, 0x27},
+			[]byte{0x26, 0x57, 0x4d, 0x57, 0xf1, 0x96, 0xcc, 0x85, 0x42, 0xe7, 0x24, 0xf7, 0x2e, 0x8a, 0x97}},
+		{"UI frame", uiFrame.AX25Data(),
+			[]byte{0x63, 0xf1, 0x40, 0x40, 0x40, 0x00, 0x6b, 0x2b, 0x54, 0x28, 0x25, 0x2a, 0x0f},
+			[]byte{0x6a, 0xea, 0x9c, 0xc2, 0x01, 0x11, 0xfc, 0x14, 0x1f, 0xda, 0x6e, 0xf2, 0x53, 0x91, 0xbd}},
+	}
+	for _, example := range examples {
+		header, payload := il2pTranslate(example.AX25Data, false)
+		if !bytes.Equal(header[:], example.Header) || len(payload) != 0 {
+			t.Errorf("%s expected header % x got % x with %d payload bytes", example.Name, example.Header, header, len(payload))
+		}
+		if scrambled := il2pEncodeBlock(example.Header, il2pHeaderParity); !bytes.Equal(scrambled, example.Scrambled) {
+			t.Errorf("%s expected scrambled header % x got % x", example.Name, example.Scrambled, scrambled)
+		}
+		if ax25data, err := il2pReconstruct(example.Header, nil); err != nil || !bytes.Equal(ax25data, example.AX25Data) {
+			t.Errorf("%s expected % x got % x (%v)", example.Name, example.AX25Data, ax25data, err)
+		}
+	}
+}
+
+func TestIL2PPIDs(t *testing.T) {
+	testCases := []struct {
+		AX25 byte
+		IL2P int
+	}{
+		{0x20, 0x2}, {0x01, 0x3}, {0x06, 0x4}, {0x07, 0x5}, {0x08, 0x6},
+		{0xcc, 0xb}, {0xcd, 0xc}, {0xce, 0xd}, {0xcf, 0xe}, {0xf0, 0xf},
+		{0xc3, -1}, {0xca, -1}, {0x00, -1},
+	}
+	for _, testCase := range testCases {
+		if pid := il2pTranslatePID(testCase.AX25); pid != testCase.IL2P {
+			t.Errorf("AX.25 PID %#x expected IL2P PID %#x got %#x", testCase.AX25, testCase.IL2P, pid)
+		}
+	}
+}
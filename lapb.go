@@ -0,0 +1,924 @@
+package aprsgo
+
+// lapb.go contains the AX.25 connected mode data link: the state machine that connects with
+// SABM/UA, or SABME/UA for version 2.2 modulo 128 links, exchanges acknowledged and sequenced
+// I frames, recovers lost frames with REJ, SREJ and the T1 timer, and the net.Conn stream
+// interface on top of it
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// Errors reported by connected mode links
+var (
+	ErrConnectionRefused = errors.New("connection refused by the remote station")
+	ErrLinkFailure       = errors.New("no response from the remote station")
+	ErrLinkReset         = errors.New("link reset by the remote station")
+	ErrEndpointClosed    = errors.New("endpoint closed")
+)
+
+// LinkConfig holds the parameters of a connected mode link
+type LinkConfig struct {
+	Window          int           // k, the number of unacknowledged I frames outstanding, 1 to 7 or 127 if extended
+	MaxIFieldLength int           // N1, the maximum bytes of data in an I frame
+	Retries         int           // N2, the number of retries before the link fails
+	AckTimer        time.Duration // T1, the wait for an acknowledgement, scaled up for each digipeater
+	IdleTimer       time.Duration // T3, the link is checked after this long without traffic
+	ReceiveBuffer   int           // bytes waiting to be read before the remote station is told we are busy
+	Extended        bool          // version 2.2, connect with SABME for modulo 128 and negotiate parameters with XID
+	SREJ            bool          // request lost frames with selective reject, version 2.2 only
+}
+
+// DefaultLinkConfig holds the AX.25 default link parameters
+var DefaultLinkConfig = LinkConfig{
+	Window:          4,
+	MaxIFieldLength: 256,
+	Retries:         10,
+	AckTimer:        3 * time.Second,
+	IdleTimer:       300 * time.Second,
+	ReceiveBuffer:   4096,
+}
+
+const (
+	noLayer3         = pID // the protocol identifier of I frames carrying a plain byte stream
+	maxAcceptBacklog = 8   // incoming links waiting for Accept
+)
+
+type linkState int
+
+// data link states
+const (
+	stateDisconnected linkState = iota
+	stateAwaitingConnection
+	stateAwaitingRelease
+	stateConnected
+	stateTimerRecovery
+)
+
+// Endpoint is a station that makes and accepts connected mode links over a FrameTransport
+type Endpoint struct {
+	transport FrameTransport
+	local     Address
+	config    LinkConfig
+
+	mu         sync.Mutex // protects links, negotiated, listening and closed
+	links      map[string]*Conn
+	negotiated map[string]XIDParameters // parameters agreed by XID before the link is connected
+	listening  bool
+	closed     bool
+	accept     chan *Conn
+	done       chan struct{}
+}
+
+// NewEndpoint handles the connected mode frames addressed to local received on transport,
+// the endpoint owns the transport and closes it when closed
+func NewEndpoint(transport FrameTransport, local Address, config LinkConfig) *Endpoint {
+	ep := &Endpoint{
+		transport:  transport,
+		local:      local,
+		config:     config,
+		links:      make(map[string]*Conn),
+		negotiated: make(map[string]XIDParameters),
+		accept:     make(chan *Conn, maxAcceptBacklog),
+		done:       make(chan struct{}),
+	}
+	go ep.run()
+	return ep
+}
+
+// Listen starts accepting incoming links, until then connection requests are refused
+func (ep *Endpoint) Listen() {
+	ep.mu.Lock()
+	defer ep.mu.Unlock()
+	ep.listening = true
+}
+
+// Accept waits for the next incoming link, Listen must be called first
+func (ep *Endpoint) Accept() (*Conn, error) {
+	select {
+	case conn := <-ep.accept:
+		return conn, nil
+	case <-ep.done:
+		return nil, ErrEndpointClosed
+	}
+}
+
+// Dial connects to the remote station through any digipeaters and returns the link once established
+func (ep *Endpoint) Dial(remote Address, via ...Address) (*Conn, error) {
+	ep.mu.Lock()
+	if ep.closed {
+		ep.mu.Unlock()
+		return nil, ErrEndpointClosed
+	}
+	if _, ok := ep.links[remote.String()]; ok {
+		ep.mu.Unlock()
+		return nil, fmt.Errorf("already linked to %s", remote)
+	}
+	conn := ep.newConn(remote, via)
+	ep.mu.Unlock()
+
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+	conn.establish()
+	for conn.state == stateAwaitingConnection {
+		conn.cond.Wait()
+	}
+	if conn.state != stateConnected {
+		return nil, conn.err
+	}
+	return conn, nil
+}
+
+// Close disconnects every link without waiting for acknowledgement and closes the transport,
+// close the links first for an orderly disconnect
+func (ep *Endpoint) Close() error {
+	ep.shutdown()
+	return ep.transport.Close()
+}
+
+// run receives frames until the transport is closed
+func (ep *Endpoint) run() {
+	for {
+		ax25data, err := ep.transport.ReadFrame()
+		if err != nil {
+			ep.shutdown()
+			return
+		}
+		frame, err := DecodeFrame(ax25data)
+		if err != nil || !frame.Destination.Equal(ep.local) {
+			continue // corrupted frames and frames for other stations are dropped
+		}
+		if len(frame.Path) > 0 && !frame.Path[len(frame.Path)-1].Repeated {
+			continue // not yet repeated by the last digipeater
+		}
+		ep.receive(frame, ax25data)
+	}
+}
+
+// receive passes the frame to its link, or answers it if there is none
+func (ep *Endpoint) receive(frame Frame, ax25data AX25Data) {
+	key := frame.Source.String()
+	ep.mu.Lock()
+	conn, ok := ep.links[key]
+	accepting := ep.listening && !ep.closed && len(ep.accept) < cap(ep.accept)
+	if !ok && accepting && (frame.Type == FrameSABM || (frame.Type == FrameSABME && ep.config.Extended)) {
+		conn = ep.newConn(frame.Source, reversePath(frame.Path))
+		if agreed, ok := ep.negotiated[key]; ok {
+			conn.config = agreed.Apply(conn.config)
+			delete(ep.negotiated, key)
+		} else if frame.Type == FrameSABM {
+			conn.config.SREJ = false // a version 2.0 station
+		}
+		ep.mu.Unlock()
+		conn.mu.Lock()
+		conn.connected(frame.Type == FrameSABME)
+		conn.sendUnnumbered(FrameUA, false, frame.PollFinal)
+		conn.mu.Unlock()
+		ep.accept <- conn // only run sends to accept, so there is room
+		return
+	}
+	if !ok && accepting && frame.Type == FrameXID && frame.Command && ep.config.Extended {
+		// parameters negotiated before connecting are kept for the link
+		offered, err := DecodeXIDParameters(frame.Info)
+		if err != nil {
+			ep.mu.Unlock()
+			return
+		}
+		agreed := ep.config.XIDParameters().Negotiate(offered)
+		ep.negotiated[key] = agreed
+		ep.mu.Unlock()
+		xid := Frame{Destination: frame.Source, Source: ep.local, Path: reversePath(frame.Path), Type: FrameXID, PollFinal: frame.PollFinal, Info: agreed.Encode()}
+		ep.transport.WriteFrame(xid.AX25Data())
+		return
+	}
+	ep.mu.Unlock()
+
+	if ok {
+		if conn.isExtended() && (frame.Type == FrameI || frame.Type.isSupervisory()) {
+			var err error
+			if frame, err = DecodeExtendedFrame(ax25data); err != nil {
+				return
+			}
+		}
+		conn.receive(frame)
+		return
+	}
+	if frame.Command && (frame.Type == FrameSABM || frame.Type == FrameSABME || frame.Type == FrameDISC || frame.PollFinal) {
+		// answer as a station in the disconnected state
+		dm := Frame{Destination: frame.Source, Source: ep.local, Path: reversePath(frame.Path), Type: FrameDM, PollFinal: frame.PollFinal}
+		ep.transport.WriteFrame(dm.AX25Data())
+	}
+}
+
+// newConn creates a disconnected link to remote, ep.mu must be held
+func (ep *Endpoint) newConn(remote Address, path []Address) *Conn {
+	remote.Repeated = false
+	conn := &Conn{
+		endpoint: ep,
+		local:    ep.local,
+		remote:   remote,
+		path:     path,
+		config:   ep.config,
+		modulo:   8,
+	}
+	conn.cond = sync.NewCond(&conn.mu)
+	conn.sent = make([][]byte, conn.modulo)
+	conn.outOfOrder = make(map[int][]byte)
+	conn.srejSent = make(map[int]bool)
+	conn.t1.conn, conn.t3.conn = conn, conn
+	ep.links[remote.String()] = conn
+	return conn
+}
+
+// remove forgets a disconnected link
+func (ep *Endpoint) remove(conn *Conn) {
+	ep.mu.Lock()
+	defer ep.mu.Unlock()
+	if ep.links[conn.remote.String()] == conn {
+		delete(ep.links, conn.remote.String())
+	}
+}
+
+// shutdown disconnects every link once the endpoint is closed
+func (ep *Endpoint) shutdown() {
+	ep.mu.Lock()
+	if ep.closed {
+		ep.mu.Unlock()
+		return
+	}
+	ep.closed = true
+	close(ep.done)
+	var conns []*Conn
+	for _, conn := range ep.links {
+		conns = append(conns, conn)
+	}
+	ep.mu.Unlock()
+
+	for _, conn := range conns {
+		conn.mu.Lock()
+		conn.disconnected(ErrEndpointClosed)
+		conn.mu.Unlock()
+	}
+}
+
+// reversePath returns the digipeaters of a received frame in the order for replies
+func reversePath(path []Address) []Address {
+	var reversed []Address
+	for i := len(path) - 1; i >= 0; i-- {
+		digipeater := path[i]
+		digipeater.Repeated = false
+		reversed = append(reversed, digipeater)
+	}
+	return reversed
+}
+
+// Conn is a connected mode link to a remote station, it implements net.Conn
+type Conn struct {
+	endpoint *Endpoint
+	local    Address
+	remote   Address
+	path     []Address // digipeaters to the remote station
+	config   LinkConfig
+	modulo   int // sequence numbers count modulo 8, or 128 once connected with SABME
+
+	mu              sync.Mutex
+	cond            *sync.Cond // signalled on every change of state, buffers or deadlines
+	state           linkState
+	vs, vr, va      int      // send state, receive state and acknowledge state variables
+	rc              int      // retry count
+	sent            [][]byte // the information of sent I frames by N(S), kept until acknowledged
+	queue           [][]byte // information waiting to be sent
+	readBuffer      []byte
+	outOfOrder      map[int][]byte // I frames received after a lost frame, by N(S), kept while waiting for SREJ
+	srejSent        map[int]bool   // the lost frames requested by SREJ
+	version20       bool           // the remote station rejected SABME, connect with SABM
+	peerBusy        bool           // the remote station sent RNR
+	ownBusy         bool           // we sent RNR as the read buffer is full
+	rejectException bool           // a REJ was sent and the expected frame has not arrived yet
+	ackPending      bool           // received I frames have not been acknowledged yet
+	closing         bool
+	err             error // why the link was disconnected, nil if closed normally
+	t1, t3          linkTimer
+	readDeadline    time.Time
+	writeDeadline   time.Time
+}
+
+// Read reads data received on the link, io.EOF is returned once the remote station disconnects
+func (c *Conn) Read(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for len(c.readBuffer) == 0 {
+		if c.closing {
+			return 0, net.ErrClosed
+		}
+		if c.state == stateDisconnected {
+			if c.err != nil {
+				return 0, c.err
+			}
+			return 0, io.EOF
+		}
+		if !c.wait(c.readDeadline) {
+			return 0, os.ErrDeadlineExceeded
+		}
+	}
+	n := copy(p, c.readBuffer)
+	c.readBuffer = c.readBuffer[n:]
+	if c.ownBusy && len(c.readBuffer) <= c.config.ReceiveBuffer/2 && c.state >= stateConnected {
+		c.ownBusy = false
+		c.sendSupervisory(FrameRR, false, false)
+	}
+	return n, nil
+}
+
+// Write queues data to be sent in I frames of at most MaxIFieldLength bytes,
+// it blocks while more than a window of frames is waiting to be sent
+func (c *Conn) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	written := 0
+	for len(p) > 0 {
+		if err := c.writable(); err != nil {
+			return written, err
+		}
+		if len(c.queue) >= c.config.Window {
+			if !c.wait(c.writeDeadline) {
+				return written, os.ErrDeadlineExceeded
+			}
+			continue
+		}
+		n := len(p)
+		if n > c.config.MaxIFieldLength {
+			n = c.config.MaxIFieldLength
+		}
+		c.queue = append(c.queue, append([]byte(nil), p[:n]...))
+		p, written = p[n:], written+n
+		c.transmit()
+	}
+	return written, nil
+}
+
+// writable returns the error for writing to a closed or disconnected link
+func (c *Conn) writable() error {
+	switch {
+	case c.closing:
+		return net.ErrClosed
+	case c.state == stateDisconnected && c.err != nil:
+		return c.err
+	case c.state == stateDisconnected:
+		return io.ErrClosedPipe
+	}
+	return nil
+}
+
+// Close waits for all written data to be acknowledged and disconnects the link
+func (c *Conn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closing {
+		return net.ErrClosed
+	}
+	c.closing = true
+	c.cond.Broadcast()
+	c.release()
+	for c.state != stateDisconnected {
+		c.cond.Wait()
+	}
+	return nil
+}
+
+// LocalAddr returns the local station's Address
+func (c *Conn) LocalAddr() net.Addr {
+	return c.local
+}
+
+// RemoteAddr returns the remote station's Address
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.remote
+}
+
+// SetDeadline sets the read and write deadlines
+func (c *Conn) SetDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.readDeadline, c.writeDeadline = t, t
+	c.cond.Broadcast()
+	return nil
+}
+
+// SetReadDeadline sets the deadline for Read calls, the zero time waits forever
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.readDeadline = t
+	c.cond.Broadcast()
+	return nil
+}
+
+// SetWriteDeadline sets the deadline for Write calls waiting for room to queue data
+func (c *Conn) SetWriteDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.writeDeadline = t
+	c.cond.Broadcast()
+	return nil
+}
+
+// wait blocks until the link changes, and returns false once the deadline has passed
+func (c *Conn) wait(deadline time.Time) bool {
+	if deadline.IsZero() {
+		c.cond.Wait()
+		return true
+	}
+	remaining := time.Until(deadline)
+	if remaining <= 0 {
+		return false
+	}
+	timer := time.AfterFunc(remaining, func() {
+		c.mu.Lock()
+		c.cond.Broadcast()
+		c.mu.Unlock()
+	})
+	c.cond.Wait()
+	timer.Stop()
+	return true
+}
+
+// receive handles a frame from the remote station
+func (c *Conn) receive(frame Frame) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	defer c.cond.Broadcast()
+
+	if frame.Type == FrameXID {
+		c.receiveXID(frame)
+		return
+	}
+	switch c.state {
+	case stateAwaitingConnection:
+		c.receiveAwaitingConnection(frame)
+	case stateAwaitingRelease:
+		c.receiveAwaitingRelease(frame)
+	case stateConnected, stateTimerRecovery:
+		c.receiveConnected(frame)
+	}
+}
+
+func (c *Conn) receiveAwaitingConnection(frame Frame) {
+	extended := c.config.Extended && !c.version20 // SABME was sent
+	switch frame.Type {
+	case FrameSABM, FrameSABME: // both stations connecting at once
+		c.sendUnnumbered(FrameUA, false, frame.PollFinal)
+	case FrameDISC:
+		c.sendUnnumbered(FrameDM, false, frame.PollFinal)
+	case FrameUA:
+		if frame.PollFinal {
+			c.connected(extended)
+			if extended {
+				c.send(Frame{Type: FrameXID, Command: true, PollFinal: true, Info: c.config.XIDParameters().Encode()})
+			}
+			c.transmit()
+		}
+	case FrameDM, FrameFRMR:
+		if extended { // a version 2.0 station, connect modulo 8 instead
+			c.version20 = true
+			c.config.SREJ = false
+			c.establish()
+		} else if frame.Type == FrameDM && frame.PollFinal {
+			c.disconnected(ErrConnectionRefused)
+		}
+	}
+}
+
+// receiveXID agrees the link parameters offered in an XID command, or applies those agreed in a response
+func (c *Conn) receiveXID(frame Frame) {
+	offered, err := DecodeXIDParameters(frame.Info)
+	if err != nil {
+		return
+	}
+	if !frame.Command {
+		c.config = offered.Apply(c.config)
+		c.limitWindow()
+		return
+	}
+	agreed := c.config.XIDParameters().Negotiate(offered)
+	c.config = agreed.Apply(c.config)
+	c.limitWindow()
+	c.send(Frame{Type: FrameXID, PollFinal: frame.PollFinal, Info: agreed.Encode()})
+}
+
+// limitWindow keeps the window within the sequence numbers available
+func (c *Conn) limitWindow() {
+	if c.config.Window > c.modulo-1 {
+		c.config.Window = c.modulo - 1
+	}
+	if c.config.Window < 1 {
+		c.config.Window = 1
+	}
+}
+
+func (c *Conn) receiveAwaitingRelease(frame Frame) {
+	switch frame.Type {
+	case FrameSABM, FrameSABME:
+		c.sendUnnumbered(FrameDM, false, frame.PollFinal)
+	case FrameDISC:
+		c.sendUnnumbered(FrameUA, false, frame.PollFinal)
+	case FrameUA, FrameDM:
+		if frame.PollFinal {
+			c.disconnected(nil)
+		}
+	case FrameI, FrameRR, FrameRNR, FrameREJ, FrameSREJ:
+		if frame.Command && frame.PollFinal {
+			c.sendUnnumbered(FrameDM, false, true)
+		}
+	}
+}
+
+func (c *Conn) receiveConnected(frame Frame) {
+	switch frame.Type {
+	case FrameSABM, FrameSABME: // the remote station reset the link
+		c.sendUnnumbered(FrameUA, false, frame.PollFinal)
+		c.requeue()
+		c.connected(frame.Type == FrameSABME)
+	case FrameDISC:
+		c.sendUnnumbered(FrameUA, false, frame.PollFinal)
+		c.disconnected(nil)
+		return
+	case FrameDM:
+		c.disconnected(ErrLinkReset)
+		return
+	case FrameFRMR:
+		c.reestablish()
+		return
+	case FrameRR, FrameRNR, FrameREJ:
+		c.receiveSupervisory(frame)
+	case FrameSREJ:
+		c.receiveSelectiveReject(frame)
+	case FrameI:
+		c.receiveInformation(frame)
+	}
+	c.transmit()
+	if c.ackPending {
+		c.sendSupervisory(c.readyType(), false, false)
+	}
+}
+
+func (c *Conn) receiveSupervisory(frame Frame) {
+	c.peerBusy = frame.Type == FrameRNR
+	if !c.validNR(frame.NR) {
+		c.nrError(frame)
+		return
+	}
+	if frame.Command && frame.PollFinal {
+		c.sendSupervisory(c.readyType(), false, true)
+	}
+	if c.state == stateTimerRecovery {
+		c.acknowledge(frame.NR)
+		if !frame.Command && frame.PollFinal { // the answer to our poll
+			c.t1.stop()
+			c.state, c.rc = stateConnected, 0
+			c.requeue() // resend anything not acknowledged
+			c.t3.start(c.config.IdleTimer, c.t3Expired)
+		}
+		return
+	}
+	c.checkAcknowledged(frame.NR)
+	if frame.Type == FrameREJ {
+		c.t1.stop()
+		c.requeue()
+	}
+}
+
+// receiveSelectiveReject sends the single I frame requested again, without acknowledging any frames
+func (c *Conn) receiveSelectiveReject(frame Frame) {
+	c.peerBusy = false
+	if (frame.NR-c.va+c.modulo)%c.modulo >= (c.vs-c.va+c.modulo)%c.modulo {
+		return // already acknowledged
+	}
+	if info := c.sent[frame.NR]; info != nil {
+		c.send(Frame{Type: FrameI, Command: true, NS: frame.NR, NR: c.vr, PID: noLayer3, Info: info})
+		c.startT1()
+	}
+}
+
+func (c *Conn) receiveInformation(frame Frame) {
+	if !frame.Command {
+		return // I frames are always commands
+	}
+	if !c.validNR(frame.NR) {
+		c.nrError(frame)
+		return
+	}
+	if c.state == stateTimerRecovery {
+		c.acknowledge(frame.NR)
+	} else {
+		c.checkAcknowledged(frame.NR)
+	}
+
+	switch {
+	case c.ownBusy: // discard the frame, it is resent once we are ready
+		if frame.PollFinal {
+			c.sendSupervisory(FrameRNR, false, true)
+		}
+	case frame.NS == c.vr:
+		c.vr = (c.vr + 1) % c.modulo
+		c.rejectException = false
+		c.readBuffer = append(c.readBuffer, frame.Info...)
+		delete(c.srejSent, frame.NS)
+		for info, ok := c.outOfOrder[c.vr]; ok; info, ok = c.outOfOrder[c.vr] { // frames held after the lost one
+			delete(c.outOfOrder, c.vr)
+			c.readBuffer = append(c.readBuffer, info...)
+			c.vr = (c.vr + 1) % c.modulo
+		}
+		if len(c.readBuffer) >= c.config.ReceiveBuffer {
+			c.ownBusy = true
+		}
+		c.ackPending = true
+		if frame.PollFinal {
+			c.sendSupervisory(c.readyType(), false, true)
+		}
+	case c.config.SREJ && (frame.NS-c.vr+c.modulo)%c.modulo < c.modulo/2:
+		c.receiveOutOfOrder(frame)
+	case c.rejectException: // already asked for the missing frame
+		if frame.PollFinal {
+			c.sendSupervisory(c.readyType(), false, true)
+		}
+	default:
+		c.rejectException = true
+		c.sendSupervisory(FrameREJ, false, frame.PollFinal)
+	}
+}
+
+// receiveOutOfOrder holds an I frame received after a lost frame and asks for each missing frame with SREJ
+func (c *Conn) receiveOutOfOrder(frame Frame) {
+	if _, ok := c.outOfOrder[frame.NS]; !ok {
+		c.outOfOrder[frame.NS] = frame.Info
+	}
+	for n := c.vr; n != frame.NS; n = (n + 1) % c.modulo {
+		if _, ok := c.outOfOrder[n]; !ok && !c.srejSent[n] {
+			c.srejSent[n] = true
+			c.send(Frame{Type: FrameSREJ, NR: n})
+		}
+	}
+	if frame.PollFinal {
+		c.sendSupervisory(c.readyType(), false, true)
+	}
+}
+
+// validNR checks V(A) <= N(R) <= V(S)
+func (c *Conn) validNR(nr int) bool {
+	return (nr-c.va+c.modulo)%c.modulo <= (c.vs-c.va+c.modulo)%c.modulo
+}
+
+// nrError reports an acknowledgement of a frame never sent and resets the link,
+// version 2.2 links reset without sending FRMR
+func (c *Conn) nrError(frame Frame) {
+	if c.modulo == 128 {
+		c.reestablish()
+		return
+	}
+	const frmrZ = 0x08 // invalid N(R)
+	status := byte(c.vr)<<5 | byte(c.vs)<<1
+	if !frame.Command {
+		status |= controlPF // the rejected frame was a response
+	}
+	c.send(Frame{Type: FrameFRMR, Info: []byte{frame.control(), status, frmrZ}})
+	c.reestablish()
+}
+
+// checkAcknowledged handles N(R) in the connected state, restarting T1 while frames are outstanding
+func (c *Conn) checkAcknowledged(nr int) {
+	switch {
+	case c.peerBusy:
+		c.acknowledge(nr)
+		if !c.t1.running {
+			c.startT1() // poll the busy station until it is ready
+		}
+	case nr == c.vs:
+		c.acknowledge(nr)
+		c.t1.stop()
+		c.t3.start(c.config.IdleTimer, c.t3Expired)
+	case nr != c.va:
+		c.acknowledge(nr)
+		c.startT1()
+	}
+}
+
+// acknowledge releases the sent frames up to N(R)
+func (c *Conn) acknowledge(nr int) {
+	for c.va != nr {
+		c.sent[c.va] = nil
+		c.va = (c.va + 1) % c.modulo
+	}
+}
+
+// requeue puts the unacknowledged frames back at the front of the queue to be sent again
+func (c *Conn) requeue() {
+	var resend [][]byte
+	for n := c.va; n != c.vs; n = (n + 1) % c.modulo {
+		resend = append(resend, c.sent[n])
+		c.sent[n] = nil
+	}
+	c.queue = append(resend, c.queue...)
+	c.vs = c.va
+}
+
+// transmit sends queued I frames while the window is open, then disconnects if closing
+func (c *Conn) transmit() {
+	for c.state == stateConnected && !c.peerBusy && len(c.queue) > 0 &&
+		(c.vs-c.va+c.modulo)%c.modulo < c.config.Window {
+		info := c.queue[0]
+		c.queue = c.queue[1:]
+		c.sent[c.vs] = info
+		c.send(Frame{Type: FrameI, Command: true, NS: c.vs, NR: c.vr, PID: noLayer3, Info: info})
+		c.vs = (c.vs + 1) % c.modulo
+		if !c.t1.running {
+			c.t3.stop()
+			c.startT1()
+		}
+	}
+	c.cond.Broadcast()
+	c.release()
+}
+
+// release sends DISC once closing and everything written has been acknowledged
+func (c *Conn) release() {
+	if !c.closing || c.state != stateConnected || len(c.queue) > 0 || c.va != c.vs {
+		return
+	}
+	c.t3.stop()
+	c.rc = 0
+	c.sendUnnumbered(FrameDISC, true, true)
+	c.state = stateAwaitingRelease
+	c.startT1()
+}
+
+// establish sends SABME, or SABM to a version 2.0 station, to connect
+func (c *Conn) establish() {
+	c.rc = 0
+	c.sendUnnumbered(c.connectType(), true, true)
+	c.state = stateAwaitingConnection
+	c.t3.stop()
+	c.startT1()
+}
+
+// reestablish connects again after a protocol error, data not yet acknowledged is sent again
+func (c *Conn) reestablish() {
+	c.requeue()
+	c.establish()
+}
+
+// connectType is the frame sent to connect
+func (c *Conn) connectType() FrameType {
+	if c.config.Extended && !c.version20 {
+		return FrameSABME
+	}
+	return FrameSABM
+}
+
+// isExtended returns true for a modulo 128 link
+func (c *Conn) isExtended() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.modulo == 128
+}
+
+// connected enters the connected state with all sequence numbers zero, counting modulo 128 if extended
+func (c *Conn) connected(extended bool) {
+	c.state = stateConnected
+	c.modulo = 8
+	if extended {
+		c.modulo = 128
+	}
+	c.sent = make([][]byte, c.modulo)
+	c.outOfOrder, c.srejSent = make(map[int][]byte), make(map[int]bool)
+	c.limitWindow()
+	c.vs, c.vr, c.va, c.rc = 0, 0, 0, 0
+	c.peerBusy, c.rejectException, c.ackPending = false, false, false
+	c.t1.stop()
+	c.t3.start(c.config.IdleTimer, c.t3Expired)
+	c.cond.Broadcast()
+}
+
+// disconnected enters the disconnected state, err is the reason unless closed normally
+func (c *Conn) disconnected(err error) {
+	if c.state == stateDisconnected && c.err != nil {
+		return
+	}
+	c.state = stateDisconnected
+	if c.err == nil {
+		c.err = err
+	}
+	c.t1.stop()
+	c.t3.stop()
+	c.endpoint.remove(c)
+	c.cond.Broadcast()
+}
+
+// t1Expired retries the last command, or polls the remote station for its state
+func (c *Conn) t1Expired() {
+	if c.rc >= c.config.Retries && c.state != stateConnected {
+		if c.state == stateTimerRecovery {
+			c.sendUnnumbered(FrameDM, false, false)
+		}
+		if c.state == stateAwaitingRelease {
+			c.disconnected(nil)
+		} else {
+			c.disconnected(ErrLinkFailure)
+		}
+		return
+	}
+	c.rc++
+	switch c.state {
+	case stateAwaitingConnection:
+		c.sendUnnumbered(c.connectType(), true, true)
+		c.startT1()
+	case stateAwaitingRelease:
+		c.sendUnnumbered(FrameDISC, true, true)
+		c.startT1()
+	case stateConnected, stateTimerRecovery:
+		if c.state == stateConnected {
+			c.rc = 1
+		}
+		c.state = stateTimerRecovery
+		c.sendSupervisory(c.readyType(), true, true)
+		c.startT1()
+	}
+}
+
+// t3Expired polls the remote station after the link has been idle
+func (c *Conn) t3Expired() {
+	if c.state != stateConnected {
+		return
+	}
+	c.state, c.rc = stateTimerRecovery, 0
+	c.sendSupervisory(c.readyType(), true, true)
+	c.startT1()
+}
+
+// startT1 starts the acknowledgement timer, allowing for each digipeater on the way and back
+func (c *Conn) startT1() {
+	c.t1.start(c.config.AckTimer*time.Duration(1+2*len(c.path)), c.t1Expired)
+}
+
+// readyType is RNR while the read buffer is full, otherwise RR
+func (c *Conn) readyType() FrameType {
+	if c.ownBusy {
+		return FrameRNR
+	}
+	return FrameRR
+}
+
+func (c *Conn) sendUnnumbered(frameType FrameType, command bool, pollFinal bool) {
+	c.send(Frame{Type: frameType, Command: command, PollFinal: pollFinal})
+}
+
+func (c *Conn) sendSupervisory(frameType FrameType, command bool, pollFinal bool) {
+	c.send(Frame{Type: frameType, Command: command, PollFinal: pollFinal, NR: c.vr})
+}
+
+// send addresses the frame to the remote station and writes it to the transport
+func (c *Conn) send(frame Frame) {
+	frame.Destination, frame.Source, frame.Path = c.remote, c.local, c.path
+	frame.Extended = c.modulo == 128
+	if frame.Type == FrameI || frame.Type == FrameRR || frame.Type == FrameRNR || frame.Type == FrameREJ {
+		c.ackPending = false // N(R) acknowledges everything received
+	}
+	c.endpoint.transport.WriteFrame(frame.AX25Data()) // a failed transport closes the endpoint
+}
+
+// linkTimer calls expired with the link locked when it runs out, unless stopped or restarted first
+type linkTimer struct {
+	conn    *Conn
+	timer   *time.Timer
+	gen     int // incremented on every start and stop, so a stale expiry is ignored
+	running bool
+}
+
+func (t *linkTimer) start(d time.Duration, expired func()) {
+	t.stop()
+	t.running = true
+	gen := t.gen
+	t.timer = time.AfterFunc(d, func() {
+		t.conn.mu.Lock()
+		defer t.conn.mu.Unlock()
+		if t.running && t.gen == gen {
+			t.running = false
+			expired()
+			t.conn.cond.Broadcast()
+		}
+	})
+}
+
+func (t *linkTimer) stop() {
+	t.gen++
+	t.running = false
+	if t.timer != nil {
+		t.timer.Stop()
+	}
+}
@@ -0,0 +1,285 @@
+package aprsgo
+
+import (
+	"bytes"
+	"io"
+	"sync"
+	"testing"
+	"time"
+)
+
+var testLinkConfig = LinkConfig{
+	Window:          3,
+	MaxIFieldLength: 64,
+	Retries:         10,
+	AckTimer:        20 * time.Millisecond,
+	IdleTimer:       200 * time.Millisecond,
+	ReceiveBuffer:   256,
+}
+
+// lossyTransport drops every n-th frame written
+type lossyTransport struct {
+	FrameTransport
+	mu    sync.Mutex
+	n     int
+	count int
+}
+
+func (l *lossyTransport) WriteFrame(ax25data AX25Data) error {
+	l.mu.Lock()
+	l.count++
+	drop := l.n > 0 && l.count%l.n == 0
+	l.mu.Unlock()
+	if drop {
+		return nil
+	}
+	return l.FrameTransport.WriteFrame(ax25data)
+}
+
+// linkedEndpoints returns a listening endpoint for N0CALL-7 and an endpoint for W1AW connected through a pipe
+// that drops every dropEvery-th frame in each direction
+func linkedEndpoints(dropEvery int) (*Endpoint, *Endpoint) {
+	a, b := NewFramePipe()
+	server := NewEndpoint(&lossyTransport{FrameTransport: a, n: dropEvery}, Address{Callsign: "N0CALL", SSID: 7}, testLinkConfig)
+	client := NewEndpoint(&lossyTransport{FrameTransport: b, n: dropEvery}, Address{Callsign: "W1AW"}, testLinkConfig)
+	server.Listen()
+	return server, client
+}
+
+func testLinkTransfer(t *testing.T, server, client *Endpoint) {
+	defer server.Close()
+	defer client.Close()
+
+	message := bytes.Repeat([]byte("The quick brown fox jumps over the lazy dog. "), 40)
+	received := make(chan []byte)
+	go func() {
+		conn, err := server.Accept()
+		if err != nil {
+			t.Errorf("Accept failed with error %v", err)
+			close(received)
+			return
+		}
+		if conn.RemoteAddr().String() != "W1AW" {
+			t.Errorf("Expected connection from W1AW got %s", conn.RemoteAddr())
+		}
+		data, err := io.ReadAll(conn) // until the client disconnects
+		if err != nil {
+			t.Errorf("Reading failed with error %v", err)
+		}
+		received <- data
+	}()
+
+	conn, err := client.Dial(Address{Callsign: "N0CALL", SSID: 7})
+	if err != nil {
+		t.Fatalf("Dial failed with error %v", err)
+	}
+	if n, err := conn.Write(message); err != nil || n != len(message) {
+		t.Fatalf("Write returned %d, %v", n, err)
+	}
+	if err := conn.Close(); err != nil {
+		t.Fatalf("Close failed with error %v", err)
+	}
+	select {
+	case data := <-received:
+		if !bytes.Equal(data, message) {
+			t.Errorf("Received %d bytes not matching the %d sent", len(data), len(message))
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("Timed out waiting for the data")
+	}
+}
+
+func TestLinkTransfer(t *testing.T) {
+	server, client := linkedEndpoints(0)
+	testLinkTransfer(t, server, client)
+}
+
+func TestLinkTransferWithLoss(t *testing.T) {
+	server, client := linkedEndpoints(7) // exercises REJ and T1 recovery
+	testLinkTransfer(t, server, client)
+}
+
+var testExtendedLinkConfig = LinkConfig{
+	Window:          16,
+	MaxIFieldLength: 64,
+	Retries:         10,
+	AckTimer:        20 * time.Millisecond,
+	IdleTimer:       200 * time.Millisecond,
+	ReceiveBuffer:   2048,
+	Extended:        true,
+	SREJ:            true,
+}
+
+// extendedEndpoints returns linked endpoints as linkedEndpoints with the given configurations
+func extendedEndpoints(dropEvery int, serverConfig, clientConfig LinkConfig) (*Endpoint, *Endpoint) {
+	a, b := NewFramePipe()
+	server := NewEndpoint(&lossyTransport{FrameTransport: a, n: dropEvery}, Address{Callsign: "N0CALL", SSID: 7}, serverConfig)
+	client := NewEndpoint(&lossyTransport{FrameTransport: b, n: dropEvery}, Address{Callsign: "W1AW"}, clientConfig)
+	server.Listen()
+	return server, client
+}
+
+func TestLinkTransferExtended(t *testing.T) {
+	server, client := extendedEndpoints(0, testExtendedLinkConfig, testExtendedLinkConfig)
+	testLinkTransfer(t, server, client)
+}
+
+func TestLinkTransferExtendedWithLoss(t *testing.T) {
+	server, client := extendedEndpoints(7, testExtendedLinkConfig, testExtendedLinkConfig) // exercises SREJ
+	testLinkTransfer(t, server, client)
+}
+
+func TestLinkExtendedNegotiation(t *testing.T) {
+	testCases := []struct {
+		Server, Client LinkConfig
+		Modulo, Window int
+		SREJ           bool
+	}{
+		{Server: testExtendedLinkConfig, Client: testExtendedLinkConfig, Modulo: 128, Window: 16, SREJ: true},
+		{Server: testLinkConfig, Client: testExtendedLinkConfig, Modulo: 8, Window: 7}, // falls back to SABM
+		{Server: testExtendedLinkConfig, Client: testLinkConfig, Modulo: 8, Window: 3}, // the server accepts SABM
+		{Server: func() LinkConfig { c := testExtendedLinkConfig; c.Window, c.SREJ = 8, false; return c }(),
+			Client: testExtendedLinkConfig, Modulo: 128, Window: 8},
+	}
+	for i, testCase := range testCases {
+		server, client := extendedEndpoints(0, testCase.Server, testCase.Client)
+		accepted := make(chan *Conn, 1)
+		go func() {
+			conn, _ := server.Accept()
+			accepted <- conn
+		}()
+		conn, err := client.Dial(Address{Callsign: "N0CALL", SSID: 7})
+		if err != nil {
+			t.Errorf("Case %d: Dial failed with error %v", i, err)
+			server.Close()
+			client.Close()
+			continue
+		}
+		remote := <-accepted
+		// the XID exchange follows the connection, give it time to complete
+		deadline := time.Now().Add(time.Second)
+		for {
+			conn.mu.Lock()
+			modulo, window, srej := conn.modulo, conn.config.Window, conn.config.SREJ
+			conn.mu.Unlock()
+			remote.mu.Lock()
+			remoteModulo := remote.modulo
+			remote.mu.Unlock()
+			if modulo == testCase.Modulo && remoteModulo == testCase.Modulo && window == testCase.Window && srej == testCase.SREJ {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Errorf("Case %d: expected modulo %d window %d SREJ %v got modulo %d/%d window %d SREJ %v",
+					i, testCase.Modulo, testCase.Window, testCase.SREJ, modulo, remoteModulo, window, srej)
+				break
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		conn.Close()
+		server.Close()
+		client.Close()
+	}
+}
+
+func TestLinkEcho(t *testing.T) {
+	server, client := linkedEndpoints(0)
+	defer server.Close()
+	defer client.Close()
+
+	go func() {
+		conn, err := server.Accept()
+		if err != nil {
+			return
+		}
+		io.Copy(conn, conn)
+		conn.Close()
+	}()
+
+	conn, err := client.Dial(Address{Callsign: "N0CALL", SSID: 7})
+	if err != nil {
+		t.Fatalf("Dial failed with error %v", err)
+	}
+	for _, line := range []string{"hello\r", "bye\r"} {
+		if _, err := conn.Write([]byte(line)); err != nil {
+			t.Fatalf("Write failed with error %v", err)
+		}
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		reply := make([]byte, len(line))
+		if _, err := io.ReadFull(conn, reply); err != nil || string(reply) != line {
+			t.Errorf("Expected echo %q got %q, %v", line, reply, err)
+		}
+	}
+
+	conn.SetReadDeadline(time.Now().Add(10 * time.Millisecond))
+	if _, err := conn.Read(make([]byte, 1)); err == nil {
+		t.Errorf("Read should time out with nothing received")
+	}
+	conn.Close()
+}
+
+func TestLinkRefused(t *testing.T) {
+	a, b := NewFramePipe()
+	server := NewEndpoint(a, Address{Callsign: "N0CALL"}, testLinkConfig) // not listening
+	client := NewEndpoint(b, Address{Callsign: "W1AW"}, testLinkConfig)
+	defer server.Close()
+	defer client.Close()
+
+	if _, err := client.Dial(Address{Callsign: "N0CALL"}); err != ErrConnectionRefused {
+		t.Errorf("Expected %v got %v", ErrConnectionRefused, err)
+	}
+
+	config := testLinkConfig
+	config.Retries = 2
+	nobody, _ := NewFramePipe() // no station at the other end
+	silent := NewEndpoint(nobody, Address{Callsign: "W1AW"}, config)
+	defer silent.Close()
+	start := time.Now()
+	if _, err := silent.Dial(Address{Callsign: "N0CALL"}); err != ErrLinkFailure {
+		t.Errorf("Expected %v got %v", ErrLinkFailure, err)
+	}
+	if elapsed := time.Since(start); elapsed < 3*config.AckTimer {
+		t.Errorf("Dial gave up after %v, before retrying", elapsed)
+	}
+}
+
+func TestLinkBusy(t *testing.T) {
+	server, client := linkedEndpoints(0)
+	defer server.Close()
+	defer client.Close()
+
+	accepted := make(chan *Conn)
+	go func() {
+		conn, _ := server.Accept()
+		accepted <- conn
+	}()
+	conn, err := client.Dial(Address{Callsign: "N0CALL", SSID: 7})
+	if err != nil {
+		t.Fatalf("Dial failed with error %v", err)
+	}
+	remote := <-accepted
+
+	// write more than the receive buffer before the other end reads anything
+	message := bytes.Repeat([]byte("0123456789"), 100)
+	done := make(chan error)
+	go func() {
+		_, err := conn.Write(message)
+		done <- err
+	}()
+	time.Sleep(100 * time.Millisecond)
+	remote.mu.Lock()
+	busy, buffered := remote.ownBusy, len(remote.readBuffer)
+	remote.mu.Unlock()
+	if !busy || buffered > testLinkConfig.ReceiveBuffer+testLinkConfig.MaxIFieldLength {
+		t.Errorf("Expected the receiver to be busy with at most a frame over the buffer, busy %v with %d bytes", busy, buffered)
+	}
+
+	data := make([]byte, len(message))
+	remote.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := io.ReadFull(remote, data); err != nil || !bytes.Equal(data, message) {
+		t.Errorf("Expected all data after clearing busy, got %v", err)
+	}
+	if err := <-done; err != nil {
+		t.Errorf("Write failed with error %v", err)
+	}
+	conn.Close()
+}
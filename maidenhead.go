@@ -0,0 +1,179 @@
+package aprsgo
+
+// maidenhead.go contains routines for converting between latitude/longitude and
+// Maidenhead grid locators and for producing APRS grid square position reports
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+const gridSquareDataTypeIdentifier = '[' // Maidenhead grid locator beacon
+
+// LocatorError reports a malformed Maidenhead locator
+type LocatorError struct {
+	Locator string
+	Reason  string
+}
+
+func (e *LocatorError) Error() string {
+	return fmt.Sprintf("invalid Maidenhead locator %q: %s", e.Locator, e.Reason)
+}
+
+// LatLongToLocator converts latitude and longitude to a Maidenhead locator of 4, 6 or 8 characters
+func LatLongToLocator(lat, long float64, length int) (string, error) {
+	if length != 4 && length != 6 && length != 8 {
+		return "", fmt.Errorf("Maidenhead locators have 4, 6 or 8 characters, not %d", length)
+	}
+	if lat < -90 || lat > 90 || math.IsNaN(lat) {
+		return "", fmt.Errorf("latitude %v out of range", lat)
+	}
+	if long < -180 || long > 180 || math.IsNaN(long) {
+		return "", fmt.Errorf("longitude %v out of range", long)
+	}
+	// work in units of the smallest square, 1/240 degree of latitude and 1/120 degree of longitude
+	latUnits := int(math.Floor((lat + 90) * 240))
+	longUnits := int(math.Floor((long + 180) * 120))
+	if latUnits >= 180*240 { // the north pole and antimeridian belong to the last square
+		latUnits = 180*240 - 1
+	}
+	if longUnits >= 360*120 {
+		longUnits = 360*120 - 1
+	}
+
+	locator := []byte{
+		byte('A' + longUnits/2400), // field, 20 degrees of longitude
+		byte('A' + latUnits/2400),  // field, 10 degrees of latitude
+		byte('0' + longUnits%2400/240),
+		byte('0' + latUnits%2400/240),
+		byte('A' + longUnits%240/10), // subsquare, 5 minutes of longitude
+		byte('A' + latUnits%240/10),  // subsquare, 2.5 minutes of latitude
+		byte('0' + longUnits%10),
+		byte('0' + latUnits%10),
+	}
+	return string(locator[:length]), nil
+}
+
+// LocatorToLatLong returns the latitude and longitude of the center of a 4, 6 or 8 character locator
+func LocatorToLatLong(locator string) (float64, float64, error) {
+	if err := ValidateLocator(locator); err != nil {
+		return 0, 0, err
+	}
+	locator = strings.ToUpper(locator)
+	long := float64(locator[0]-'A')*20 + float64(locator[2]-'0')*2
+	lat := float64(locator[1]-'A')*10 + float64(locator[3]-'0')
+	longSize, latSize := 2.0, 1.0 // the size of the square in degrees
+	if len(locator) >= 6 {
+		longSize, latSize = longSize/24, latSize/24
+		long += float64(locator[4]-'A') * longSize
+		lat += float64(locator[5]-'A') * latSize
+	}
+	if len(locator) == 8 {
+		longSize, latSize = longSize/10, latSize/10
+		long += float64(locator[6]-'0') * longSize
+		lat += float64(locator[7]-'0') * latSize
+	}
+	return lat + latSize/2 - 90, long + longSize/2 - 180, nil
+}
+
+// ValidateLocator returns a *LocatorError if locator is not a 4, 6 or 8 character Maidenhead locator
+func ValidateLocator(locator string) error {
+	if len(locator) != 4 && len(locator) != 6 && len(locator) != 8 {
+		return &LocatorError{Locator: locator, Reason: "must have 4, 6 or 8 characters"}
+	}
+	upper := strings.ToUpper(locator)
+	for i := 0; i < len(upper); i++ {
+		c := upper[i]
+		switch i {
+		case 0, 1:
+			if c < 'A' || c > 'R' {
+				return &LocatorError{Locator: locator, Reason: "field must be A to R"}
+			}
+		case 2, 3, 6, 7:
+			if c < '0' || c > '9' {
+				return &LocatorError{Locator: locator, Reason: "square must be 0 to 9"}
+			}
+		case 4, 5:
+			if c < 'A' || c > 'X' {
+				return &LocatorError{Locator: locator, Reason: "subsquare must be A to X"}
+			}
+		}
+	}
+	return nil
+}
+
+// NewPositionDataFromLocator returns a PositionData located at the center of the locator
+func NewPositionDataFromLocator(callsign string, ssid SSID, locator string) (PositionData, error) {
+	lat, long, err := LocatorToLatLong(locator)
+	if err != nil {
+		return PositionData{}, err
+	}
+	return PositionData{
+		Callsign:    callsign,
+		StationSSID: ssid,
+		Latitude:    lat,
+		Longitude:   long,
+	}, nil
+}
+
+// Locator returns the 4, 6 or 8 character Maidenhead locator of the position
+func (data PositionData) Locator(length int) (string, error) {
+	return LatLongToLocator(data.Latitude, data.Longitude, length)
+}
+
+// LocatorStatus returns a status report in the locator form, >IO91SX/- text, for the position
+func (data PositionData) LocatorStatus(text string) (StatusData, error) {
+	locator, err := data.Locator(6)
+	if err != nil {
+		return StatusData{}, err
+	}
+	return StatusData{
+		Callsign:    data.Callsign,
+		StationSSID: data.StationSSID,
+		Locator:     locator,
+		Text:        text,
+	}, nil
+}
+
+// GridSquareAPRSReport constructs an APRS Maidenhead locator beacon, e.g. [IO91SX]
+func (data PositionData) GridSquareAPRSReport() (AX25Data, error) {
+	if err := data.validate(MaxGridSquareCommentLength); err != nil {
+		return nil, err
+	}
+
+	destinationAddress := Address{Callsign: Version, SSID: DestSSIDVIAPath}
+	sourceAddress := Address{Callsign: data.Callsign, SSID: data.StationSSID}
+	informationField := data.CalculateGridSquareInformationField()
+
+	ax25data := AssembleAX25Data(sourceAddress, destinationAddress, informationField)
+
+	return AX25Data(ax25data), nil
+}
+
+// CalculateGridSquareInformationField returns the 6 character locator in brackets followed by the comment
+func (data PositionData) CalculateGridSquareInformationField() []byte {
+	locator, _ := data.Locator(6) // the position is checked by Validate
+	return []byte(fmt.Sprintf("%c%s]%s", gridSquareDataTypeIdentifier, locator, data.Comment))
+}
+
+// ParseGridSquareInformationField parses a Maidenhead locator beacon into the center of the
+// locator and the comment
+func ParseGridSquareInformationField(informationField []byte) (PositionData, error) {
+	var data PositionData
+	if len(informationField) == 0 || informationField[0] != gridSquareDataTypeIdentifier {
+		return data, errors.New("not a Maidenhead locator beacon")
+	}
+	end := strings.IndexByte(string(informationField), ']')
+	if end < 0 {
+		return data, errors.New("Maidenhead locator beacon is not terminated by ]")
+	}
+	lat, long, err := LocatorToLatLong(string(informationField[1:end]))
+	if err != nil {
+		return data, err
+	}
+	data.Latitude, data.Longitude = lat, long
+	data.Comment = string(informationField[end+1:])
+	return data, nil
+}
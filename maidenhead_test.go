@@ -0,0 +1,103 @@
+package aprsgo
+
+import (
+	"math"
+	"testing"
+)
+
+func TestLatLongToLocator(t *testing.T) {
+	testCases := []struct {
+		Lat, Long float64
+		Want      string
+	}{
+		{Lat: 51.4778, Long: -0.0014, Want: "IO91XL94"},   // Greenwich
+		{Lat: 41.7147, Long: -72.7272, Want: "FN31PR21"},  // W1AW
+		{Lat: -33.8568, Long: 151.2153, Want: "QF56OD54"}, // Sydney
+		{Lat: 21.3069, Long: -157.8583, Want: "BL11BH73"}, // Honolulu
+		{Lat: 48.8566, Long: 2.3522, Want: "JN18EU25"},    // Paris
+		{Lat: 0.0001, Long: 0.0001, Want: "JJ00AA00"},     // null island
+		{Lat: -0.0001, Long: -0.0001, Want: "II99XX99"},   // just southwest of null island
+		{Lat: -90, Long: -180, Want: "AA00AA00"},          // corner
+		{Lat: 90, Long: 180, Want: "RR99XX99"},            // opposite corner
+	}
+
+	for _, testCase := range testCases {
+		for _, length := range []int{4, 6, 8} {
+			got, err := LatLongToLocator(testCase.Lat, testCase.Long, length)
+			if err != nil {
+				t.Errorf("Converting %v, %v failed with error %v", testCase.Lat, testCase.Long, err)
+			} else if got != testCase.Want[:length] {
+				t.Errorf("Converting %v, %v expected %s got %s", testCase.Lat, testCase.Long, testCase.Want[:length], got)
+			}
+		}
+		lat, long, err := LocatorToLatLong(testCase.Want)
+		if err != nil {
+			t.Errorf("Converting %s failed with error %v", testCase.Want, err)
+		}
+		if math.Abs(lat-testCase.Lat) > 1.0/240 || math.Abs(long-testCase.Long) > 1.0/120 {
+			t.Errorf("Converting %s expected near %v, %v got %v, %v", testCase.Want, testCase.Lat, testCase.Long, lat, long)
+		}
+	}
+
+	if _, err := LatLongToLocator(91, 0, 6); err == nil {
+		t.Errorf("Converting latitude 91 should fail")
+	}
+	if _, err := LatLongToLocator(0, -181, 6); err == nil {
+		t.Errorf("Converting longitude -181 should fail")
+	}
+	if _, err := LatLongToLocator(0, 0, 5); err == nil {
+		t.Errorf("Converting to a 5 character locator should fail")
+	}
+}
+
+func TestValidateLocator(t *testing.T) {
+	for _, good := range []string{"IO91", "io91sx", "IO91SX12", "AA00AA00", "RR99XX99"} {
+		if err := ValidateLocator(good); err != nil {
+			t.Errorf("Validating %s failed with error %v", good, err)
+		}
+	}
+	for _, bad := range []string{"", "IO9", "IO91S", "SO91", "IOA1", "IO91SY", "IO91SX1A", "IO91SX123"} {
+		err := ValidateLocator(bad)
+		if _, ok := err.(*LocatorError); !ok {
+			t.Errorf("Validating %s expected a LocatorError, got %v", bad, err)
+		}
+	}
+}
+
+func TestGridSquareInformationField(t *testing.T) {
+	report, err := NewPositionDataFromLocator("G3ZZZ", 0, "IO91SX")
+	if err != nil {
+		t.Fatalf("Creating a position from IO91SX failed with error %v", err)
+	}
+	report.Comment = "Contest"
+	if got, _ := report.Locator(6); got != "IO91SX" {
+		t.Errorf("Expected locator IO91SX got %s", got)
+	}
+	got := string(report.CalculateGridSquareInformationField())
+	if want := "[IO91SX]Contest"; got != want {
+		t.Errorf("Expected %s got %s", want, got)
+	}
+	parsed, err := ParseGridSquareInformationField([]byte(got))
+	if err != nil {
+		t.Fatalf("Parsing %s failed with error %v", got, err)
+	}
+	if parsed.Latitude != report.Latitude || parsed.Longitude != report.Longitude || parsed.Comment != report.Comment {
+		t.Errorf("Parsing %s expected %+v got %+v", got, report, parsed)
+	}
+	for _, bad := range []string{"[IO91SX", "[IO9]", "!4142.88N/07243.63W-"} {
+		if _, err := ParseGridSquareInformationField([]byte(bad)); err == nil {
+			t.Errorf("Parsing %s expected to fail, but didn't", bad)
+		}
+	}
+
+	status, err := report.LocatorStatus("QRV 2m")
+	if err != nil {
+		t.Fatalf("Creating a locator status failed with error %v", err)
+	}
+	if got, want := string(status.CalculateStatusInformationField()), ">IO91SX/- QRV 2m"; got != want {
+		t.Errorf("Expected %s got %s", want, got)
+	}
+	if _, err := NewPositionDataFromLocator("G3ZZZ", 0, "ZZ99"); err == nil {
+		t.Errorf("Creating a position from ZZ99 should fail")
+	}
+}
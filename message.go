@@ -0,0 +1,75 @@
+package aprsgo
+
+// message.go contains routines for producing and parsing APRS messages (:)
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	messageDataTypeIdentifier = ':' // message, bulletin or announcement
+	addresseeLength           = 9   // addressees are padded with spaces to 9 characters
+	maxMessageIDLength        = 5   // message numbers are up to 5 alphanumeric characters
+	maxMessageLength          = 67  // maximum length of the message text
+)
+
+// MessageData contains the data to construct an APRS message
+type MessageData struct {
+	Callsign    string // limited to 6 ASCII characters
+	StationSSID SSID
+	Addressee   string // the receiving station, up to 9 characters, e.g. W1AW-7
+	Text        string // up to 67 characters, excluding |, ~ and {
+	MessageID   string // optional message number, up to 5 characters, requests an ack
+}
+
+// MessageAPRSReport constructs an APRS message
+func (data MessageData) MessageAPRSReport() (AX25Data, error) {
+	if err := data.Validate(); err != nil {
+		return nil, err
+	}
+
+	destinationAddress := Address{Callsign: Version, SSID: DestSSIDVIAPath}
+	sourceAddress := Address{Callsign: data.Callsign, SSID: data.StationSSID}
+	informationField := data.CalculateMessageInformationField()
+
+	ax25data := AssembleAX25Data(sourceAddress, destinationAddress, informationField)
+
+	return AX25Data(ax25data), nil
+}
+
+// CalculateMessageInformationField returns the message as :ADDRESSEE:text{id
+func (data MessageData) CalculateMessageInformationField() []byte {
+	informationField := fmt.Sprintf("%c%-9s%c%s",
+		messageDataTypeIdentifier,
+		data.Addressee,
+		messageDataTypeIdentifier,
+		data.Text)
+	if data.MessageID != "" {
+		informationField += "{" + data.MessageID
+	}
+
+	return []byte(informationField)
+}
+
+// ParseMessageInformationField parses the information field of an APRS message,
+// the Addressee is returned with the padding spaces removed
+func ParseMessageInformationField(informationField []byte) (MessageData, error) {
+	var data MessageData
+	if len(informationField) < addresseeLength+2 || informationField[0] != messageDataTypeIdentifier {
+		return data, errors.New("not an APRS message")
+	}
+	if informationField[addresseeLength+1] != messageDataTypeIdentifier {
+		return data, errors.New("message addressee is not terminated by :")
+	}
+	data.Addressee = strings.TrimRight(string(informationField[1:addresseeLength+1]), " ")
+	if data.Addressee == "" {
+		return data, errors.New("message has no addressee")
+	}
+	data.Text = string(informationField[addresseeLength+2:])
+	if brace := strings.LastIndexByte(data.Text, '{'); brace >= 0 && len(data.Text)-brace-1 <= maxMessageIDLength {
+		data.Text, data.MessageID = data.Text[:brace], data.Text[brace+1:]
+	}
+	return data, nil
+}
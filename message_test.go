@@ -0,0 +1,43 @@
+package aprsgo
+
+import (
+	"testing"
+)
+
+func TestMessageInformationField(t *testing.T) {
+	testCases := []struct {
+		In   MessageData
+		Want string
+	}{
+		{In: MessageData{Addressee: "WU2Z", Text: "Testing", MessageID: "003"},
+			Want: ":WU2Z     :Testing{003",
+		},
+		{In: MessageData{Addressee: "KB2ICI-14", Text: "Hello {brace} world"},
+			Want: ":KB2ICI-14:Hello {brace} world",
+		},
+		{In: MessageData{Addressee: "EMAIL", Text: "ack12"},
+			Want: ":EMAIL    :ack12",
+		},
+	}
+
+	for _, testCase := range testCases {
+		got := string(testCase.In.CalculateMessageInformationField())
+		if got != testCase.Want {
+			t.Errorf("Expected %s got %s", testCase.Want, got)
+		}
+		parsed, err := ParseMessageInformationField([]byte(got))
+		if err != nil {
+			t.Errorf("Parsing %s failed with error %v", got, err)
+			continue
+		}
+		if parsed != testCase.In {
+			t.Errorf("Parsing %s expected %+v got %+v", got, testCase.In, parsed)
+		}
+	}
+
+	for _, bad := range []string{":WU2Z:Testing", ":         :Testing", ">status"} {
+		if _, err := ParseMessageInformationField([]byte(bad)); err == nil {
+			t.Errorf("Parsing %s expected to fail, but didn't", bad)
+		}
+	}
+}
@@ -0,0 +1,287 @@
+package aprsgo
+
+// nmea.go contains routines for building and parsing raw NMEA ($) APRS
+// position reports as emitted by simple trackers
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const nmeaDataTypeIdentifier = '$' // raw GPS data or Ultimeter 2000
+
+// NMEAAPRSReport constructs a raw NMEA APRS position report using a $GPRMC sentence,
+// the comment, ambiguity and DAO are not sent
+func (data PositionData) NMEAAPRSReport() (AX25Data, error) {
+	if err := data.validatePosition(); err != nil {
+		return nil, err
+	}
+
+	destinationAddress := Address{Callsign: Version, SSID: DestSSIDVIAPath}
+	sourceAddress := Address{Callsign: data.Callsign, SSID: data.StationSSID}
+	informationField := data.CalculateRMCInformationField()
+
+	ax25data := AssembleAX25Data(sourceAddress, destinationAddress, informationField)
+
+	return AX25Data(ax25data), nil
+}
+
+// CalculateRMCInformationField returns the position, course, speed and time as a $GPRMC sentence
+func (data PositionData) CalculateRMCInformationField() []byte {
+	// $GPRMC,hhmmss.ss,A,ddmm.mmmm,N,dddmm.mmmm,W,speed,course,ddmmyy,,*hh
+	timeString, dateString := "", "" // unknown time is left empty
+	if !data.Timestamp.IsZero() {
+		utc := data.Timestamp.UTC()
+		timeString = fmt.Sprintf("%02d%02d%02d.%02d", utc.Hour(), utc.Minute(), utc.Second(), utc.Nanosecond()/1e7)
+		dateString = fmt.Sprintf("%02d%02d%02d", utc.Day(), int(utc.Month()), utc.Year()%100)
+	}
+	latString, latDir, longString, longDir := nmeaLatLong(data.Latitude, data.Longitude)
+	sentence := fmt.Sprintf("GPRMC,%s,A,%s,%s,%s,%s,%.1f,%.1f,%s,,",
+		timeString,
+		latString,
+		latDir,
+		longString,
+		longDir,
+		data.Speed,
+		data.Course,
+		dateString)
+
+	return []byte(nmeaSentence(sentence))
+}
+
+// CalculateGGAInformationField returns the position, altitude and time as a $GPGGA sentence
+func (data PositionData) CalculateGGAInformationField() []byte {
+	// $GPGGA,hhmmss.ss,ddmm.mmmm,N,dddmm.mmmm,W,1,,,altitude,M,,M,,*hh
+	timeString := ""
+	if !data.Timestamp.IsZero() {
+		utc := data.Timestamp.UTC()
+		timeString = fmt.Sprintf("%02d%02d%02d.%02d", utc.Hour(), utc.Minute(), utc.Second(), utc.Nanosecond()/1e7)
+	}
+	latString, latDir, longString, longDir := nmeaLatLong(data.Latitude, data.Longitude)
+	sentence := fmt.Sprintf("GPGGA,%s,%s,%s,%s,%s,1,,,%.1f,M,,M,,",
+		timeString,
+		latString,
+		latDir,
+		longString,
+		longDir,
+		data.Altitude/metersToFeet) // NMEA altitude is in meters
+
+	return []byte(nmeaSentence(sentence))
+}
+
+// nmeaLatLong formats latitude and longitude as NMEA degrees and decimal minutes
+func nmeaLatLong(lat, long float64) (string, string, string, string) {
+	latDir := "N"
+	if lat < 0 {
+		latDir = "S"
+		lat = -lat
+	}
+	longDir := "E"
+	if long < 0 {
+		longDir = "W"
+		long = -long
+	}
+	latMinutes := math.Floor(lat*60*1e4+0.5) / 1e4 // round to 4 decimals of minutes before splitting
+	longMinutes := math.Floor(long*60*1e4+0.5) / 1e4
+	latDeg, longDeg := int(latMinutes/60), int(longMinutes/60)
+	latString := fmt.Sprintf("%02d%07.4f", latDeg, latMinutes-float64(latDeg*60))
+	longString := fmt.Sprintf("%03d%07.4f", longDeg, longMinutes-float64(longDeg*60))
+	return latString, latDir, longString, longDir
+}
+
+// nmeaSentence adds the leading $ and trailing checksum to the body of a sentence
+func nmeaSentence(body string) string {
+	return fmt.Sprintf("$%s*%02X", body, nmeaChecksum(body))
+}
+
+// nmeaChecksum is the XOR of all characters between the $ and the *
+func nmeaChecksum(body string) byte {
+	var checksum byte
+	for i := 0; i < len(body); i++ {
+		checksum ^= body[i]
+	}
+	return checksum
+}
+
+// ParseNMEAInformationField extracts the position, course, speed, altitude and time
+// from the information field of a raw NMEA ($) APRS packet.
+// RMC, GGA and GLL sentences from any talker are supported, the checksum is verified if present.
+// GGA and GLL sentences carry no date, so only the time of day is set in the Timestamp.
+func ParseNMEAInformationField(informationField []byte) (PositionData, error) {
+	var data PositionData
+	sentence := strings.TrimRight(string(informationField), "\r\n ")
+	if len(sentence) < 7 || sentence[0] != nmeaDataTypeIdentifier {
+		return data, errors.New("not a raw NMEA sentence")
+	}
+	body := sentence[1:]
+	if star := strings.LastIndexByte(body, '*'); star >= 0 {
+		checksum, err := strconv.ParseUint(body[star+1:], 16, 8)
+		if err != nil {
+			return data, fmt.Errorf("malformed NMEA checksum %q", body[star+1:])
+		}
+		body = body[:star]
+		if byte(checksum) != nmeaChecksum(body) {
+			return data, fmt.Errorf("NMEA checksum %02X does not match %02X", checksum, nmeaChecksum(body))
+		}
+	}
+	fields := strings.Split(body, ",")
+	if len(fields[0]) != 5 {
+		return data, fmt.Errorf("unknown NMEA sentence %q", fields[0])
+	}
+
+	var err error
+	switch fields[0][2:] { // the first two characters are the talker, e.g. GP or GN
+	case "RMC":
+		// RMC,time,status,lat,N,long,W,speed,course,date,...
+		if len(fields) < 10 {
+			return data, errors.New("RMC sentence has too few fields")
+		}
+		if fields[2] != "A" {
+			return data, errors.New("RMC sentence has no valid fix")
+		}
+		if data.Latitude, data.Longitude, err = parseNMEALatLong(fields[3:7]); err != nil {
+			return data, err
+		}
+		if data.Speed, err = parseNMEAFloat(fields[7]); err != nil {
+			return data, err
+		}
+		if data.Course, err = parseNMEAFloat(fields[8]); err != nil {
+			return data, err
+		}
+		if data.Timestamp, err = parseNMEATime(fields[1], fields[9]); err != nil {
+			return data, err
+		}
+	case "GGA":
+		// GGA,time,lat,N,long,W,quality,satellites,hdop,altitude,M,...
+		if len(fields) < 11 {
+			return data, errors.New("GGA sentence has too few fields")
+		}
+		if fields[6] == "" || fields[6] == "0" {
+			return data, errors.New("GGA sentence has no valid fix")
+		}
+		if data.Latitude, data.Longitude, err = parseNMEALatLong(fields[2:6]); err != nil {
+			return data, err
+		}
+		altitude, err := parseNMEAFloat(fields[9])
+		if err != nil {
+			return data, err
+		}
+		data.Altitude = altitude * metersToFeet
+		if data.Timestamp, err = parseNMEATime(fields[1], ""); err != nil {
+			return data, err
+		}
+	case "GLL":
+		// GLL,lat,N,long,W,time,status
+		if len(fields) < 7 {
+			return data, errors.New("GLL sentence has too few fields")
+		}
+		if fields[6] != "A" {
+			return data, errors.New("GLL sentence has no valid fix")
+		}
+		if data.Latitude, data.Longitude, err = parseNMEALatLong(fields[1:5]); err != nil {
+			return data, err
+		}
+		if data.Timestamp, err = parseNMEATime(fields[5], ""); err != nil {
+			return data, err
+		}
+	default:
+		return data, fmt.Errorf("unsupported NMEA sentence %q", fields[0])
+	}
+	return data, nil
+}
+
+// parseNMEALatLong parses the four fields ddmm.mm,N,dddmm.mm,W into signed degrees
+func parseNMEALatLong(fields []string) (float64, float64, error) {
+	lat, err := parseNMEADegrees(fields[0], 2)
+	if err != nil {
+		return 0, 0, err
+	}
+	switch fields[1] {
+	case "N":
+	case "S":
+		lat = -lat
+	default:
+		return 0, 0, fmt.Errorf("invalid latitude direction %q", fields[1])
+	}
+	long, err := parseNMEADegrees(fields[2], 3)
+	if err != nil {
+		return 0, 0, err
+	}
+	switch fields[3] {
+	case "E":
+	case "W":
+		long = -long
+	default:
+		return 0, 0, fmt.Errorf("invalid longitude direction %q", fields[3])
+	}
+	if lat > 90 || long > 180 || lat < -90 || long < -180 {
+		return 0, 0, fmt.Errorf("position %v, %v out of range", lat, long)
+	}
+	return lat, long, nil
+}
+
+// parseNMEADegrees parses degrees and decimal minutes with the given number of degree digits
+func parseNMEADegrees(field string, degreeDigits int) (float64, error) {
+	if len(field) < degreeDigits+2 {
+		return 0, fmt.Errorf("malformed NMEA coordinate %q", field)
+	}
+	degrees, err := strconv.ParseUint(field[:degreeDigits], 10, 8)
+	if err != nil {
+		return 0, fmt.Errorf("malformed NMEA coordinate %q", field)
+	}
+	minutes, err := strconv.ParseFloat(field[degreeDigits:], 64)
+	if err != nil || minutes >= 60 {
+		return 0, fmt.Errorf("malformed NMEA coordinate %q", field)
+	}
+	return float64(degrees) + minutes/60, nil
+}
+
+// parseNMEAFloat parses an optional numeric field, empty fields are zero
+func parseNMEAFloat(field string) (float64, error) {
+	if field == "" {
+		return 0, nil
+	}
+	value, err := strconv.ParseFloat(field, 64)
+	if err != nil {
+		return 0, fmt.Errorf("malformed NMEA number %q", field)
+	}
+	return value, nil
+}
+
+// parseNMEATime parses hhmmss.ss and the optional ddmmyy date into a UTC time
+func parseNMEATime(timeField, dateField string) (time.Time, error) {
+	if timeField == "" {
+		return time.Time{}, nil
+	}
+	if len(timeField) < 6 {
+		return time.Time{}, fmt.Errorf("malformed NMEA time %q", timeField)
+	}
+	hms, err := strconv.Atoi(timeField[:6])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("malformed NMEA time %q", timeField)
+	}
+	var nanoseconds int
+	if len(timeField) > 7 && timeField[6] == '.' {
+		fraction, err := strconv.ParseFloat("0"+timeField[6:], 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("malformed NMEA time %q", timeField)
+		}
+		nanoseconds = int(fraction*1e9 + 0.5)
+	}
+	year, month, day := 0, 1, 1 // no date available
+	if dateField != "" {
+		dmy, err := strconv.Atoi(dateField)
+		if err != nil || len(dateField) != 6 {
+			return time.Time{}, fmt.Errorf("malformed NMEA date %q", dateField)
+		}
+		day, month, year = dmy/10000, (dmy/100)%100, 2000+dmy%100
+		if year >= 2080 { // two digit years are taken to be 1980-2079
+			year -= 100
+		}
+	}
+	return time.Date(year, time.Month(month), day, hms/10000, (hms/100)%100, hms%100, nanoseconds, time.UTC), nil
+}
@@ -0,0 +1,118 @@
+package aprsgo
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseNMEAInformationField(t *testing.T) {
+	testCases := []struct {
+		In   string
+		Want PositionData
+	}{
+		{In: "$GPRMC,123519,A,4807.038,N,01131.000,E,022.4,084.4,230394,003.1,W*6A",
+			Want: PositionData{Latitude: 48.1173, Longitude: 11.516666, Speed: 22.4, Course: 84.4,
+				Timestamp: time.Date(1994, 3, 23, 12, 35, 19, 0, time.UTC)},
+		},
+		{In: "$GPGGA,123519,4807.038,N,01131.000,E,1,08,0.9,545.4,M,46.9,M,,*47",
+			Want: PositionData{Latitude: 48.1173, Longitude: 11.516666, Altitude: 1789.37,
+				Timestamp: time.Date(0, 1, 1, 12, 35, 19, 0, time.UTC)},
+		},
+		{In: "$GNGLL,4142.882,N,07243.632,W,225444.50,A",
+			Want: PositionData{Latitude: 41.7147, Longitude: -72.7272,
+				Timestamp: time.Date(0, 1, 1, 22, 54, 44, 5e8, time.UTC)},
+		},
+	}
+
+	for _, testCase := range testCases {
+		got, err := ParseNMEAInformationField([]byte(testCase.In))
+		if err != nil {
+			t.Errorf("Parsing %s failed with error %v", testCase.In, err)
+			continue
+		}
+		if math.Abs(got.Latitude-testCase.Want.Latitude) > 1e-5 || math.Abs(got.Longitude-testCase.Want.Longitude) > 1e-5 {
+			t.Errorf("Parsing %s, expected position %v, %v got %v, %v", testCase.In,
+				testCase.Want.Latitude, testCase.Want.Longitude, got.Latitude, got.Longitude)
+		}
+		if got.Speed != testCase.Want.Speed || got.Course != testCase.Want.Course {
+			t.Errorf("Parsing %s, expected speed %v course %v got %v, %v", testCase.In,
+				testCase.Want.Speed, testCase.Want.Course, got.Speed, got.Course)
+		}
+		if math.Abs(got.Altitude-testCase.Want.Altitude) > 0.01 {
+			t.Errorf("Parsing %s, expected altitude %v got %v", testCase.In, testCase.Want.Altitude, got.Altitude)
+		}
+		if !got.Timestamp.Equal(testCase.Want.Timestamp) {
+			t.Errorf("Parsing %s, expected time %v got %v", testCase.In, testCase.Want.Timestamp, got.Timestamp)
+		}
+	}
+
+	for _, bad := range []string{
+		"$GPRMC,123519,A,4807.038,N,01131.000,E,022.4,084.4,230394,003.1,W*6B", // bad checksum
+		"$GPRMC,123519,V,4807.038,N,01131.000,E,022.4,084.4,230394,003.1,W",    // no fix
+		"$GPGGA,123519,4807.038,N,01131.000,E,0,08,0.9,545.4,M,46.9,M,,",       // no fix
+		"$GPRMC,123519,A,4807.038,X,01131.000,E,022.4,084.4,230394,003.1,W",    // bad direction
+		"$GPRMC,123519,A,4867.038,N,01131.000,E,022.4,084.4,230394,003.1,W",    // bad minutes
+		"$GPZDA,201530.00,04,07,2002,00,00",                                    // unsupported
+		"!4142.88N/07243.63W-Test",                                             // not NMEA
+	} {
+		if _, err := ParseNMEAInformationField([]byte(bad)); err == nil {
+			t.Errorf("Parsing %s expected to fail, but didn't", bad)
+		}
+	}
+}
+
+func TestNMEARoundTrip(t *testing.T) {
+	report := PositionData{
+		Callsign:  "W1AW",
+		Latitude:  -41.7147,
+		Longitude: 172.7272,
+		Altitude:  1000,
+		Course:    271.5,
+		Speed:     36.2,
+		Timestamp: time.Date(2017, 5, 1, 8, 9, 10, 250e6, time.UTC),
+	}
+
+	rmc := string(report.CalculateRMCInformationField())
+	if want := "$GPRMC,080910.25,A,4142.8820,S,17243.6320,E,36.2,271.5,010517,,*"; !strings.HasPrefix(rmc, want) {
+		t.Errorf("Expected RMC %s, got %s", want, rmc)
+	}
+	got, err := ParseNMEAInformationField([]byte(rmc))
+	if err != nil {
+		t.Fatalf("Parsing %s failed with error %v", rmc, err)
+	}
+	if math.Abs(got.Latitude-report.Latitude) > 1e-6 || math.Abs(got.Longitude-report.Longitude) > 1e-6 ||
+		got.Course != report.Course || got.Speed != report.Speed || !got.Timestamp.Equal(report.Timestamp) {
+		t.Errorf("RMC round trip expected %+v, got %+v", report, got)
+	}
+
+	gga := string(report.CalculateGGAInformationField())
+	got, err = ParseNMEAInformationField([]byte(gga))
+	if err != nil {
+		t.Fatalf("Parsing %s failed with error %v", gga, err)
+	}
+	if math.Abs(got.Altitude-report.Altitude) > 0.5 {
+		t.Errorf("GGA round trip expected altitude %v, got %v", report.Altitude, got.Altitude)
+	}
+
+	ax25data, err := report.NMEAAPRSReport()
+	if err != nil {
+		t.Fatalf("Report failed validation: %v", err)
+	}
+	if info := string(ax25data[16 : len(ax25data)-2]); info != rmc {
+		t.Errorf("Expected information field %s, got %s", rmc, info)
+	}
+}
+
+func TestNMEAReportValidate(t *testing.T) {
+	// the comment and DAO are not sent in a $GPRMC sentence so do not limit it
+	report := PositionData{Callsign: "W1AW", Latitude: 41.7147, Longitude: -72.7272, Comment: strings.Repeat("x", 42) + "|", DAO: DAOHuman}
+	if _, err := report.NMEAAPRSReport(); err != nil {
+		t.Errorf("43 character comment with a DAO should not stop an NMEA report, got %v", err)
+	}
+	report.Latitude = 91
+	if _, err := report.NMEAAPRSReport(); errorType(err) != "*aprsgo.LatitudeError" {
+		t.Errorf("Latitude 91 should fail validation, got %v", err)
+	}
+}
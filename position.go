@@ -0,0 +1,258 @@
+package aprsgo
+
+// position.go contains routines for position ambiguity and the !DAO! extra precision
+// extension, and for parsing received position reports
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DAOFormat selects the form of the !DAO! extra precision extension
+type DAOFormat int
+
+// DAO formats, both use the WGS84 datum
+const (
+	DAONone   DAOFormat = iota // no extra precision
+	DAOHuman                   // !W..! one more decimal digit of minutes, human readable
+	DAOBase91                  // !w..! two more decimal digits of minutes, base-91 encoded
+)
+
+const (
+	daoDatumHuman  = 'W' // WGS84, human readable digits
+	daoDatumBase91 = 'w' // WGS84, base-91 digits
+	maxAmbiguity   = 4   // the number of digits that can be blanked from DDMM.hh
+	daoLength      = 5   // the !DAO! extension appended to the comment
+)
+
+// ambiguityMinutes is the size of the ambiguous area in minutes for each level of ambiguity
+var ambiguityMinutes = [maxAmbiguity + 1]float64{0.01, 0.1, 1, 10, 60}
+
+// basicCoordinate formats degrees, without sign, as DDMM.hh with the given number of degree digits
+// and blanks the trailing ambiguity digits. The DAO digit returned holds the extra precision.
+func basicCoordinate(degrees float64, degreeDigits int, ambiguity int, dao DAOFormat) (string, byte) {
+	if ambiguity < 0 {
+		ambiguity = 0
+	}
+	if ambiguity > maxAmbiguity {
+		ambiguity = maxAmbiguity
+	}
+	var hundredths int // the coordinate in hundredths of a minute
+	var daoDigit byte
+	switch {
+	case ambiguity > 0: // truncate to the ambiguous area
+		box := int(ambiguityMinutes[ambiguity] * 100)
+		hundredths = int(math.Floor(degrees*6000/float64(box))) * box
+	case dao == DAOHuman:
+		thousandths := int(math.Floor(degrees*60000 + 0.5))
+		hundredths = thousandths / 10
+		daoDigit = byte('0' + thousandths%10)
+	case dao == DAOBase91:
+		tenThousandths := int(math.Floor(degrees*600000 + 0.5))
+		hundredths = tenThousandths / 100
+		code := int(math.Floor(float64(tenThousandths%100)/1.1 + 0.5))
+		if code > 90 {
+			code = 90
+		}
+		daoDigit = byte(code + 33)
+	default:
+		hundredths = int(math.Floor(degrees*6000 + 0.5))
+	}
+
+	minutes := hundredths % 6000
+	coordinate := []byte(fmt.Sprintf("%0*d%02d.%02d", degreeDigits, hundredths/6000, minutes/100, minutes%100))
+	for i, blanked := len(coordinate)-1, 0; blanked < ambiguity; i-- {
+		if coordinate[i] != '.' {
+			coordinate[i] = ' '
+			blanked++
+		}
+	}
+	return string(coordinate), daoDigit
+}
+
+// ambiguousCenter returns the center of the ambiguous area containing degrees
+func ambiguousCenter(degrees float64, ambiguity int) float64 {
+	if ambiguity <= 0 {
+		return degrees
+	}
+	if ambiguity > maxAmbiguity {
+		ambiguity = maxAmbiguity
+	}
+	box := ambiguityMinutes[ambiguity] / 60
+	center := math.Floor(math.Abs(degrees)/box)*box + box/2
+	return math.Copysign(center, degrees)
+}
+
+// sendsDAO returns true if the basic report carries a !DAO! extension,
+// extra precision is meaningless for an ambiguous position
+func (data PositionData) sendsDAO() bool {
+	return data.DAO != DAONone && data.Ambiguity == 0
+}
+
+// formatDAO returns the !DAO! extension with the extra latitude and longitude digits
+func formatDAO(dao DAOFormat, latDigit, longDigit byte) string {
+	datum := byte(daoDatumHuman)
+	if dao == DAOBase91 {
+		datum = daoDatumBase91
+	}
+	return string([]byte{'!', datum, latDigit, longDigit, '!'})
+}
+
+// findDAO locates a !DAO! extension in a comment and returns its position, format and the extra
+// latitude and longitude in minutes
+func findDAO(comment string) (int, DAOFormat, float64, float64) {
+	for i := strings.IndexByte(comment, '!'); i >= 0 && i+5 <= len(comment); {
+		candidate := comment[i : i+5]
+		if candidate[4] == '!' {
+			switch datum := candidate[1]; {
+			case datum >= 'A' && datum <= 'Z' && daoHumanDigit(candidate[2]) && daoHumanDigit(candidate[3]):
+				return i, DAOHuman, daoHumanValue(candidate[2]), daoHumanValue(candidate[3])
+			case datum >= 'a' && datum <= 'z' && daoBase91Digit(candidate[2]) && daoBase91Digit(candidate[3]):
+				return i, DAOBase91, daoBase91Value(candidate[2]), daoBase91Value(candidate[3])
+			}
+		}
+		next := strings.IndexByte(comment[i+1:], '!')
+		if next < 0 {
+			break
+		}
+		i += next + 1
+	}
+	return -1, DAONone, 0, 0
+}
+
+func daoHumanDigit(c byte) bool {
+	return c == ' ' || (c >= '0' && c <= '9')
+}
+
+func daoHumanValue(c byte) float64 {
+	if c == ' ' {
+		return 0
+	}
+	return float64(c-'0') * 0.001
+}
+
+func daoBase91Digit(c byte) bool {
+	return c >= '!' && c <= '{'
+}
+
+func daoBase91Value(c byte) float64 {
+	return float64(c-'!') * 1.1 * 0.0001
+}
+
+// ParsePositionInformationField parses an APRS position report in the basic or compressed format
+// with or without a timestamp, recovering the position ambiguity and any !DAO! extra precision.
+// An ambiguous position is reported at the center of the ambiguous area.
+func ParsePositionInformationField(informationField []byte) (PositionData, error) {
+	var data PositionData
+	if len(informationField) == 0 {
+		return data, errors.New("empty information field")
+	}
+	body := string(informationField[1:])
+	switch informationField[0] {
+	case '!', '=': // without timestamp
+	case '/', '@': // with timestamp
+		if len(body) < 7 {
+			return data, errors.New("position report timestamp is too short")
+		}
+		var err error
+		if data.Timestamp, err = parsePositionTimestamp(body[:7]); err != nil {
+			return data, err
+		}
+		body = body[7:]
+	default:
+		return data, fmt.Errorf("data type %q is not a position report", informationField[0])
+	}
+	if len(body) > 0 && body[0] >= '0' && body[0] <= '9' { // uncompressed positions start with latitude digits
+		return parseBasicPosition(data, body)
+	}
+	return parseCompressedPosition(data, body)
+}
+
+// parsePositionTimestamp parses a DHM zulu (z) or HMS (h) timestamp
+func parsePositionTimestamp(timestamp string) (time.Time, error) {
+	switch timestamp[6] {
+	case 'z':
+		return parseDHMTimestamp(timestamp)
+	case 'h':
+		return parseNMEATime(timestamp[:6], "")
+	}
+	return time.Time{}, fmt.Errorf("unsupported position timestamp %q", timestamp)
+}
+
+// parseBasicPosition parses DDMM.hhN/DDDMM.hhW$comment
+func parseBasicPosition(data PositionData, body string) (PositionData, error) {
+	if len(body) < 19 {
+		return data, errors.New("position report is too short")
+	}
+	lat, latAmbiguity, err := parseBasicCoordinate(body[:7], 2)
+	if err != nil {
+		return data, err
+	}
+	long, _, err := parseBasicCoordinate(body[9:17], 3)
+	if err != nil {
+		return data, err
+	}
+	data.Ambiguity = latAmbiguity // the latitude ambiguity applies to the longitude as well
+	data.SymbolTable, data.Symbol = body[8], body[18]
+	data.Comment = body[19:]
+
+	if i, dao, latExtra, longExtra := findDAO(data.Comment); i >= 0 && latAmbiguity == 0 {
+		data.DAO = dao
+		lat += latExtra / 60
+		long += longExtra / 60
+		data.Comment = data.Comment[:i] + data.Comment[i+5:]
+	} else if latAmbiguity > 0 {
+		box := ambiguityMinutes[latAmbiguity] / 60
+		lat, long = lat+box/2, long+box/2
+	}
+
+	switch body[7] {
+	case 'N':
+	case 'S':
+		lat = -lat
+	default:
+		return data, fmt.Errorf("invalid latitude direction %q", body[7])
+	}
+	switch body[17] {
+	case 'E':
+	case 'W':
+		long = -long
+	default:
+		return data, fmt.Errorf("invalid longitude direction %q", body[17])
+	}
+	if lat > 90 || lat < -90 || long > 180 || long < -180 {
+		return data, fmt.Errorf("position %v, %v out of range", lat, long)
+	}
+	data.Latitude, data.Longitude = lat, long
+	return data, nil
+}
+
+// parseBasicCoordinate parses DDMM.hh with the given number of degree digits, where trailing digits
+// may be blanked by spaces, returning the truncated degrees and the number of blanked digits
+func parseBasicCoordinate(field string, degreeDigits int) (float64, int, error) {
+	if len(field) != degreeDigits+5 || field[degreeDigits+2] != '.' {
+		return 0, 0, fmt.Errorf("malformed coordinate %q", field)
+	}
+	digits := []byte(strings.Replace(field, ".", "", 1))
+	ambiguity := 0
+	for i := len(digits) - 1; i >= 0 && digits[i] == ' '; i-- {
+		digits[i] = '0'
+		ambiguity++
+	}
+	if ambiguity > maxAmbiguity {
+		return 0, 0, fmt.Errorf("coordinate %q is too ambiguous", field)
+	}
+	value, err := strconv.ParseUint(string(digits), 10, 32)
+	if err != nil {
+		return 0, 0, fmt.Errorf("malformed coordinate %q", field)
+	}
+	degrees, hundredths := value/10000, value%10000
+	if hundredths >= 6000 {
+		return 0, 0, fmt.Errorf("coordinate %q has more than 60 minutes", field)
+	}
+	return float64(degrees) + float64(hundredths)/6000, ambiguity, nil
+}
@@ -0,0 +1,121 @@
+package aprsgo
+
+import (
+	"math"
+	"testing"
+)
+
+func TestPositionAmbiguity(t *testing.T) {
+	testCases := []struct {
+		Ambiguity int
+		Want      string
+	}{
+		{Ambiguity: 0, Want: "!4142.88N/07243.63W-Test"},
+		{Ambiguity: 1, Want: "!4142.8 N/07243.6 W-Test"},
+		{Ambiguity: 2, Want: "!4142.  N/07243.  W-Test"},
+		{Ambiguity: 3, Want: "!414 .  N/0724 .  W-Test"},
+		{Ambiguity: 4, Want: "!41  .  N/072  .  W-Test"},
+	}
+
+	for _, testCase := range testCases {
+		report := PositionData{
+			Latitude:  41.7147,
+			Longitude: -72.7272,
+			Comment:   "Test",
+			Ambiguity: testCase.Ambiguity,
+			DAO:       DAOBase91, // ignored for ambiguous positions
+		}
+		if testCase.Ambiguity == 0 {
+			report.DAO = DAONone
+		}
+		got := string(report.CalculateBasicInformationField())
+		if got != testCase.Want {
+			t.Errorf("Ambiguity %d expected %s got %s", testCase.Ambiguity, testCase.Want, got)
+		}
+		parsed, err := ParsePositionInformationField([]byte(got))
+		if err != nil {
+			t.Errorf("Parsing %s failed with error %v", got, err)
+			continue
+		}
+		if parsed.Ambiguity != testCase.Ambiguity || parsed.Comment != "Test" {
+			t.Errorf("Parsing %s expected ambiguity %d got %d", got, testCase.Ambiguity, parsed.Ambiguity)
+		}
+		// the parsed position is the center of the ambiguous area, which contains the true position
+		halfBox := ambiguityMinutes[testCase.Ambiguity] / 60 / 2
+		if math.Abs(parsed.Latitude-report.Latitude) > halfBox || math.Abs(parsed.Longitude-report.Longitude) > halfBox {
+			t.Errorf("Parsing %s expected within %v of %v, %v got %v, %v", got, halfBox,
+				report.Latitude, report.Longitude, parsed.Latitude, parsed.Longitude)
+		}
+		if testCase.Ambiguity > 0 {
+			compressed := report
+			compressed.Latitude, compressed.Longitude = parsed.Latitude, parsed.Longitude
+			if string(report.CalculateCompressedInformationField()) != string(compressed.CalculateCompressedInformationField()) {
+				t.Errorf("Compressed report with ambiguity %d should be at the center of the ambiguous area", testCase.Ambiguity)
+			}
+		}
+	}
+}
+
+func TestPositionDAO(t *testing.T) {
+	testCases := []struct {
+		DAO       DAOFormat
+		Want      string
+		Precision float64
+	}{
+		{DAO: DAONone, Want: "!4142.88N/07243.63W-Test", Precision: 0.005 / 60},
+		{DAO: DAOHuman, Want: "!4142.88N/07243.63W-Test!W22!", Precision: 0.0005 / 60},
+		{DAO: DAOBase91, Want: "!4142.88N/07243.63W-Test!w33!", Precision: 0.0001 / 60},
+	}
+
+	for _, testCase := range testCases {
+		report := PositionData{Latitude: 41.7147, Longitude: -72.7272, Comment: "Test", DAO: testCase.DAO}
+		got := string(report.CalculateBasicInformationField())
+		if got != testCase.Want {
+			t.Errorf("DAO %d expected %s got %s", testCase.DAO, testCase.Want, got)
+		}
+
+		for _, lat := range []float64{41.71473333, -0.00001, 89.99999} {
+			report := PositionData{Latitude: lat, Longitude: -lat * 2, Comment: "Test", DAO: testCase.DAO}
+			info := report.CalculateBasicInformationField()
+			parsed, err := ParsePositionInformationField(info)
+			if err != nil {
+				t.Errorf("Parsing %s failed with error %v", info, err)
+				continue
+			}
+			if parsed.DAO != testCase.DAO || parsed.Comment != "Test" {
+				t.Errorf("Parsing %s expected DAO %d and comment Test got %d %q", info, testCase.DAO, parsed.DAO, parsed.Comment)
+			}
+			if math.Abs(parsed.Latitude-report.Latitude) > testCase.Precision || math.Abs(parsed.Longitude-report.Longitude) > testCase.Precision {
+				t.Errorf("Parsing %s expected within %v of %v, %v got %v, %v", info, testCase.Precision,
+					report.Latitude, report.Longitude, parsed.Latitude, parsed.Longitude)
+			}
+		}
+	}
+}
+
+func TestParsePositionInformationField(t *testing.T) {
+	parsed, err := ParsePositionInformationField([]byte("@092345z4903.50N/07201.75W>Moving !W5 ! test"))
+	if err != nil {
+		t.Fatalf("Parsing failed with error %v", err)
+	}
+	if math.Abs(parsed.Latitude-(49+3.505/60)) > 1e-9 || math.Abs(parsed.Longitude+(72+1.75/60)) > 1e-9 {
+		t.Errorf("Expected 49 03.505N 072 01.750W got %v, %v", parsed.Latitude, parsed.Longitude)
+	}
+	if parsed.Comment != "Moving  test" || parsed.Timestamp.Day() != 9 || parsed.Timestamp.Hour() != 23 {
+		t.Errorf("Unexpected comment %q or timestamp %v", parsed.Comment, parsed.Timestamp)
+	}
+
+	for _, bad := range []string{
+		"!4142.88X/07243.63W-",  // bad direction
+		"!4162.88N/07243.63W-",  // more than 60 minutes
+		"!4142.88N/07243.63",    // too short
+		"!41 2.88N/07243.63W-",  // embedded space
+		"!9142.88N/07243.63W-",  // out of range
+		"@0923z4903.50N/07201.", // short timestamp
+		">status text",          // not a position
+	} {
+		if _, err := ParsePositionInformationField([]byte(bad)); err == nil {
+			t.Errorf("Parsing %s expected to fail, but didn't", bad)
+		}
+	}
+}
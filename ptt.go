@@ -0,0 +1,203 @@
+package aprsgo
+
+// ptt.go contains the Push To Talk control that keys the transmitter: no-op for radios keyed by
+// VOX, Linux sysfs GPIO, CM108 sound card GPIO over HID and the Hamlib rigctld network protocol,
+// serial RTS/DTR and GPIO character devices are in the operating system specific files
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PTT keys and unkeys a transmitter
+type PTT interface {
+	Key() error
+	Unkey() error
+	Close() error
+}
+
+// VOX is the PTT of a radio keyed by the transmitted audio, it does nothing
+type VOX struct{}
+
+// Key does nothing, the audio keys the radio
+func (VOX) Key() error { return nil }
+
+// Unkey does nothing, the radio unkeys when the audio stops
+func (VOX) Unkey() error { return nil }
+
+// Close does nothing
+func (VOX) Close() error { return nil }
+
+// SerialLine is the modem control line of a serial port that keys the transmitter
+type SerialLine int
+
+// Serial port lines used for PTT
+const (
+	RTS SerialLine = iota // Request To Send
+	DTR                   // Data Terminal Ready
+)
+
+func (line SerialLine) String() string {
+	switch line {
+	case RTS:
+		return "RTS"
+	case DTR:
+		return "DTR"
+	}
+	return fmt.Sprintf("SerialLine(%d)", int(line))
+}
+
+var gpioSysfsRoot = "/sys/class/gpio" // the sysfs GPIO interface, replaced in tests
+
+// gpioSysfsPTT keys with a GPIO pin through the deprecated, but widely available, sysfs interface
+type gpioSysfsPTT struct {
+	value  *os.File
+	invert bool
+}
+
+// NewGPIOSysfsPTT exports the GPIO pin as an output, driven high to key or low if invert is set
+func NewGPIOSysfsPTT(pin int, invert bool) (PTT, error) {
+	dir := filepath.Join(gpioSysfsRoot, fmt.Sprintf("gpio%d", pin))
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		if err := writeSysfs(filepath.Join(gpioSysfsRoot, "export"), strconv.Itoa(pin)); err != nil {
+			return nil, fmt.Errorf("exporting GPIO %d: %v", pin, err)
+		}
+	}
+	initial := "low" // sets the direction to output unkeyed without a glitch
+	if invert {
+		initial = "high"
+	}
+	if err := writeSysfs(filepath.Join(dir, "direction"), initial); err != nil {
+		return nil, fmt.Errorf("setting GPIO %d to output: %v", pin, err)
+	}
+	value, err := os.OpenFile(filepath.Join(dir, "value"), os.O_WRONLY, 0)
+	if err != nil {
+		return nil, fmt.Errorf("opening GPIO %d: %v", pin, err)
+	}
+	return &gpioSysfsPTT{value: value, invert: invert}, nil
+}
+
+// writeSysfs writes value to an attribute file, which must already exist
+func writeSysfs(path, value string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_TRUNC, 0)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(value); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (p *gpioSysfsPTT) set(keyed bool) error {
+	level := "0"
+	if keyed != p.invert {
+		level = "1"
+	}
+	_, err := p.value.WriteAt([]byte(level), 0)
+	return err
+}
+
+func (p *gpioSysfsPTT) Key() error   { return p.set(true) }
+func (p *gpioSysfsPTT) Unkey() error { return p.set(false) }
+
+func (p *gpioSysfsPTT) Close() error {
+	p.set(false)
+	return p.value.Close()
+}
+
+// cm108PTT keys with a GPIO pin of a CM108 or compatible USB sound card, set with HID output reports
+type cm108PTT struct {
+	device *os.File
+	pin    int
+}
+
+// NewCM108PTT keys with GPIO pin 1 to 8 of the CM108 sound card at the hidraw device, usually pin 3
+func NewCM108PTT(device string, pin int) (PTT, error) {
+	if pin < 1 || pin > 8 {
+		return nil, fmt.Errorf("CM108 GPIO pin must be 1 to 8, not %d", pin)
+	}
+	f, err := os.OpenFile(device, os.O_WRONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &cm108PTT{device: f, pin: pin}, nil
+}
+
+func (p *cm108PTT) set(keyed bool) error {
+	mask := byte(1) << uint(p.pin-1)
+	var data byte
+	if keyed {
+		data = mask
+	}
+	// report number, HID output byte 0, then the GPIO direction mask and data
+	_, err := p.device.Write([]byte{0, 0, mask, data, 0})
+	return err
+}
+
+func (p *cm108PTT) Key() error   { return p.set(true) }
+func (p *cm108PTT) Unkey() error { return p.set(false) }
+
+func (p *cm108PTT) Close() error {
+	p.set(false)
+	return p.device.Close()
+}
+
+// RigctldDefaultAddress is the address the Hamlib rigctld daemon listens on by default
+const RigctldDefaultAddress = "localhost:4532"
+
+var rigctldTimeout = 5 * time.Second // the wait for rigctld to connect or answer
+
+// rigctldPTT keys a radio through the Hamlib rigctld network daemon
+type rigctldPTT struct {
+	mu     sync.Mutex // protects conn and reader, one command at a time
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// DialRigctld connects to the Hamlib rigctld daemon at addr to key the radio with its PTT commands
+func DialRigctld(addr string) (PTT, error) {
+	conn, err := net.DialTimeout("tcp", addr, rigctldTimeout)
+	if err != nil {
+		return nil, err
+	}
+	return &rigctldPTT{conn: conn, reader: bufio.NewReader(conn)}, nil
+}
+
+// command sends a rigctld command and checks the RPRT reply, 0 for success or a negative Hamlib error
+func (p *rigctldPTT) command(command string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.conn.SetDeadline(time.Now().Add(rigctldTimeout))
+	if _, err := p.conn.Write([]byte(command + "\n")); err != nil {
+		return err
+	}
+	reply, err := p.reader.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	reply = strings.TrimSpace(reply)
+	if !strings.HasPrefix(reply, "RPRT ") {
+		return fmt.Errorf("unexpected rigctld reply %q to %q", reply, command)
+	}
+	if code, err := strconv.Atoi(strings.TrimPrefix(reply, "RPRT ")); err != nil || code != 0 {
+		return fmt.Errorf("rigctld %q failed: %s", command, reply)
+	}
+	return nil
+}
+
+func (p *rigctldPTT) Key() error   { return p.command("T 1") }
+func (p *rigctldPTT) Unkey() error { return p.command("T 0") }
+
+func (p *rigctldPTT) Close() error {
+	p.Unkey()
+	return p.conn.Close()
+}
@@ -0,0 +1,131 @@
+//go:build linux
+
+package aprsgo
+
+// ptt_linux.go contains the PTT keyed with the RTS or DTR line of a serial port and with a line of
+// a GPIO character device, both set with Linux ioctls
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// serialPTT keys with a modem control line of a serial port
+type serialPTT struct {
+	port   *os.File
+	bits   int // the TIOCM bit of the line
+	invert bool
+}
+
+// NewSerialPTT keys by asserting the line of the serial port device, or clearing it if invert is set
+func NewSerialPTT(device string, line SerialLine, invert bool) (PTT, error) {
+	var bits int
+	switch line {
+	case RTS:
+		bits = syscall.TIOCM_RTS
+	case DTR:
+		bits = syscall.TIOCM_DTR
+	default:
+		return nil, fmt.Errorf("unknown serial line %v", line)
+	}
+	port, err := os.OpenFile(device, os.O_RDWR|syscall.O_NOCTTY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return nil, err
+	}
+	p := &serialPTT{port: port, bits: bits, invert: invert}
+	if err := p.Unkey(); err != nil {
+		port.Close()
+		return nil, fmt.Errorf("setting %v on %s: %v", line, device, err)
+	}
+	return p, nil
+}
+
+func (p *serialPTT) set(keyed bool) error {
+	request := uintptr(syscall.TIOCMBIC)
+	if keyed != p.invert {
+		request = syscall.TIOCMBIS
+	}
+	bits := int32(p.bits)
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, p.port.Fd(), request, uintptr(unsafe.Pointer(&bits))); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+func (p *serialPTT) Key() error   { return p.set(true) }
+func (p *serialPTT) Unkey() error { return p.set(false) }
+
+func (p *serialPTT) Close() error {
+	p.set(false)
+	return p.port.Close()
+}
+
+// the GPIO character device version 1 ABI from linux/gpio.h
+const (
+	gpioHandleRequestOutput  = 1 << 1
+	gpioGetLineHandleIoctl   = 0xc16cb403 // _IOWR(0xB4, 0x03, struct gpiohandle_request)
+	gpioSetLineValuesIoctl   = 0xc040b409 // _IOWR(0xB4, 0x09, struct gpiohandle_data)
+	gpioHandlesMax           = 64
+	gpioConsumerLabel        = "aprsgo ptt"
+	gpioConsumerLabelMaxSize = 32
+)
+
+type gpioHandleRequest struct {
+	lineOffsets   [gpioHandlesMax]uint32
+	flags         uint32
+	defaultValues [gpioHandlesMax]uint8
+	consumerLabel [gpioConsumerLabelMaxSize]byte
+	lines         uint32
+	fd            int32
+}
+
+type gpioHandleData struct {
+	values [gpioHandlesMax]uint8
+}
+
+// gpioCharPTT keys with a line requested from a GPIO character device
+type gpioCharPTT struct {
+	line   *os.File // the line handle returned by the chip
+	invert bool
+}
+
+// NewGPIOCharPTT requests the line of the GPIO chip device, such as /dev/gpiochip0, as an output
+// driven high to key or low if invert is set
+func NewGPIOCharPTT(chip string, line int, invert bool) (PTT, error) {
+	f, err := os.Open(chip)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	request := gpioHandleRequest{flags: gpioHandleRequestOutput, lines: 1}
+	request.lineOffsets[0] = uint32(line)
+	if invert {
+		request.defaultValues[0] = 1
+	}
+	copy(request.consumerLabel[:], gpioConsumerLabel)
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), gpioGetLineHandleIoctl, uintptr(unsafe.Pointer(&request))); errno != 0 {
+		return nil, fmt.Errorf("requesting line %d of %s: %v", line, chip, errno)
+	}
+	return &gpioCharPTT{line: os.NewFile(uintptr(request.fd), chip), invert: invert}, nil
+}
+
+func (p *gpioCharPTT) set(keyed bool) error {
+	var data gpioHandleData
+	if keyed != p.invert {
+		data.values[0] = 1
+	}
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, p.line.Fd(), gpioSetLineValuesIoctl, uintptr(unsafe.Pointer(&data))); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+func (p *gpioCharPTT) Key() error   { return p.set(true) }
+func (p *gpioCharPTT) Unkey() error { return p.set(false) }
+
+func (p *gpioCharPTT) Close() error {
+	p.set(false)
+	return p.line.Close()
+}
@@ -0,0 +1,22 @@
+//go:build !linux
+
+package aprsgo
+
+// ptt_other.go reports serial and GPIO character device PTT as unsupported outside Linux
+
+import (
+	"errors"
+)
+
+var errPTTUnsupported = errors.New("PTT control is only supported on Linux")
+
+// NewSerialPTT keys by asserting the line of the serial port device, or clearing it if invert is set
+func NewSerialPTT(device string, line SerialLine, invert bool) (PTT, error) {
+	return nil, errPTTUnsupported
+}
+
+// NewGPIOCharPTT requests the line of the GPIO chip device, such as /dev/gpiochip0, as an output
+// driven high to key or low if invert is set
+func NewGPIOCharPTT(chip string, line int, invert bool) (PTT, error) {
+	return nil, errPTTUnsupported
+}
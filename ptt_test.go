@@ -0,0 +1,162 @@
+package aprsgo
+
+import (
+	"bufio"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeRigctld answers PTT commands with the reply for each, recording the commands received
+func fakeRigctld(t *testing.T, replies map[string]string) (net.Listener, <-chan string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error starting fake rigctld: %v", err)
+	}
+	commands := make(chan string, 10)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			reader := bufio.NewReader(conn)
+			for {
+				line, err := reader.ReadString('\n')
+				if err != nil {
+					break
+				}
+				command := strings.TrimSpace(line)
+				commands <- command
+				reply, ok := replies[command]
+				if !ok {
+					reply = "RPRT -1"
+				}
+				conn.Write([]byte(reply + "\n"))
+			}
+			conn.Close()
+		}
+	}()
+	return ln, commands
+}
+
+func TestRigctldPTT(t *testing.T) {
+	ln, commands := fakeRigctld(t, map[string]string{"T 1": "RPRT 0", "T 0": "RPRT 0"})
+	defer ln.Close()
+
+	ptt, err := DialRigctld(ln.Addr().String())
+	if err != nil {
+		t.Fatalf("DialRigctld failed: %v", err)
+	}
+	if err := ptt.Key(); err != nil {
+		t.Errorf("Key failed: %v", err)
+	}
+	if err := ptt.Unkey(); err != nil {
+		t.Errorf("Unkey failed: %v", err)
+	}
+	if err := ptt.Close(); err != nil {
+		t.Errorf("Close failed: %v", err)
+	}
+	for _, expected := range []string{"T 1", "T 0", "T 0"} {
+		if command := <-commands; command != expected {
+			t.Errorf("expected command %q, got %q", expected, command)
+		}
+	}
+}
+
+func TestRigctldPTTError(t *testing.T) {
+	ln, _ := fakeRigctld(t, map[string]string{"T 1": "RPRT -11", "T 0": "garbage"})
+	defer ln.Close()
+
+	ptt, err := DialRigctld(ln.Addr().String())
+	if err != nil {
+		t.Fatalf("DialRigctld failed: %v", err)
+	}
+	defer ptt.Close()
+	if err := ptt.Key(); err == nil || !strings.Contains(err.Error(), "RPRT -11") {
+		t.Errorf("expected the Hamlib error reported, got %v", err)
+	}
+	if err := ptt.Unkey(); err == nil {
+		t.Errorf("expected an unexpected reply to fail")
+	}
+}
+
+func TestGPIOSysfsPTT(t *testing.T) {
+	root := t.TempDir()
+	defer func(saved string) { gpioSysfsRoot = saved }(gpioSysfsRoot)
+	gpioSysfsRoot = root
+
+	// the kernel creates the pin directory when it is exported, the fake has it already
+	dir := filepath.Join(root, "gpio17")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"direction", "value"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("in"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	read := func(name string) string {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+
+	tests := []struct {
+		invert                 bool
+		direction, keyed, idle string
+	}{
+		{false, "low", "1", "0"},
+		{true, "high", "0", "1"},
+	}
+	for _, test := range tests {
+		ptt, err := NewGPIOSysfsPTT(17, test.invert)
+		if err != nil {
+			t.Fatalf("NewGPIOSysfsPTT failed: %v", err)
+		}
+		if direction := read("direction"); direction != test.direction {
+			t.Errorf("invert %t: expected direction %q, got %q", test.invert, test.direction, direction)
+		}
+		ptt.Key()
+		if value := read("value")[:1]; value != test.keyed {
+			t.Errorf("invert %t: expected keyed value %q, got %q", test.invert, test.keyed, value)
+		}
+		ptt.Close()
+		if value := read("value")[:1]; value != test.idle {
+			t.Errorf("invert %t: expected unkeyed value %q, got %q", test.invert, test.idle, value)
+		}
+	}
+
+	if _, err := NewGPIOSysfsPTT(18, false); err == nil {
+		t.Errorf("expected an error for a pin that cannot be exported")
+	}
+}
+
+func TestCM108PTT(t *testing.T) {
+	device := filepath.Join(t.TempDir(), "hidraw0")
+	if err := os.WriteFile(device, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewCM108PTT(device, 9); err == nil {
+		t.Errorf("expected an error for pin 9")
+	}
+	ptt, err := NewCM108PTT(device, 3)
+	if err != nil {
+		t.Fatalf("NewCM108PTT failed: %v", err)
+	}
+	ptt.Key()
+	ptt.Unkey()
+	ptt.Close()
+	reports, err := os.ReadFile(device)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := string([]byte{0, 0, 4, 4, 0, 0, 0, 4, 0, 0, 0, 0, 4, 0, 0})
+	if string(reports) != expected {
+		t.Errorf("expected HID reports % x, got % x", expected, reports)
+	}
+}
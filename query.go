@@ -0,0 +1,336 @@
+package aprsgo
+
+// query.go contains routines for producing APRS queries and building
+// the responses to queries received by a station
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const queryDataTypeIdentifier = '?' // general query
+
+// QueryType identifies an APRS query
+type QueryType string
+
+// General queries are broadcast to all stations, directed queries are sent as a message to one station
+const (
+	QueryAll      QueryType = "APRS"  // ?APRS? all stations respond with their position
+	QueryIGate    QueryType = "IGATE" // ?IGATE? IGates respond with their capabilities
+	QueryWeather  QueryType = "WX"    // ?WX? weather stations respond with their weather report
+	QueryPosition QueryType = "APRSP" // ?APRSP the station responds with its position
+	QueryStatus   QueryType = "APRSS" // ?APRSS the station responds with its status
+	QueryDirect   QueryType = "APRSD" // ?APRSD the station responds with the stations heard direct
+	QueryHeard    QueryType = "APRSH" // ?APRSH CALL the station responds with how often it heard CALL
+	QueryTrace    QueryType = "APRST" // ?APRST the station responds with the path the query took
+	QueryPing     QueryType = "PING?" // ?PING? the same as ?APRST
+)
+
+const earthRadiusMiles = 3958.8 // mean radius of the earth for footprint distances
+
+var (
+	directHeardWindow = 30 * time.Minute // stations heard direct within this window are listed for ?APRSD
+	heardHistoryHours = 8                // hours of packet counts returned for ?APRSH
+)
+
+// QueryData contains the data to construct an APRS query
+type QueryData struct {
+	Callsign    string // limited to 6 ASCII characters
+	StationSSID SSID
+	Query       QueryType
+	Addressee   string  // the queried station, required for directed queries
+	Target      string  // the callsign asked about by ?APRSH
+	Latitude    float64 // center of the footprint for general queries
+	Longitude   float64
+	Radius      float64 // radius of the footprint in miles, 0 for everyone
+}
+
+// IsDirected returns true if the query is sent as a message to a single station
+func (data QueryData) IsDirected() bool {
+	switch data.Query {
+	case QueryAll, QueryIGate, QueryWeather:
+		return false
+	}
+	return true
+}
+
+// QueryAPRSReport constructs an APRS query, directed queries are sent as a message to the Addressee
+func (data QueryData) QueryAPRSReport() (AX25Data, error) {
+	if err := data.Validate(); err != nil {
+		return nil, err
+	}
+
+	destinationAddress := Address{Callsign: Version, SSID: DestSSIDVIAPath}
+	sourceAddress := Address{Callsign: data.Callsign, SSID: data.StationSSID}
+	informationField := data.CalculateQueryInformationField()
+
+	ax25data := AssembleAX25Data(sourceAddress, destinationAddress, informationField)
+
+	return AX25Data(ax25data), nil
+}
+
+// CalculateQueryInformationField returns the general query, e.g. ?APRS?, with an optional footprint
+// or a message containing the directed query, e.g. :W1AW     :?APRSP
+func (data QueryData) CalculateQueryInformationField() []byte {
+	if data.IsDirected() {
+		text := "?" + string(data.Query)
+		if data.Query == QueryHeard {
+			text += " " + data.Target
+		}
+		message := MessageData{Addressee: data.Addressee, Text: text}
+		return message.CalculateMessageInformationField()
+	}
+	informationField := fmt.Sprintf("%c%s%c", queryDataTypeIdentifier, data.Query, queryDataTypeIdentifier)
+	if data.Radius > 0 {
+		informationField += fmt.Sprintf(" %.2f %.2f %04.0f", data.Latitude, data.Longitude, data.Radius)
+	}
+	return []byte(informationField)
+}
+
+// ParseQuery extracts a general or directed query from a received packet
+func ParseQuery(packet Packet) (QueryData, error) {
+	var data QueryData
+	source, err := ParseTacticalAddress(packet.Source)
+	if err != nil {
+		return data, err
+	}
+	data.Callsign, data.StationSSID = source.Callsign, source.SSID
+	informationField := packet.Information
+	if len(informationField) == 0 {
+		return data, errors.New("empty information field")
+	}
+
+	if informationField[0] == queryDataTypeIdentifier {
+		end := strings.IndexByte(string(informationField[1:]), queryDataTypeIdentifier)
+		if end < 0 {
+			return data, errors.New("general query is not terminated by ?")
+		}
+		data.Query = QueryType(informationField[1 : end+1])
+		if data.IsDirected() {
+			return data, fmt.Errorf("unknown general query ?%s?", data.Query)
+		}
+		footprint := strings.Fields(string(informationField[end+2:]))
+		if len(footprint) >= 3 {
+			lat, latErr := strconv.ParseFloat(footprint[0], 64)
+			long, longErr := strconv.ParseFloat(footprint[1], 64)
+			radius, radiusErr := strconv.ParseFloat(footprint[2], 64)
+			if latErr != nil || longErr != nil || radiusErr != nil {
+				return data, fmt.Errorf("malformed query footprint %q", informationField[end+2:])
+			}
+			data.Latitude, data.Longitude, data.Radius = lat, long, radius
+		}
+		return data, nil
+	}
+
+	message, err := ParseMessageInformationField(informationField)
+	if err != nil {
+		return data, err
+	}
+	if !strings.HasPrefix(message.Text, "?") {
+		return data, errors.New("message is not a query")
+	}
+	fields := strings.Fields(message.Text[1:])
+	if len(fields) == 0 {
+		return data, errors.New("empty directed query")
+	}
+	data.Query = QueryType(strings.ToUpper(fields[0]))
+	data.Addressee = message.Addressee
+	switch data.Query {
+	case QueryPosition, QueryStatus, QueryDirect, QueryTrace, QueryPing:
+	case QueryHeard:
+		if len(fields) < 2 {
+			return data, errors.New("?APRSH query without a callsign")
+		}
+		data.Target = strings.ToUpper(fields[1])
+	default:
+		return data, fmt.Errorf("unknown directed query ?%s", data.Query)
+	}
+	return data, nil
+}
+
+// HeardList records the stations heard by this station
+type HeardList struct {
+	stations map[string][]heardPacket // the packets heard keyed by source address
+}
+
+type heardPacket struct {
+	at     time.Time
+	direct bool // heard without being repeated by a digipeater
+}
+
+// NewHeardList returns an empty HeardList
+func NewHeardList() *HeardList {
+	return &HeardList{stations: make(map[string][]heardPacket)}
+}
+
+// Add records a packet heard at the given time
+func (h *HeardList) Add(packet Packet, at time.Time) {
+	direct := true
+	for _, digipeater := range packet.Path {
+		if strings.HasSuffix(digipeater, "*") {
+			direct = false
+		}
+	}
+	h.stations[packet.Source] = append(h.stations[packet.Source], heardPacket{at: at, direct: direct})
+}
+
+// Expire removes all packets heard before the given time
+func (h *HeardList) Expire(before time.Time) {
+	for station, packets := range h.stations {
+		kept := packets[:0]
+		for _, heard := range packets {
+			if !heard.at.Before(before) {
+				kept = append(kept, heard)
+			}
+		}
+		if len(kept) == 0 {
+			delete(h.stations, station)
+		} else {
+			h.stations[station] = kept
+		}
+	}
+}
+
+// Direct returns the sorted list of stations heard direct since the given time
+func (h *HeardList) Direct(since time.Time) []string {
+	var stations []string
+	for station, packets := range h.stations {
+		for _, heard := range packets {
+			if heard.direct && !heard.at.Before(since) {
+				stations = append(stations, station)
+				break
+			}
+		}
+	}
+	sort.Strings(stations)
+	return stations
+}
+
+// HourlyCounts returns the number of packets heard from station in each of the last hours before now,
+// the most recent hour first
+func (h *HeardList) HourlyCounts(station string, now time.Time, hours int) []int {
+	counts := make([]int, hours)
+	for _, heard := range h.stations[station] {
+		age := now.Sub(heard.at)
+		if age >= 0 && age < time.Duration(hours)*time.Hour {
+			counts[int(age/time.Hour)]++
+		}
+	}
+	return counts
+}
+
+// Responder builds the responses of a station to the queries it receives
+type Responder struct {
+	Position     PositionData     // the station's position report and callsign used in every response
+	Status       StatusData       // the status for ?APRSS, not sent if it has no text or locator
+	Capabilities CapabilitiesData // the capabilities for ?IGATE?, only IGates with capabilities respond
+	Weather      []byte           // the information field of the latest weather report, nil if not a weather station
+	Heard        *HeardList       // the stations heard for ?APRSD and ?APRSH, may be nil
+}
+
+// Respond returns the response packets to the query in packet, received at now.
+// Directed queries for other stations and queries outside a footprint get no response,
+// an error is only returned if the responder's own reports fail validation.
+func (r Responder) Respond(packet Packet, now time.Time) ([]AX25Data, error) {
+	query, err := ParseQuery(packet)
+	if err != nil {
+		return nil, nil
+	}
+	if query.IsDirected() && !strings.EqualFold(query.Addressee, r.address()) {
+		return nil, nil
+	}
+	if query.Radius > 0 && distanceMiles(query.Latitude, query.Longitude, r.Position.Latitude, r.Position.Longitude) > query.Radius {
+		return nil, nil
+	}
+
+	reply := MessageData{
+		Callsign:    r.Position.Callsign,
+		StationSSID: r.Position.StationSSID,
+		Addressee:   packet.Source,
+	}
+	switch query.Query {
+	case QueryAll, QueryPosition:
+		return response(r.Position.BasicAPRSReport())
+	case QueryIGate:
+		if len(r.Capabilities.Capabilities) == 0 {
+			return nil, nil
+		}
+		capabilities := r.Capabilities
+		capabilities.Callsign, capabilities.StationSSID = r.Position.Callsign, r.Position.StationSSID
+		return response(capabilities.CapabilitiesAPRSReport())
+	case QueryWeather:
+		if r.Weather == nil {
+			return nil, nil
+		}
+		if err := validateStation(r.Position.Callsign, r.Position.StationSSID); err != nil {
+			return nil, err
+		}
+		destinationAddress := Address{Callsign: Version, SSID: DestSSIDVIAPath}
+		sourceAddress := Address{Callsign: r.Position.Callsign, SSID: r.Position.StationSSID}
+		return []AX25Data{AX25Data(AssembleAX25Data(sourceAddress, destinationAddress, r.Weather))}, nil
+	case QueryStatus:
+		if r.Status.Text == "" && r.Status.Locator == "" {
+			return nil, nil
+		}
+		status := r.Status
+		status.Callsign, status.StationSSID = r.Position.Callsign, r.Position.StationSSID
+		return response(status.StatusAPRSReport())
+	case QueryDirect:
+		reply.Text = "Directs="
+		if r.Heard != nil {
+			for _, station := range r.Heard.Direct(now.Add(-directHeardWindow)) {
+				reply.Text += " " + station
+			}
+		}
+	case QueryHeard:
+		var counts []string
+		for _, count := range r.heardCounts(query.Target, now) {
+			counts = append(counts, strconv.Itoa(count))
+		}
+		reply.Text = fmt.Sprintf("%s HEARD: %s", query.Target, strings.Join(counts, ","))
+	case QueryTrace, QueryPing:
+		reply.Text = strings.SplitN(packet.String(), ":", 2)[0] // the header as received
+	}
+	if len(reply.Text) > maxMessageLength {
+		reply.Text = reply.Text[:maxMessageLength]
+	}
+	return response(reply.MessageAPRSReport())
+}
+
+// response wraps a single report in the slice of responses
+func response(ax25data AX25Data, err error) ([]AX25Data, error) {
+	if err != nil {
+		return nil, err
+	}
+	return []AX25Data{ax25data}, nil
+}
+
+// address returns the station's address as used for directed queries
+func (r Responder) address() string {
+	if r.Position.StationSSID == 0 {
+		return r.Position.Callsign
+	}
+	return fmt.Sprintf("%s-%d", r.Position.Callsign, r.Position.StationSSID)
+}
+
+func (r Responder) heardCounts(station string, now time.Time) []int {
+	if r.Heard == nil {
+		return make([]int, heardHistoryHours)
+	}
+	return r.Heard.HourlyCounts(station, now, heardHistoryHours)
+}
+
+// distanceMiles returns the great circle distance between two points in statute miles
+func distanceMiles(lat1, long1, lat2, long2 float64) float64 {
+	toRadians := math.Pi / 180
+	dLat := (lat2 - lat1) * toRadians
+	dLong := (long2 - long1) * toRadians
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*toRadians)*math.Cos(lat2*toRadians)*math.Sin(dLong/2)*math.Sin(dLong/2)
+	return 2 * earthRadiusMiles * math.Asin(math.Sqrt(a))
+}
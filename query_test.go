@@ -0,0 +1,126 @@
+package aprsgo
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQueryInformationField(t *testing.T) {
+	testCases := []struct {
+		In   QueryData
+		Want string
+	}{
+		{In: QueryData{Query: QueryAll},
+			Want: "?APRS?",
+		},
+		{In: QueryData{Query: QueryIGate},
+			Want: "?IGATE?",
+		},
+		{In: QueryData{Query: QueryWeather, Latitude: 34.02, Longitude: -117.15, Radius: 200},
+			Want: "?WX? 34.02 -117.15 0200",
+		},
+		{In: QueryData{Query: QueryPosition, Addressee: "W1AW"},
+			Want: ":W1AW     :?APRSP",
+		},
+		{In: QueryData{Query: QueryHeard, Addressee: "W1AW-1", Target: "N0CALL"},
+			Want: ":W1AW-1   :?APRSH N0CALL",
+		},
+		{In: QueryData{Query: QueryPing, Addressee: "W1AW"},
+			Want: ":W1AW     :?PING?",
+		},
+	}
+
+	for _, testCase := range testCases {
+		got := string(testCase.In.CalculateQueryInformationField())
+		if got != testCase.Want {
+			t.Errorf("Expected %s got %s", testCase.Want, got)
+		}
+		parsed, err := ParseQuery(Packet{Source: "N0CALL-9", Information: []byte(got)})
+		if err != nil {
+			t.Errorf("Parsing %s failed with error %v", got, err)
+			continue
+		}
+		want := testCase.In
+		want.Callsign, want.StationSSID = "N0CALL", 9
+		if parsed != want {
+			t.Errorf("Parsing %s expected %+v got %+v", got, want, parsed)
+		}
+	}
+
+	for _, bad := range []string{"?FOO?", "?APRS", ":W1AW     :?FOO", ":W1AW     :?APRSH", ":W1AW     :Hello", "!4142.88N/07243.63W-"} {
+		if _, err := ParseQuery(Packet{Source: "N0CALL", Information: []byte(bad)}); err == nil {
+			t.Errorf("Parsing %s expected to fail, but didn't", bad)
+		}
+	}
+	for _, source := range []string{"N0CALL-0A", "N0CALL-16", "n0call", "TOOLONGCALL"} {
+		if _, err := ParseQuery(Packet{Source: source, Information: []byte("?APRS?")}); err == nil {
+			t.Errorf("Parsing a query from %s expected to fail, but didn't", source)
+		}
+	}
+	if parsed, err := ParseQuery(Packet{Source: "SHELTER-2", Information: []byte("?APRS?")}); err != nil || parsed.Callsign != "SHELTER" || parsed.StationSSID != 2 {
+		t.Errorf("Parsing a query from a tactical callsign expected SHELTER-2 got %+v (%v)", parsed, err)
+	}
+}
+
+func TestResponder(t *testing.T) {
+	now := time.Date(2017, 5, 1, 12, 0, 0, 0, time.UTC)
+	heard := NewHeardList()
+	heard.Add(Packet{Source: "N0CALL", Path: []string{"WIDE1-1"}}, now.Add(-5*time.Minute))
+	heard.Add(Packet{Source: "N0CALL", Path: []string{"WIDE1-1"}}, now.Add(-90*time.Minute))
+	heard.Add(Packet{Source: "KB1AAA-9", Path: []string{"W1XM*", "WIDE2-1"}}, now.Add(-10*time.Minute))
+	heard.Add(Packet{Source: "AA1AA"}, now.Add(-time.Minute))
+	heard.Add(Packet{Source: "OLD"}, now.Add(-9*time.Hour))
+	heard.Expire(now.Add(-8 * time.Hour))
+
+	responder := Responder{
+		Position:     PositionData{Callsign: "W1AW", StationSSID: 10, Latitude: 41.7147, Longitude: -72.7272, Comment: "Test"},
+		Status:       StatusData{Text: "Monitoring 144.39"},
+		Capabilities: NewIGateCapabilities("", 0, 3, 2),
+		Heard:        heard,
+	}
+
+	testCases := []struct {
+		In   string
+		Path []string
+		Want []string
+	}{
+		{In: "?APRS?", Want: []string{"W1AW-10>APZ001:!4142.88N/07243.63W-Test"}},
+		{In: "?APRS? 41.70 -72.70 0010", Want: []string{"W1AW-10>APZ001:!4142.88N/07243.63W-Test"}},
+		{In: "?APRS? 34.02 -117.15 0200"}, // outside the footprint
+		{In: "?IGATE?", Want: []string{"W1AW-10>APZ001:<IGATE,MSG_CNT=3,LOC_CNT=2"}},
+		{In: "?WX?"}, // not a weather station
+		{In: ":W1AW-10  :?APRSP", Want: []string{"W1AW-10>APZ001:!4142.88N/07243.63W-Test"}},
+		{In: ":W1AW-10  :?APRSS", Want: []string{"W1AW-10>APZ001:>Monitoring 144.39"}},
+		{In: ":W1AW-10  :?APRSD", Want: []string{"W1AW-10>APZ001::KB2ICI   :Directs= AA1AA N0CALL"}},
+		{In: ":W1AW-10  :?APRSH N0CALL", Want: []string{"W1AW-10>APZ001::KB2ICI   :N0CALL HEARD: 1,1,0,0,0,0,0,0"}},
+		{In: ":W1AW-10  :?PING?", Path: []string{"W1XM*", "WIDE2-1"},
+			Want: []string{"W1AW-10>APZ001::KB2ICI   :KB2ICI>APZ001,W1XM*,WIDE2-1"}},
+		{In: ":W1AW     :?APRSP"}, // directed to another station
+	}
+
+	for _, testCase := range testCases {
+		query := Packet{Source: "KB2ICI", Destination: "APZ001", Path: testCase.Path, Information: []byte(testCase.In)}
+		responses, err := responder.Respond(query, now)
+		if err != nil {
+			t.Errorf("Query %s failed with error %v", testCase.In, err)
+			continue
+		}
+		if len(responses) != len(testCase.Want) {
+			t.Errorf("Query %s expected %d responses, got %d", testCase.In, len(testCase.Want), len(responses))
+			continue
+		}
+		for i, response := range responses {
+			packet, err := response.Decode()
+			if err != nil {
+				t.Errorf("Query %s response failed to decode: %v", testCase.In, err)
+			} else if packet.String() != testCase.Want[i] {
+				t.Errorf("Query %s expected response %s got %s", testCase.In, testCase.Want[i], packet.String())
+			}
+		}
+	}
+
+	responder.Weather = []byte("_10090556c220s004g005t077r000p000P000h50b09900")
+	if responses, _ := responder.Respond(Packet{Source: "KB2ICI", Information: []byte("?WX?")}, now); len(responses) != 1 {
+		t.Errorf("A weather station should respond to ?WX?")
+	}
+}
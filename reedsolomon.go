@@ -0,0 +1,212 @@
+package aprsgo
+
+// reedsolomon.go contains the Reed-Solomon codes over GF(2^8) used by FX.25 and IL2P,
+// with the field polynomial 0x11d and nroots consecutive generator roots from alpha^firstRoot,
+// shortened codes are handled by leaving out the leading zero symbols
+
+import (
+	"errors"
+)
+
+const (
+	gfPolynomial = 0x11d // x^8 + x^4 + x^3 + x^2 + 1
+	gfSize       = 255   // the number of nonzero field elements, the full code length
+)
+
+// ErrUncorrectable is returned for a codeword with more errors than the code can correct
+var ErrUncorrectable = errors.New("too many errors to correct")
+
+var gfExp, gfLog = gfTables()
+
+// gfTables returns the powers of alpha, repeated to avoid reducing sums of logarithms, and their logarithms
+func gfTables() ([2 * gfSize]byte, [gfSize + 1]int) {
+	var exp [2 * gfSize]byte
+	var log [gfSize + 1]int
+	x := 1
+	for i := 0; i < gfSize; i++ {
+		exp[i], exp[i+gfSize] = byte(x), byte(x)
+		log[x] = i
+		x <<= 1
+		if x > gfSize {
+			x ^= gfPolynomial
+		}
+	}
+	return exp, log
+}
+
+func gfMul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gfExp[gfLog[a]+gfLog[b]]
+}
+
+func gfDiv(a, b byte) byte {
+	if a == 0 {
+		return 0
+	}
+	return gfExp[gfLog[a]+gfSize-gfLog[b]]
+}
+
+// gfPow returns alpha^n
+func gfPow(n int) byte {
+	n %= gfSize
+	if n < 0 {
+		n += gfSize
+	}
+	return gfExp[n]
+}
+
+// reedSolomon is a systematic code with nroots parity symbols following the data
+type reedSolomon struct {
+	nroots    int
+	firstRoot int    // the generator polynomial roots start at alpha^firstRoot
+	generator []byte // coefficients highest degree first, the leading 1 is left out
+}
+
+// newReedSolomon returns the code with nroots parity symbols
+func newReedSolomon(nroots int, firstRoot int) *reedSolomon {
+	// g(x) = (x - alpha^firstRoot)...(x - alpha^(firstRoot+nroots-1)), lowest degree first while building
+	g := []byte{1}
+	for i := 0; i < nroots; i++ {
+		root := gfPow(firstRoot + i)
+		next := make([]byte, len(g)+1)
+		for j, c := range g {
+			next[j] ^= gfMul(c, root)
+			next[j+1] ^= c
+		}
+		g = next
+	}
+	generator := make([]byte, nroots)
+	for i := range generator {
+		generator[i] = g[nroots-1-i]
+	}
+	return &reedSolomon{nroots: nroots, firstRoot: firstRoot, generator: generator}
+}
+
+// encode returns the parity symbols for data
+func (rs *reedSolomon) encode(data []byte) []byte {
+	parity := make([]byte, rs.nroots)
+	for _, d := range data {
+		feedback := d ^ parity[0]
+		copy(parity, parity[1:])
+		parity[rs.nroots-1] = 0
+		if feedback != 0 {
+			for i, g := range rs.generator {
+				parity[i] ^= gfMul(feedback, g)
+			}
+		}
+	}
+	return parity
+}
+
+// syndromes evaluates the codeword at each generator root, all zero for a valid codeword
+func (rs *reedSolomon) syndromes(codeword []byte) ([]byte, bool) {
+	syndromes := make([]byte, rs.nroots)
+	valid := true
+	for j := range syndromes {
+		root := gfPow(rs.firstRoot + j)
+		var s byte
+		for _, c := range codeword {
+			s = gfMul(s, root) ^ c
+		}
+		syndromes[j] = s
+		valid = valid && s == 0
+	}
+	return syndromes, valid
+}
+
+// decode corrects the data and parity of codeword in place and returns the number of symbols corrected,
+// the codeword is left unchanged if it cannot be corrected, except in the rare case the correction itself fails
+func (rs *reedSolomon) decode(codeword []byte) (int, error) {
+	n := len(codeword)
+	if n <= rs.nroots || n > gfSize {
+		return 0, errors.New("codeword length does not fit the code")
+	}
+	syndromes, valid := rs.syndromes(codeword)
+	if valid {
+		return 0, nil
+	}
+
+	// Berlekamp-Massey finds the error locator lambda, lowest degree first
+	lambda, previous := []byte{1}, []byte{1}
+	length, shift, previousDiscrepancy := 0, 1, byte(1)
+	for k := 0; k < rs.nroots; k++ {
+		discrepancy := syndromes[k]
+		for i := 1; i <= length && i < len(lambda); i++ {
+			discrepancy ^= gfMul(lambda[i], syndromes[k-i])
+		}
+		if discrepancy == 0 {
+			shift++
+			continue
+		}
+		scale := gfDiv(discrepancy, previousDiscrepancy)
+		size := len(lambda)
+		if len(previous)+shift > size {
+			size = len(previous) + shift
+		}
+		next := make([]byte, size)
+		copy(next, lambda)
+		for i, c := range previous {
+			next[i+shift] ^= gfMul(scale, c)
+		}
+		if 2*length <= k {
+			previous, previousDiscrepancy = lambda, discrepancy
+			length, shift = k+1-length, 1
+		} else {
+			shift++
+		}
+		lambda = next
+	}
+	if 2*length > rs.nroots {
+		return 0, ErrUncorrectable
+	}
+
+	// omega(x) = S(x) lambda(x) mod x^nroots evaluates the error magnitudes
+	omega := make([]byte, rs.nroots)
+	for i := range omega {
+		for j := 0; j <= i && j < len(lambda); j++ {
+			omega[i] ^= gfMul(lambda[j], syndromes[i-j])
+		}
+	}
+
+	// the Chien search tries every position, the symbol at index i is the coefficient of x^(n-1-i)
+	var positions []int
+	var magnitudes []byte
+	for i := 0; i < n; i++ {
+		power := n - 1 - i
+		xInverse := gfPow(-power)
+		if evaluate(lambda, xInverse) != 0 {
+			continue
+		}
+		// Forney: e = X^(1-firstRoot) omega(X^-1) / lambda'(X^-1)
+		var derivative byte
+		for j := 1; j < len(lambda); j += 2 { // the odd terms survive differentiation in GF(2^m)
+			derivative ^= gfMul(lambda[j], gfPow(-power*(j-1)))
+		}
+		if derivative == 0 {
+			return 0, ErrUncorrectable
+		}
+		magnitude := gfMul(gfDiv(evaluate(omega, xInverse), derivative), gfPow(power*(1-rs.firstRoot)))
+		positions, magnitudes = append(positions, i), append(magnitudes, magnitude)
+	}
+	if len(positions) != length {
+		return 0, ErrUncorrectable // the locator has roots outside the shortened codeword
+	}
+	for k, i := range positions {
+		codeword[i] ^= magnitudes[k]
+	}
+	if _, valid := rs.syndromes(codeword); !valid {
+		return 0, ErrUncorrectable
+	}
+	return len(positions), nil
+}
+
+// evaluate returns the polynomial with coefficients lowest degree first at x
+func evaluate(polynomial []byte, x byte) byte {
+	var result byte
+	for i := len(polynomial) - 1; i >= 0; i-- {
+		result = gfMul(result, x) ^ polynomial[i]
+	}
+	return result
+}
@@ -0,0 +1,53 @@
+package aprsgo
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+func TestReedSolomonCorrection(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	for _, nroots := range []int{2, 16, 32, 64} {
+		rs := newReedSolomon(nroots, nroots%2) // first roots alpha^0 for IL2P and alpha^1 for FX.25
+		for _, dataSize := range []int{13, 32, 64, 128, 255 - nroots} {
+			data := make([]byte, dataSize)
+			random.Read(data)
+			codeword := append(append([]byte{}, data...), rs.encode(data)...)
+			if _, valid := rs.syndromes(codeword); !valid {
+				t.Errorf("RS(%d,%d) encoded an invalid codeword", len(codeword), dataSize)
+				continue
+			}
+
+			for errors := 0; errors <= nroots/2; errors += 1 + nroots/8 {
+				received := append([]byte{}, codeword...)
+				for _, i := range random.Perm(len(received))[:errors] {
+					received[i] ^= byte(1 + random.Intn(255))
+				}
+				corrected, err := rs.decode(received)
+				if err != nil || corrected != errors || !bytes.Equal(received, codeword) {
+					t.Errorf("RS(%d,%d) with %d errors corrected %d, %v", len(codeword), dataSize, errors, corrected, err)
+				}
+			}
+
+			received := append([]byte{}, codeword...)
+			for _, i := range random.Perm(len(received))[:nroots/2+1] {
+				received[i] ^= byte(1 + random.Intn(255))
+			}
+			if _, err := rs.decode(received); err == nil && bytes.Equal(received, codeword) {
+				t.Errorf("RS(%d,%d) corrected more errors than it can", len(codeword), dataSize)
+			}
+		}
+	}
+}
+
+func TestGaloisField(t *testing.T) {
+	for a := 1; a < 256; a++ {
+		if gfMul(byte(a), gfDiv(1, byte(a))) != 1 {
+			t.Errorf("%d times its inverse is not 1", a)
+		}
+	}
+	if gfMul(0x80, 2) != 0x1d {
+		t.Errorf("Expected alpha^8 = 0x1d got %02x", gfMul(0x80, 2))
+	}
+}
@@ -0,0 +1,103 @@
+package aprsgo
+
+// session.go contains the builders for audio of several packets: a transmission sends frames in
+// one key-up of the transmitter, and a session lays out transmissions with silence between them
+// in one recording, as used for benchmarking receivers
+
+import (
+	"time"
+)
+
+// DefaultTiming selects the TXDELAY or TXTAIL of DefaultChannelAccess for a transmission
+const DefaultTiming time.Duration = -1
+
+// Transmission is one key-up of the transmitter sending frames back to back
+type Transmission struct {
+	Frames  []AX25Data
+	TXDelay time.Duration // flags sent before the first frame, at least one, or DefaultTiming
+	TXTail  time.Duration // flags sent after the last frame, at least one, or DefaultTiming
+}
+
+// Encode converts the frames of the transmission to symbols with a shared preamble and a flag between frames
+func (t Transmission) Encode() SymbolStream {
+	txDelay, txTail := t.TXDelay, t.TXTail
+	if txDelay == DefaultTiming {
+		txDelay = DefaultChannelAccess.TXDelay
+	}
+	if txTail == DefaultTiming {
+		txTail = DefaultChannelAccess.TXTail
+	}
+	return EncodeBurst(t.Frames, flags(txDelay), flags(txTail))
+}
+
+// Session is a recording of transmissions separated by silence
+type Session struct {
+	LeadIn        time.Duration // silence before the first transmission
+	Gap           time.Duration // silence between transmissions
+	Trail         time.Duration // silence after the last transmission
+	Transmissions []Transmission
+}
+
+// Add appends a transmission of the frames to the session
+func (s *Session) Add(txDelay, txTail time.Duration, frames ...AX25Data) {
+	s.Transmissions = append(s.Transmissions, Transmission{Frames: frames, TXDelay: txDelay, TXTail: txTail})
+}
+
+// Frames returns the number of frames sent in the session
+func (s Session) Frames() int {
+	frames := 0
+	for _, t := range s.Transmissions {
+		frames += len(t.Frames)
+	}
+	return frames
+}
+
+// Modulate returns the audio of the session as samples between -1.0 and 1.0, scaled by the volume
+// set with SetVolume and shaped as set with SetToneShaping, none below one sample per symbol
+func (s Session) Modulate(samplesPerSecond uint32) []float64 {
+	w, err := newWaveWriter(samplesPerSecond, 16, 1) // only the timing, phase and shaping of the writer are used
+	if err != nil {
+		return nil
+	}
+	var samples []float64
+	s.render(w, func(sample float64) {
+		samples = append(samples, scalingFactor*sample)
+	})
+	return samples
+}
+
+// WriteWAV writes the session out to a WAV file with parameters given by params
+func (s Session) WriteWAV(params WAVParams) error {
+	wr, err := newWaveWriter(params.SamplesPerSecond, params.BitsPerSample, params.NumChannels)
+	if err != nil {
+		return err
+	}
+	if params.Float {
+		if err := wr.useFloat(); err != nil {
+			return err
+		}
+	}
+	s.render(wr, wr.writeSample)
+	return wr.writeFile(params.Filename)
+}
+
+// render passes each sample of the session from the writer to emit, at full scale
+func (s Session) render(w *waveWriter, emit func(float64)) {
+	silence := func(d time.Duration) {
+		for n := int64(d) * int64(w.samplesPerSecond) / int64(time.Second); n > 0; n-- {
+			emit(0)
+		}
+	}
+	silence(s.LeadIn)
+	for i, t := range s.Transmissions {
+		if i > 0 {
+			silence(s.Gap)
+		}
+		for _, sym := range t.Encode() {
+			for n := w.nextSymbolSamples(); n > 0; n-- {
+				emit(w.nextSample(sym))
+			}
+		}
+	}
+	silence(s.Trail)
+}
@@ -0,0 +1,122 @@
+package aprsgo
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTransmissionEncode(t *testing.T) {
+	frames := []AX25Data{testAX25Data(t, "one"), testAX25Data(t, "two"), testAX25Data(t, "three")}
+	transmission := Transmission{Frames: frames, TXDelay: 100 * time.Millisecond, TXTail: 20 * time.Millisecond}
+	symbolStream := transmission.Encode()
+	expected := 8 * (15 + 2 + 3) // preamble, flags between frames, tail
+	for _, ax25data := range frames {
+		expected += len(stuffBits(ax25data)) - 16
+	}
+	if len(symbolStream) != expected {
+		t.Errorf("expected %d symbols, got %d", expected, len(symbolStream))
+	}
+	decoded := symbolStream.Decode()
+	if len(decoded) != len(frames) {
+		t.Fatalf("expected %d frames decoded, got %d", len(frames), len(decoded))
+	}
+	for i := range frames {
+		if string(decoded[i]) != string(frames[i]) {
+			t.Errorf("frame %d decoded out of order", i)
+		}
+	}
+
+	frameSymbols := len(stuffBits(frames[0])) - 16
+	for _, test := range []struct {
+		txDelay, txTail time.Duration
+		flags           int
+	}{
+		{0, 0, 2}, // at least one flag each side
+		{DefaultTiming, DefaultTiming, 45 + 5},
+		{DefaultTiming, 0, 45 + 1},
+	} {
+		transmission := Transmission{Frames: frames[:1], TXDelay: test.txDelay, TXTail: test.txTail}
+		if symbols := len(transmission.Encode()); symbols != 8*test.flags+frameSymbols {
+			t.Errorf("TXDELAY %v TXTAIL %v: expected %d flags, got %d symbols", test.txDelay, test.txTail, test.flags, symbols)
+		}
+	}
+}
+
+func TestSessionModulate(t *testing.T) {
+	const samplesPerSecond = 48000
+	var session Session
+	session.LeadIn, session.Gap, session.Trail = 250*time.Millisecond, time.Second, 500*time.Millisecond
+	var frames []AX25Data
+	for i := 0; i < 3; i++ {
+		transmission := []AX25Data{testAX25Data(t, fmt.Sprintf("%d one", i)), testAX25Data(t, fmt.Sprintf("%d two", i))}
+		session.Add(300*time.Millisecond, 30*time.Millisecond, transmission...)
+		frames = append(frames, transmission...)
+	}
+	if session.Frames() != 6 {
+		t.Errorf("expected 6 frames in the session, got %d", session.Frames())
+	}
+
+	samples := session.Modulate(samplesPerSecond)
+	silence := map[string][2]int{"lead in": {0, 12000}, "trail": {len(samples) - 24000, len(samples)}}
+	expected := 12000 + 2*48000 + 24000
+	start := 12000
+	for i, transmission := range session.Transmissions {
+		length := 40 * len(transmission.Encode())
+		if i > 0 {
+			silence[fmt.Sprintf("gap %d", i)] = [2]int{start, start + 48000}
+			start += 48000
+		}
+		start += length
+		expected += length
+	}
+	if len(samples) != expected {
+		t.Fatalf("expected %d samples, got %d", expected, len(samples))
+	}
+	for name, span := range silence {
+		for n := span[0]; n < span[1]; n++ {
+			if samples[n] != 0 {
+				t.Fatalf("%s: expected silence, sample %d is %v", name, n, samples[n])
+			}
+		}
+	}
+
+	received := Receiver{}.Receive(samples, samplesPerSecond)
+	if len(received) != len(frames) {
+		t.Fatalf("expected %d frames received, got %d", len(frames), len(received))
+	}
+	for i := range frames {
+		if string(received[i].AX25Data) != string(frames[i]) {
+			t.Errorf("frame %d received out of order", i)
+		}
+	}
+}
+
+func TestSessionWriteWAV(t *testing.T) {
+	var session Session
+	session.LeadIn, session.Gap = 100*time.Millisecond, 200*time.Millisecond
+	session.Add(DefaultTiming, DefaultTiming, testAX25Data(t, "one"))
+	session.Add(DefaultTiming, DefaultTiming, testAX25Data(t, "two"))
+	params := WAVParams{Filename: filepath.Join(t.TempDir(), "session.wav"), SamplesPerSecond: 22050, BitsPerSample: 16, NumChannels: 1}
+	if err := session.WriteWAV(params); err != nil {
+		t.Fatal(err)
+	}
+	audio, err := ReadWAVFile(params.Filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := session.Modulate(params.SamplesPerSecond)
+	if len(audio.Channels[0]) != len(expected) {
+		t.Fatalf("expected %d samples, got %d", len(expected), len(audio.Channels[0]))
+	}
+	for n, sample := range audio.Channels[0] {
+		if math.Abs(sample-expected[n]) > 1.0/32768 {
+			t.Fatalf("sample %d expected %v, got %v", n, expected[n], sample)
+		}
+	}
+	if received := (Receiver{}).Receive(audio.Channels[0], audio.SamplesPerSecond); len(received) != 2 {
+		t.Errorf("expected both transmissions received from the file, got %d", len(received))
+	}
+}
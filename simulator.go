@@ -0,0 +1,191 @@
+package aprsgo
+
+// simulator.go contains an audio channel simulator for testing the receiver without radios, it
+// impairs modulated samples with the emphasis, twist, tuning error, sample clock drift, dropouts
+// and noise of a real channel
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+// Emphasis is the FM audio frequency response of a channel
+type Emphasis int
+
+// Emphasis of the channel audio
+const (
+	FlatAudio   Emphasis = iota // no emphasis, such as a discriminator tap to a flat audio input
+	PreEmphasis                 // rising 6 dB per octave, pre-emphasized audio heard on a flat receiver
+	DeEmphasis                  // falling 6 dB per octave, flat audio heard on a de-emphasized receiver
+)
+
+var (
+	emphasisTimeConstant = 75e-6 // seconds, the FM broadcast and two way radio standard in the US
+	hilbertSpan          = 5e-3  // seconds of audio either side in the frequency shift filter
+)
+
+// ChannelSimulator impairs audio samples like a radio channel, the zero value leaves them unchanged
+type ChannelSimulator struct {
+	Emphasis        Emphasis
+	Twist           float64       // dB the 2200 Hz space tone is raised relative to the 1200 Hz mark tone, negative to lower it
+	FrequencyOffset float64       // Hz every frequency is shifted, as from mistuned SSB
+	ClockDrift      float64       // parts per million the receiving sample clock runs fast, negative for slow
+	DropoutRate     float64       // the average number of dropouts per second
+	DropoutLength   time.Duration // the length of each dropout, when the audio is silent
+	Noise           bool          // add white Gaussian noise at SNR
+	SNR             float64       // dB signal to noise ratio of the added noise over the whole sampled bandwidth
+	Seed            int64         // seeds the dropouts and noise, the same seed impairs the same way
+}
+
+// Apply returns the samples received over the channel, impaired in the order the channel would:
+// the audio response, tuning, sampling and finally the noise
+func (s ChannelSimulator) Apply(samples []float64, samplesPerSecond uint32) []float64 {
+	random := rand.New(rand.NewSource(s.Seed))
+	received := append([]float64{}, samples...)
+	if s.Emphasis != FlatAudio {
+		received = emphasize(received, s.Emphasis, samplesPerSecond)
+	}
+	if s.Twist != 0 {
+		received = twist(received, s.Twist, samplesPerSecond)
+	}
+	if s.FrequencyOffset != 0 {
+		received = shiftFrequency(received, s.FrequencyOffset, samplesPerSecond)
+	}
+	if s.ClockDrift != 0 {
+		received = resample(received, 1+s.ClockDrift*1e-6)
+	}
+	if s.DropoutRate > 0 && s.DropoutLength > 0 {
+		probability := s.DropoutRate / float64(samplesPerSecond)
+		length := int(s.DropoutLength.Seconds() * float64(samplesPerSecond))
+		for n := 0; n < len(received); n++ {
+			if random.Float64() >= probability {
+				continue
+			}
+			for end := n + length; n < end && n < len(received); n++ {
+				received[n] = 0
+			}
+		}
+	}
+	if s.Noise {
+		var power float64
+		for _, sample := range received {
+			power += sample * sample
+		}
+		power /= float64(len(received))
+		sigma := math.Sqrt(power / math.Pow(10, s.SNR/10))
+		for n := range received {
+			received[n] += sigma * random.NormFloat64()
+		}
+	}
+	return received
+}
+
+// emphasize applies first order pre-emphasis or de-emphasis, each the inverse of the other
+func emphasize(samples []float64, emphasis Emphasis, samplesPerSecond uint32) []float64 {
+	f := newEmphasisFilter(emphasis, samplesPerSecond)
+	filtered := make([]float64, len(samples))
+	for n, sample := range samples {
+		filtered[n] = f.filter(sample)
+	}
+	return filtered
+}
+
+// emphasisFilter filters a sample at a time with unity gain at low frequencies, the pre-emphasis rises
+// and the de-emphasis falls from the corner frequency of emphasisTimeConstant
+type emphasisFilter struct {
+	emphasis         Emphasis
+	alpha            float64
+	previous         float64 // the last input for pre-emphasis, the last output for de-emphasis
+	samplesPerSecond uint32
+}
+
+func newEmphasisFilter(emphasis Emphasis, samplesPerSecond uint32) *emphasisFilter {
+	alpha := math.Exp(-1 / (emphasisTimeConstant * float64(samplesPerSecond)))
+	return &emphasisFilter{emphasis: emphasis, alpha: alpha, samplesPerSecond: samplesPerSecond}
+}
+
+func (f *emphasisFilter) filter(sample float64) float64 {
+	switch f.emphasis {
+	case PreEmphasis:
+		filtered := (sample - f.alpha*f.previous) / (1 - f.alpha)
+		f.previous = sample
+		return filtered
+	case DeEmphasis:
+		f.previous = (1-f.alpha)*sample + f.alpha*f.previous
+		return f.previous
+	}
+	return sample
+}
+
+// gain returns the steady state gain of the filter for a tone at frequency
+func (f *emphasisFilter) gain(frequency float64) float64 {
+	w := 2 * math.Pi * frequency / float64(f.samplesPerSecond)
+	rising := math.Hypot(1-f.alpha*math.Cos(w), f.alpha*math.Sin(w)) / (1 - f.alpha)
+	switch f.emphasis {
+	case PreEmphasis:
+		return rising
+	case DeEmphasis:
+		return 1 / rising
+	}
+	return 1
+}
+
+// twist filters the samples with a three tap linear phase filter with unity gain at the mark tone
+// and the twist at the space tone, the output is delayed by a sample
+func twist(samples []float64, twistDB float64, samplesPerSecond uint32) []float64 {
+	// the response of taps a, b, a is b + 2a cos(w)
+	markCos := math.Cos(2 * math.Pi * markFreq / float64(samplesPerSecond))
+	spaceCos := math.Cos(2 * math.Pi * spaceFreq / float64(samplesPerSecond))
+	spaceGain := math.Pow(10, twistDB/20)
+	a := (1 - spaceGain) / (2 * (markCos - spaceCos))
+	b := 1 - 2*a*markCos
+	filtered := make([]float64, len(samples))
+	for n := range samples {
+		filtered[n] = b * sampleAt(samples, n-1)
+		filtered[n] += a * (sampleAt(samples, n) + sampleAt(samples, n-2))
+	}
+	return filtered
+}
+
+// shiftFrequency moves every frequency up by offset Hz, or down if negative, by mixing the analytic
+// signal from a windowed Hilbert transform filter
+func shiftFrequency(samples []float64, offset float64, samplesPerSecond uint32) []float64 {
+	half := int(hilbertSpan * float64(samplesPerSecond))
+	taps := make([]float64, half+1) // the odd taps of one side, the filter is antisymmetric
+	for k := 1; k <= half; k += 2 {
+		window := 0.42 + 0.5*math.Cos(math.Pi*float64(k)/float64(half+1)) + 0.08*math.Cos(2*math.Pi*float64(k)/float64(half+1))
+		taps[k] = 2 / (math.Pi * float64(k)) * window
+	}
+	increment := 2 * math.Pi * offset / float64(samplesPerSecond)
+	shifted := make([]float64, len(samples))
+	for n := range samples {
+		var quadrature float64
+		for k := 1; k <= half; k += 2 {
+			quadrature += taps[k] * (sampleAt(samples, n-k) - sampleAt(samples, n+k))
+		}
+		angle := increment * float64(n)
+		shifted[n] = samples[n]*math.Cos(angle) - quadrature*math.Sin(angle)
+	}
+	return shifted
+}
+
+// resample returns the samples taken ratio times as often, interpolating linearly between them
+func resample(samples []float64, ratio float64) []float64 {
+	resampled := make([]float64, int(math.Round(float64(len(samples))*ratio)))
+	for n := range resampled {
+		position := float64(n) / ratio
+		i := int(position)
+		fraction := position - float64(i)
+		resampled[n] = (1-fraction)*sampleAt(samples, i) + fraction*sampleAt(samples, i+1)
+	}
+	return resampled
+}
+
+// sampleAt returns the sample at n, silence outside the samples
+func sampleAt(samples []float64, n int) float64 {
+	if n < 0 || n >= len(samples) {
+		return 0
+	}
+	return samples[n]
+}
@@ -0,0 +1,189 @@
+package aprsgo
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+)
+
+// toneAmplitude returns the amplitude of the tone at frequency in the samples
+func toneAmplitude(samples []float64, frequency float64, samplesPerSecond uint32) float64 {
+	var inPhase, quadrature float64
+	for n, sample := range samples {
+		angle := 2 * math.Pi * frequency * float64(n) / float64(samplesPerSecond)
+		inPhase += sample * math.Cos(angle)
+		quadrature += sample * math.Sin(angle)
+	}
+	return 2 * math.Hypot(inPhase, quadrature) / float64(len(samples))
+}
+
+func tone(frequency float64, samplesPerSecond uint32, n int) []float64 {
+	samples := make([]float64, n)
+	for i := range samples {
+		samples[i] = math.Sin(2 * math.Pi * frequency * float64(i) / float64(samplesPerSecond))
+	}
+	return samples
+}
+
+func TestChannelSimulatorUnchanged(t *testing.T) {
+	samples := tone(markFreq, 48000, 4800)
+	received := ChannelSimulator{}.Apply(samples, 48000)
+	for i := range samples {
+		if received[i] != samples[i] {
+			t.Fatalf("expected the zero value simulator to leave the samples unchanged, sample %d differs", i)
+		}
+	}
+}
+
+func TestChannelSimulatorResponse(t *testing.T) {
+	const samplesPerSecond = 48000
+	mark, space := tone(markFreq, samplesPerSecond, 48000), tone(spaceFreq, samplesPerSecond, 48000)
+	tests := []struct {
+		simulator ChannelSimulator
+		twist     float64 // the expected space to mark amplitude ratio in dB
+	}{
+		{ChannelSimulator{Twist: 6}, 6},
+		{ChannelSimulator{Twist: -9}, -9},
+		{ChannelSimulator{Emphasis: PreEmphasis}, 1.9},
+		{ChannelSimulator{Emphasis: DeEmphasis}, -1.9},
+		{ChannelSimulator{Emphasis: PreEmphasis, Twist: -1.9}, 0},
+	}
+	for _, test := range tests {
+		markAmplitude := toneAmplitude(test.simulator.Apply(mark, samplesPerSecond), markFreq, samplesPerSecond)
+		spaceAmplitude := toneAmplitude(test.simulator.Apply(space, samplesPerSecond), spaceFreq, samplesPerSecond)
+		twist := 20 * math.Log10(spaceAmplitude/markAmplitude)
+		if math.Abs(twist-test.twist) > 0.2 {
+			t.Errorf("%+v: expected twist %.1f dB, got %.2f dB", test.simulator, test.twist, twist)
+		}
+	}
+}
+
+func TestChannelSimulatorFrequencyOffset(t *testing.T) {
+	const samplesPerSecond = 48000
+	samples := tone(markFreq, samplesPerSecond, 48000)
+	for _, offset := range []float64{150, -150} {
+		received := ChannelSimulator{FrequencyOffset: offset}.Apply(samples, samplesPerSecond)
+		shifted := toneAmplitude(received, markFreq+offset, samplesPerSecond)
+		original := toneAmplitude(received, markFreq, samplesPerSecond)
+		image := toneAmplitude(received, markFreq-offset, samplesPerSecond)
+		if math.Abs(shifted-1) > 0.02 || original > 0.02 || image > 0.02 {
+			t.Errorf("offset %.0f Hz: expected the tone moved, got shifted %.3f original %.3f image %.3f", offset, shifted, original, image)
+		}
+	}
+}
+
+func TestChannelSimulatorClockDrift(t *testing.T) {
+	const samplesPerSecond = 48000
+	samples := tone(markFreq, samplesPerSecond, 48000)
+	received := ChannelSimulator{ClockDrift: 1000}.Apply(samples, samplesPerSecond)
+	if len(received) != 48048 {
+		t.Errorf("expected 48048 samples at +1000 ppm, got %d", len(received))
+	}
+	// sampled faster the tone appears lower
+	if amplitude := toneAmplitude(received, markFreq/1.001, samplesPerSecond); math.Abs(amplitude-1) > 0.01 {
+		t.Errorf("expected the tone at %.1f Hz, got amplitude %.3f", markFreq/1.001, amplitude)
+	}
+}
+
+func TestChannelSimulatorDropouts(t *testing.T) {
+	const samplesPerSecond = 48000
+	samples := tone(markFreq, samplesPerSecond, 10*samplesPerSecond)
+	for i := range samples {
+		samples[i] += 2 // no zeros in the original
+	}
+	simulator := ChannelSimulator{DropoutRate: 2, DropoutLength: 20 * time.Millisecond, Seed: 1}
+	received := simulator.Apply(samples, samplesPerSecond)
+	dropped := 0
+	for _, sample := range received {
+		if sample == 0 {
+			dropped++
+		}
+	}
+	fraction := float64(dropped) / float64(len(received))
+	if fraction < 0.02 || fraction > 0.06 {
+		t.Errorf("expected about 4%% of the audio dropped, got %.1f%%", 100*fraction)
+	}
+}
+
+func TestChannelSimulatorNoise(t *testing.T) {
+	const samplesPerSecond = 48000
+	samples := tone(markFreq, samplesPerSecond, samplesPerSecond)
+	for _, snr := range []float64{20, 6, 0, -6} {
+		simulator := ChannelSimulator{Noise: true, SNR: snr, Seed: 1}
+		received := simulator.Apply(samples, samplesPerSecond)
+		var signal, noise float64
+		for i := range samples {
+			signal += samples[i] * samples[i]
+			noise += (received[i] - samples[i]) * (received[i] - samples[i])
+		}
+		if measured := 10 * math.Log10(signal/noise); math.Abs(measured-snr) > 0.2 {
+			t.Errorf("expected SNR %.0f dB, got %.2f dB", snr, measured)
+		}
+		again := simulator.Apply(samples, samplesPerSecond)
+		for i := range received {
+			if again[i] != received[i] {
+				t.Fatalf("expected the same seed to add the same noise")
+			}
+		}
+	}
+}
+
+func TestChannelSimulatorReceive(t *testing.T) {
+	const samplesPerSecond = 48000
+	ax25data := testAX25Data(t, "Test")
+	samples := ax25data.Encode().Modulate(samplesPerSecond)
+	simulator := ChannelSimulator{
+		Emphasis:        DeEmphasis,
+		Twist:           -3,
+		FrequencyOffset: 30,
+		ClockDrift:      200,
+		Noise:           true,
+		SNR:             10,
+		Seed:            1,
+	}
+	received := Receiver{}.Receive(simulator.Apply(samples, samplesPerSecond), samplesPerSecond)
+	if len(received) != 1 || string(received[0].AX25Data) != string(ax25data) {
+		t.Errorf("expected the frame received through the impaired channel, got %d", len(received))
+	}
+}
+
+// packetErrorRate returns the fraction of packets lost over the channel with a different seed for each
+func packetErrorRate(ax25data AX25Data, simulator ChannelSimulator, receiver Receiver, packets int) float64 {
+	const samplesPerSecond = 48000
+	samples := ax25data.Encode().Modulate(samplesPerSecond)
+	lost := 0
+	for i := 0; i < packets; i++ {
+		simulator.Seed = int64(i)
+		received := receiver.Receive(simulator.Apply(samples, samplesPerSecond), samplesPerSecond)
+		if len(received) != 1 || string(received[0].AX25Data) != string(ax25data) {
+			lost++
+		}
+	}
+	return float64(lost) / float64(packets)
+}
+
+func TestPacketErrorRate(t *testing.T) {
+	ax25data := testAX25Data(t, "Test")
+	receiver := Receiver{Demodulators: []Demodulator{{Name: "single", FilterWidth: 1, SpaceGain: 1}}}
+	high := packetErrorRate(ax25data, ChannelSimulator{Noise: true, SNR: 10}, receiver, 20)
+	low := packetErrorRate(ax25data, ChannelSimulator{Noise: true, SNR: -6}, receiver, 20)
+	if high > 0 || low < 0.5 {
+		t.Errorf("expected no packets lost at 10 dB and most lost at -6 dB, got %.2f and %.2f", high, low)
+	}
+}
+
+// BenchmarkPacketErrorRate reports the packet error rate of the default receiver at each SNR
+func BenchmarkPacketErrorRate(b *testing.B) {
+	report := PositionData{Callsign: "W1AW", Latitude: 41.7147, Longitude: -72.7272, Comment: "Test"}
+	ax25data, err := report.BasicAPRSReport()
+	if err != nil {
+		b.Fatal(err)
+	}
+	for _, snr := range []float64{-3, 0, 3, 6} {
+		b.Run(fmt.Sprintf("SNR %+.0f dB", snr), func(b *testing.B) {
+			simulator := ChannelSimulator{Emphasis: DeEmphasis, Noise: true, SNR: snr}
+			b.ReportMetric(packetErrorRate(ax25data, simulator, Receiver{FixBits: 1}, b.N), "PER")
+		})
+	}
+}
@@ -0,0 +1,272 @@
+package aprsgo
+
+// status.go contains routines for producing and parsing APRS status reports (>)
+// and station capabilities (<) packets
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	statusDataTypeIdentifier       = '>' // status report
+	capabilitiesDataTypeIdentifier = '<' // station capabilities
+)
+
+// StatusData contains the data to construct an APRS status report.
+// A status report carries either a timestamp or a Maidenhead locator with symbol, never both.
+type StatusData struct {
+	Callsign    string // limited to 6 ASCII characters
+	StationSSID SSID
+	Timestamp   time.Time // optional, sent as day/hours/minutes zulu
+	Locator     string    // optional 4 or 6 character Maidenhead locator, e.g. IO91SX
+	SymbolTable byte      // symbol table for the locator form, defaults to '/'
+	Symbol      byte      // symbol code for the locator form, defaults to '-'
+	BeamHeading float64   // degrees, in steps of 10, only sent with a non-zero ERP
+	ERP         float64   // effective radiated power in watts, 0 for none
+	Text        string
+}
+
+// Capability is a single token of a station capabilities packet, with an optional value
+type Capability struct {
+	Token string
+	Value string
+}
+
+// CapabilitiesData contains the data to construct an APRS station capabilities packet
+type CapabilitiesData struct {
+	Callsign     string // limited to 6 ASCII characters
+	StationSSID  SSID
+	Capabilities []Capability
+}
+
+// NewIGateCapabilities returns the capabilities of an IGate that has gated
+// msgCount messages and heard locCount local stations
+func NewIGateCapabilities(callsign string, ssid SSID, msgCount, locCount int) CapabilitiesData {
+	return CapabilitiesData{
+		Callsign:    callsign,
+		StationSSID: ssid,
+		Capabilities: []Capability{
+			{Token: "IGATE"},
+			{Token: "MSG_CNT", Value: strconv.Itoa(msgCount)},
+			{Token: "LOC_CNT", Value: strconv.Itoa(locCount)},
+		},
+	}
+}
+
+// StatusAPRSReport constructs an APRS status report
+func (data StatusData) StatusAPRSReport() (AX25Data, error) {
+	if err := data.Validate(); err != nil {
+		return nil, err
+	}
+
+	destinationAddress := Address{Callsign: Version, SSID: DestSSIDVIAPath}
+	sourceAddress := Address{Callsign: data.Callsign, SSID: data.StationSSID}
+	informationField := data.CalculateStatusInformationField()
+
+	ax25data := AssembleAX25Data(sourceAddress, destinationAddress, informationField)
+
+	return AX25Data(ax25data), nil
+}
+
+// CalculateStatusInformationField returns the status text with the optional timestamp,
+// locator and beam heading/power
+func (data StatusData) CalculateStatusInformationField() []byte {
+	informationField := []byte{statusDataTypeIdentifier}
+	if data.Locator != "" { // locator form, >IO91SX/G text
+		symbolTable, symbol := data.SymbolTable, data.Symbol
+		if symbolTable == 0 {
+			symbolTable = '/' // primary table
+		}
+		if symbol == 0 {
+			symbol = '-' // house
+		}
+		informationField = append(informationField, strings.ToUpper(data.Locator)...)
+		informationField = append(informationField, symbolTable, symbol)
+		if data.Text != "" {
+			informationField = append(informationField, ' ') // text after a locator is separated by a space
+		}
+	} else if !data.Timestamp.IsZero() {
+		informationField = append(informationField, formatDHMTimestamp(data.Timestamp)...)
+	}
+	informationField = append(informationField, data.Text...)
+	if data.ERP > 0 {
+		informationField = append(informationField, '^', beamHeadingCode(data.BeamHeading), erpCode(data.ERP))
+	}
+	return informationField
+}
+
+// formatDHMTimestamp formats a time as the 7 character day/hours/minutes zulu timestamp
+func formatDHMTimestamp(timestamp time.Time) string {
+	utc := timestamp.UTC()
+	return fmt.Sprintf("%02d%02d%02dz", utc.Day(), utc.Hour(), utc.Minute())
+}
+
+// parseDHMTimestamp parses a day/hours/minutes zulu timestamp, the month and year are unknown
+// so the returned time is in January of year 0
+func parseDHMTimestamp(timestamp string) (time.Time, error) {
+	if len(timestamp) != 7 || timestamp[6] != 'z' {
+		return time.Time{}, fmt.Errorf("malformed DHM timestamp %q", timestamp)
+	}
+	dhm, err := strconv.Atoi(timestamp[:6])
+	if err != nil || dhm < 0 {
+		return time.Time{}, fmt.Errorf("malformed DHM timestamp %q", timestamp)
+	}
+	day, hour, minute := dhm/10000, (dhm/100)%100, dhm%100
+	if day < 1 || day > 31 || hour > 23 || minute > 59 {
+		return time.Time{}, fmt.Errorf("DHM timestamp %q out of range", timestamp)
+	}
+	return time.Date(0, time.January, day, hour, minute, 0, 0, time.UTC), nil
+}
+
+// beamHeadingCode encodes a heading in tens of degrees as 0-9 and A-Z
+func beamHeadingCode(heading float64) byte {
+	code := int(math.Floor(math.Mod(heading+360, 360)/10+0.5)) % 36
+	return base36Digit(code)
+}
+
+// erpCode encodes the power as the digit whose square times ten is nearest the ERP
+func erpCode(erp float64) byte {
+	code := int(math.Floor(math.Sqrt(erp/10) + 0.5))
+	if code < 0 {
+		code = 0
+	}
+	if code > 35 {
+		code = 35
+	}
+	return base36Digit(code)
+}
+
+func base36Digit(value int) byte {
+	if value < 10 {
+		return byte('0' + value)
+	}
+	return byte('A' + value - 10)
+}
+
+func parseBase36Digit(digit byte) (int, error) {
+	switch {
+	case digit >= '0' && digit <= '9':
+		return int(digit - '0'), nil
+	case digit >= 'A' && digit <= 'Z':
+		return int(digit-'A') + 10, nil
+	}
+	return 0, fmt.Errorf("invalid base-36 digit %q", digit)
+}
+
+// isLocator reports whether s is a 4 or 6 character Maidenhead locator as used in status reports
+func isLocator(s string) bool {
+	return (len(s) == 4 || len(s) == 6) && ValidateLocator(s) == nil
+}
+
+// isSymbolTable reports whether c is the primary or alternate table identifier or an overlay character
+func isSymbolTable(c byte) bool {
+	return c == '/' || c == '\\' || (c >= '0' && c <= '9') || (c >= 'A' && c <= 'Z')
+}
+
+// ParseStatusInformationField parses the information field of an APRS status report
+func ParseStatusInformationField(informationField []byte) (StatusData, error) {
+	var data StatusData
+	if len(informationField) == 0 || informationField[0] != statusDataTypeIdentifier {
+		return data, errors.New("not a status report")
+	}
+	text := string(informationField[1:])
+
+	if len(text) >= 7 && text[6] == 'z' {
+		if timestamp, err := parseDHMTimestamp(text[:7]); err == nil {
+			data.Timestamp = timestamp
+			text = text[7:]
+		}
+	} else {
+		for _, n := range []int{6, 4} { // locator followed by symbol table and symbol
+			if len(text) >= n+2 && isLocator(text[:n]) && isSymbolTable(text[n]) && (len(text) == n+2 || text[n+2] == ' ') {
+				data.Locator = text[:n]
+				data.SymbolTable, data.Symbol = text[n], text[n+1]
+				text = strings.TrimPrefix(text[n+2:], " ")
+				break
+			}
+		}
+	}
+
+	if n := len(text); n >= 3 && text[n-3] == '^' { // beam heading and power
+		heading, headingErr := parseBase36Digit(text[n-2])
+		power, powerErr := parseBase36Digit(text[n-1])
+		if headingErr == nil && powerErr == nil {
+			data.BeamHeading = float64(10 * heading)
+			data.ERP = float64(10 * power * power)
+			text = text[:n-3]
+		}
+	}
+	data.Text = text
+	return data, nil
+}
+
+// CapabilitiesAPRSReport constructs an APRS station capabilities packet
+func (data CapabilitiesData) CapabilitiesAPRSReport() (AX25Data, error) {
+	if err := data.Validate(); err != nil {
+		return nil, err
+	}
+
+	destinationAddress := Address{Callsign: Version, SSID: DestSSIDVIAPath}
+	sourceAddress := Address{Callsign: data.Callsign, SSID: data.StationSSID}
+	informationField := data.CalculateCapabilitiesInformationField()
+
+	ax25data := AssembleAX25Data(sourceAddress, destinationAddress, informationField)
+
+	return AX25Data(ax25data), nil
+}
+
+// CalculateCapabilitiesInformationField returns the comma separated capabilities, e.g. <IGATE,MSG_CNT=10,LOC_CNT=5
+func (data CapabilitiesData) CalculateCapabilitiesInformationField() []byte {
+	informationField := []byte{capabilitiesDataTypeIdentifier}
+	for i, capability := range data.Capabilities {
+		if i > 0 {
+			informationField = append(informationField, ',')
+		}
+		informationField = append(informationField, capability.Token...)
+		if capability.Value != "" {
+			informationField = append(informationField, '=')
+			informationField = append(informationField, capability.Value...)
+		}
+	}
+	return informationField
+}
+
+// Value returns the value of the capability with the given token and whether it was present
+func (data CapabilitiesData) Value(token string) (string, bool) {
+	for _, capability := range data.Capabilities {
+		if capability.Token == token {
+			return capability.Value, true
+		}
+	}
+	return "", false
+}
+
+// ParseCapabilitiesInformationField parses the information field of a station capabilities packet
+func ParseCapabilitiesInformationField(informationField []byte) (CapabilitiesData, error) {
+	var data CapabilitiesData
+	if len(informationField) == 0 || informationField[0] != capabilitiesDataTypeIdentifier {
+		return data, errors.New("not a station capabilities packet")
+	}
+	for _, field := range strings.Split(string(informationField[1:]), ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		var capability Capability
+		if eq := strings.IndexByte(field, '='); eq >= 0 {
+			capability.Token, capability.Value = field[:eq], field[eq+1:]
+		} else {
+			capability.Token = field
+		}
+		if capability.Token == "" {
+			return data, fmt.Errorf("capability %q has no token", field)
+		}
+		data.Capabilities = append(data.Capabilities, capability)
+	}
+	return data, nil
+}
@@ -0,0 +1,104 @@
+package aprsgo
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStatusInformationField(t *testing.T) {
+	testCases := []struct {
+		In   StatusData
+		Want string
+	}{
+		{In: StatusData{Text: "Net Control Center"},
+			Want: ">Net Control Center",
+		},
+		{In: StatusData{Text: "Net Control Center", Timestamp: time.Date(2017, 5, 9, 23, 45, 30, 0, time.UTC)},
+			Want: ">092345zNet Control Center",
+		},
+		{In: StatusData{Text: "Monitoring", Locator: "io91sx", SymbolTable: '/', Symbol: 'G'},
+			Want: ">IO91SX/G Monitoring",
+		},
+		{In: StatusData{Locator: "IO91"},
+			Want: ">IO91/-",
+		},
+		{In: StatusData{Text: "Beam", BeamHeading: 110, ERP: 490},
+			Want: ">Beam^B7",
+		},
+		{In: StatusData{Text: "Beam", Locator: "FN31pr", SymbolTable: '\\', Symbol: 'a', BeamHeading: 0, ERP: 10},
+			Want: ">FN31PR\\a Beam^01",
+		},
+	}
+
+	for _, testCase := range testCases {
+		got := string(testCase.In.CalculateStatusInformationField())
+		if got != testCase.Want {
+			t.Errorf("Expected %s got %s", testCase.Want, got)
+		}
+		parsed, err := ParseStatusInformationField([]byte(got))
+		if err != nil {
+			t.Errorf("Parsing %s failed with error %v", got, err)
+			continue
+		}
+		if parsed.Text != testCase.In.Text {
+			t.Errorf("Parsing %s expected text %q got %q", got, testCase.In.Text, parsed.Text)
+		}
+		if parsed.ERP != testCase.In.ERP || parsed.BeamHeading != testCase.In.BeamHeading {
+			t.Errorf("Parsing %s expected beam %v at %vW got %v at %vW", got,
+				testCase.In.BeamHeading, testCase.In.ERP, parsed.BeamHeading, parsed.ERP)
+		}
+		if testCase.In.Locator != "" && (parsed.Locator != got[1:1+len(testCase.In.Locator)] || parsed.Symbol == 0) {
+			t.Errorf("Parsing %s expected locator %s got %s%c%c", got, testCase.In.Locator,
+				parsed.Locator, parsed.SymbolTable, parsed.Symbol)
+		}
+		if testCase.In.Timestamp.IsZero() != parsed.Timestamp.IsZero() {
+			t.Errorf("Parsing %s expected timestamp %v got %v", got, testCase.In.Timestamp, parsed.Timestamp)
+		} else if !parsed.Timestamp.IsZero() && formatDHMTimestamp(parsed.Timestamp) != formatDHMTimestamp(testCase.In.Timestamp) {
+			t.Errorf("Parsing %s expected timestamp %v got %v", got, testCase.In.Timestamp, parsed.Timestamp)
+		}
+	}
+
+	if _, err := ParseStatusInformationField([]byte("!4142.88N/07243.63W-Test")); err == nil {
+		t.Errorf("Parsing a position report as a status report should fail")
+	}
+	parsed, _ := ParseStatusInformationField([]byte(">IO91 is my grid"))
+	if parsed.Locator != "" || parsed.Text != "IO91 is my grid" {
+		t.Errorf("Text resembling a locator should remain text, got %+v", parsed)
+	}
+}
+
+func TestCapabilitiesInformationField(t *testing.T) {
+	data := NewIGateCapabilities("W1AW", 10, 23, 7)
+	got := string(data.CalculateCapabilitiesInformationField())
+	if want := "<IGATE,MSG_CNT=23,LOC_CNT=7"; got != want {
+		t.Errorf("Expected %s got %s", want, got)
+	}
+
+	parsed, err := ParseCapabilitiesInformationField([]byte(got))
+	if err != nil {
+		t.Fatalf("Parsing %s failed with error %v", got, err)
+	}
+	if _, ok := parsed.Value("IGATE"); !ok {
+		t.Errorf("Parsing %s expected an IGATE token", got)
+	}
+	if value, _ := parsed.Value("MSG_CNT"); value != "23" {
+		t.Errorf("Parsing %s expected MSG_CNT=23 got %s", got, value)
+	}
+	if value, _ := parsed.Value("LOC_CNT"); value != "7" {
+		t.Errorf("Parsing %s expected LOC_CNT=7 got %s", got, value)
+	}
+	if _, ok := parsed.Value("DIGI"); ok {
+		t.Errorf("Parsing %s found an unexpected DIGI token", got)
+	}
+
+	ax25data, err := data.CapabilitiesAPRSReport()
+	if err != nil {
+		t.Fatalf("Report failed validation: %v", err)
+	}
+	if info := string(ax25data[16 : len(ax25data)-2]); info != got {
+		t.Errorf("Expected information field %s, got %s", got, info)
+	}
+	if _, err := ParseCapabilitiesInformationField([]byte("<=5")); err == nil {
+		t.Errorf("Parsing a capability without a token should fail")
+	}
+}
@@ -0,0 +1,138 @@
+package aprsgo
+
+// thirdparty.go contains routines for encapsulating packets from other stations in
+// third-party (}) packets and for unwrapping them again
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	thirdPartyDataTypeIdentifier = '}' // third-party traffic
+	maxThirdPartyDepth           = 8   // nested third-party headers that will be unwrapped
+	networkPathTCPIP             = "TCPIP"
+)
+
+// ThirdPartyData contains the data to construct an APRS third-party packet,
+// the gateway station sends a packet originated by another station
+type ThirdPartyData struct {
+	Callsign    string // the gateway, limited to 6 ASCII characters
+	StationSSID SSID
+	Path        []string // the digipeater path of the outer frame, e.g. WIDE1-1
+	Packet      Packet   // the encapsulated packet
+}
+
+// ThirdPartyAPRSReport constructs an APRS third-party packet
+func (data ThirdPartyData) ThirdPartyAPRSReport() (AX25Data, error) {
+	if err := data.Validate(); err != nil {
+		return nil, err
+	}
+
+	destinationAddress := Address{Callsign: Version, SSID: DestSSIDVIAPath}
+	sourceAddress := Address{Callsign: data.Callsign, SSID: data.StationSSID}
+	informationField := data.CalculateThirdPartyInformationField()
+	var digipeaters []Address
+	for _, digipeater := range data.Path {
+		address, _ := ParseAddress(digipeater) // the path is checked by Validate
+		digipeaters = append(digipeaters, address)
+	}
+
+	ax25data := AssembleAX25Data(sourceAddress, destinationAddress, informationField, digipeaters...)
+
+	return AX25Data(ax25data), nil
+}
+
+// CalculateThirdPartyInformationField returns the encapsulated packet as }SRC>DEST,PATH:info
+func (data ThirdPartyData) CalculateThirdPartyInformationField() []byte {
+	return append([]byte{thirdPartyDataTypeIdentifier}, data.Packet.String()...)
+}
+
+// ParseThirdPartyInformationField parses the encapsulated packet from a third-party information field
+func ParseThirdPartyInformationField(informationField []byte) (Packet, error) {
+	if len(informationField) == 0 || informationField[0] != thirdPartyDataTypeIdentifier {
+		return Packet{}, errors.New("not a third-party packet")
+	}
+	return ParsePacket(string(informationField[1:]))
+}
+
+// IsThirdParty returns true if the packet encapsulates a packet from another station
+func (packet Packet) IsThirdParty() bool {
+	return len(packet.Information) > 0 && packet.Information[0] == thirdPartyDataTypeIdentifier
+}
+
+// Unwrap returns the packet encapsulated in a third-party packet
+func (packet Packet) Unwrap() (Packet, error) {
+	return ParseThirdPartyInformationField(packet.Information)
+}
+
+// Originator unwraps any nested third-party headers and returns the innermost packet,
+// a packet that is not third-party traffic is its own originator
+func (packet Packet) Originator() (Packet, error) {
+	for depth := 0; packet.IsThirdParty(); depth++ {
+		if depth == maxThirdPartyDepth {
+			return packet, fmt.Errorf("more than %d nested third-party headers", maxThirdPartyDepth)
+		}
+		inner, err := packet.Unwrap()
+		if err != nil {
+			return packet, err
+		}
+		packet = inner
+	}
+	return packet, nil
+}
+
+// ViaInternet returns true if the packet has passed through the Internet,
+// marked by TCPIP or TCPXX in its path
+func (packet Packet) ViaInternet() bool {
+	for _, digipeater := range packet.Path {
+		switch strings.TrimSuffix(digipeater, "*") {
+		case networkPathTCPIP, "TCPXX":
+			return true
+		}
+	}
+	return false
+}
+
+// IsGateableToInternet returns true if an IGate may pass the packet heard on RF to the Internet.
+// Packets that came from the Internet, or whose path contains NOGATE or RFONLY are not gated,
+// the originator of third-party packets is checked as well.
+func (packet Packet) IsGateableToInternet() bool {
+	for depth := 0; depth <= maxThirdPartyDepth; depth++ {
+		if packet.ViaInternet() {
+			return false
+		}
+		for _, digipeater := range packet.Path {
+			switch strings.TrimSuffix(digipeater, "*") {
+			case "NOGATE", "RFONLY":
+				return false
+			}
+		}
+		if !packet.IsThirdParty() {
+			return true
+		}
+		inner, err := packet.Unwrap()
+		if err != nil {
+			return false
+		}
+		packet = inner
+	}
+	return false
+}
+
+// GateToRF encapsulates a packet received from the Internet for transmission by the gateway.
+// The network path of the packet is replaced by TCPIP,GATEWAY* as required for third-party traffic on RF.
+func GateToRF(packet Packet, gatewayCallsign string, gatewaySSID SSID, path []string) ThirdPartyData {
+	gateway := gatewayCallsign
+	if gatewaySSID != 0 {
+		gateway = fmt.Sprintf("%s-%d", gatewayCallsign, gatewaySSID)
+	}
+	packet.Path = []string{networkPathTCPIP, gateway + "*"}
+	return ThirdPartyData{
+		Callsign:    gatewayCallsign,
+		StationSSID: gatewaySSID,
+		Path:        path,
+		Packet:      packet,
+	}
+}
@@ -0,0 +1,91 @@
+package aprsgo
+
+import (
+	"testing"
+)
+
+func TestThirdPartyPacket(t *testing.T) {
+	inner, err := ParsePacket("KB2ICI-9>APRS,WIDE2-1,qAR,N0CALL:!4142.88N/07243.63W>Mobile")
+	if err != nil {
+		t.Fatalf("Parsing failed with error %v", err)
+	}
+	data := GateToRF(inner, "W1AW", 10, []string{"WIDE1-1"})
+	want := "}KB2ICI-9>APRS,TCPIP,W1AW-10*:!4142.88N/07243.63W>Mobile"
+	if got := string(data.CalculateThirdPartyInformationField()); got != want {
+		t.Errorf("Expected %s got %s", want, got)
+	}
+
+	ax25data, err := data.ThirdPartyAPRSReport()
+	if err != nil {
+		t.Fatalf("Report failed validation: %v", err)
+	}
+	outer, err := ax25data.Decode()
+	if err != nil {
+		t.Fatalf("Decoding failed with error %v", err)
+	}
+	if got := outer.String(); got != "W1AW-10>APZ001,WIDE1-1:"+want {
+		t.Errorf("Expected W1AW-10>APZ001,WIDE1-1:%s got %s", want, got)
+	}
+	if !outer.IsThirdParty() || outer.IsGateableToInternet() {
+		t.Errorf("Third-party traffic from the Internet should not be gated back")
+	}
+	unwrapped, err := outer.Unwrap()
+	if err != nil {
+		t.Fatalf("Unwrapping failed with error %v", err)
+	}
+	if unwrapped.Source != "KB2ICI-9" || unwrapped.Destination != "APRS" || !unwrapped.ViaInternet() ||
+		string(unwrapped.Information) != string(inner.Information) {
+		t.Errorf("Unwrapped packet %s does not match %s", unwrapped, inner)
+	}
+
+	// the unwrapped inner frame converts to a real AX.25 frame and back
+	ax25data, err = unwrapped.AX25Data()
+	if err != nil {
+		t.Fatalf("Converting %s failed with error %v", unwrapped, err)
+	}
+	decoded, err := ax25data.Decode()
+	if err != nil {
+		t.Fatalf("Decoding failed with error %v", err)
+	}
+	if decoded.String() != unwrapped.String() {
+		t.Errorf("Expected %s got %s", unwrapped, decoded)
+	}
+}
+
+func TestNestedThirdPartyPacket(t *testing.T) {
+	packet, err := ParsePacket("W1AW>APZ001,WIDE2-1:}N0CALL>APRS,W1XM*:}KB2ICI>APRS:>Nested status")
+	if err != nil {
+		t.Fatalf("Parsing failed with error %v", err)
+	}
+	if !packet.IsGateableToInternet() {
+		t.Errorf("Nested RF third-party traffic should be gateable")
+	}
+	originator, err := packet.Originator()
+	if err != nil {
+		t.Fatalf("Finding the originator failed with error %v", err)
+	}
+	if got := originator.String(); got != "KB2ICI>APRS:>Nested status" {
+		t.Errorf("Expected originator KB2ICI>APRS:>Nested status got %s", got)
+	}
+
+	noGate, _ := ParsePacket("W1AW>APZ001:}N0CALL>APRS,NOGATE:>Local only")
+	if noGate.IsGateableToInternet() {
+		t.Errorf("NOGATE in an inner path should prevent gating")
+	}
+	broken, _ := ParsePacket("W1AW>APZ001:}N0CALL,APRS:>no source")
+	if _, err := broken.Originator(); err == nil {
+		t.Errorf("Unwrapping a malformed inner header should fail")
+	}
+
+	for _, bad := range []string{"N0CALL>APRS", ">APRS:x", "N0CALL>:x", "N0CALL>APRS,,WIDE1-1:x"} {
+		if _, err := ParsePacket(bad); err == nil {
+			t.Errorf("Parsing %s expected to fail, but didn't", bad)
+		}
+	}
+	for _, bad := range []string{"N0CALL-16>APRS:x", "TOOLONGCALL>APRS:x", "N0CALL>APRS,wide1-1:x"} {
+		packet, _ := ParsePacket(bad)
+		if _, err := packet.AX25Data(); err == nil {
+			t.Errorf("Converting %s to AX.25 expected to fail, but didn't", bad)
+		}
+	}
+}
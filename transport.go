@@ -0,0 +1,92 @@
+package aprsgo
+
+// transport.go contains the interface for carrying whole AX.25 frames between stations
+// and an in-memory pipe for connecting two stations without a radio
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrTransportClosed is returned when reading from or writing to a closed FrameTransport
+var ErrTransportClosed = errors.New("frame transport closed")
+
+// FrameTransport carries complete AX.25 frames, including the Frame Check Sequence, between stations
+type FrameTransport interface {
+	ReadFrame() (AX25Data, error) // blocks until a frame is received
+	WriteFrame(AX25Data) error
+	Close() error
+}
+
+// NewFramePipe returns two connected in-memory FrameTransports, frames written to one are read from the other.
+// Writes never block, frames are queued until they are read.
+func NewFramePipe() (FrameTransport, FrameTransport) {
+	a, b := newFrameQueue(), newFrameQueue()
+	return &framePipe{in: a, out: b}, &framePipe{in: b, out: a}
+}
+
+type framePipe struct {
+	in  *frameQueue
+	out *frameQueue
+}
+
+func (p *framePipe) ReadFrame() (AX25Data, error) {
+	return p.in.pop()
+}
+
+func (p *framePipe) WriteFrame(ax25data AX25Data) error {
+	return p.out.push(append(AX25Data(nil), ax25data...))
+}
+
+// Close closes both directions of the pipe
+func (p *framePipe) Close() error {
+	p.in.close()
+	p.out.close()
+	return nil
+}
+
+// frameQueue is an unbounded queue of frames
+type frameQueue struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	frames []AX25Data
+	closed bool
+}
+
+func newFrameQueue() *frameQueue {
+	q := &frameQueue{}
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+func (q *frameQueue) push(ax25data AX25Data) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return ErrTransportClosed
+	}
+	q.frames = append(q.frames, ax25data)
+	q.cond.Signal()
+	return nil
+}
+
+func (q *frameQueue) pop() (AX25Data, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for len(q.frames) == 0 && !q.closed {
+		q.cond.Wait()
+	}
+	if q.closed {
+		return nil, ErrTransportClosed
+	}
+	ax25data := q.frames[0]
+	q.frames = q.frames[1:]
+	return ax25data, nil
+}
+
+func (q *frameQueue) close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.closed = true
+	q.cond.Broadcast()
+}
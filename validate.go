@@ -0,0 +1,255 @@
+package aprsgo
+
+// validate.go contains the checks on report data made before a report is assembled,
+// each problem is reported with its own error type
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Maximum lengths of the free text in each report format
+const (
+	MaxBasicCommentLength      = 43 // uncompressed position report
+	MaxCompressedCommentLength = 40 // compressed position report
+	MaxGridSquareCommentLength = 43 // Maidenhead locator beacon
+	MaxStatusLength            = 62 // status report, including any timestamp or locator
+	maxCallsignLength          = 6
+	maxSSID                    = 15
+)
+
+const maxInformationLength = 256 // the longest AX.25 information field
+
+// CallsignError reports a callsign that cannot be sent in an AX.25 address
+type CallsignError struct {
+	Callsign string
+	Reason   string
+}
+
+func (e *CallsignError) Error() string {
+	return fmt.Sprintf("invalid callsign %q: %s", e.Callsign, e.Reason)
+}
+
+// SSIDError reports an SSID outside 0 to 15
+type SSIDError struct {
+	SSID SSID
+}
+
+func (e *SSIDError) Error() string {
+	return fmt.Sprintf("SSID %d is not 0 to %d", e.SSID, maxSSID)
+}
+
+// LatitudeError reports a latitude outside -90 to 90 degrees
+type LatitudeError struct {
+	Latitude float64
+}
+
+func (e *LatitudeError) Error() string {
+	return fmt.Sprintf("latitude %v is not -90 to 90 degrees", e.Latitude)
+}
+
+// LongitudeError reports a longitude outside -180 to 180 degrees
+type LongitudeError struct {
+	Longitude float64
+}
+
+func (e *LongitudeError) Error() string {
+	return fmt.Sprintf("longitude %v is not -180 to 180 degrees", e.Longitude)
+}
+
+// AmbiguityError reports a position ambiguity outside 0 to 4 digits
+type AmbiguityError struct {
+	Ambiguity int
+}
+
+func (e *AmbiguityError) Error() string {
+	return fmt.Sprintf("position ambiguity %d is not 0 to %d digits", e.Ambiguity, maxAmbiguity)
+}
+
+// TextError reports a comment, status or message text that is too long for its format
+// or contains characters that are not allowed
+type TextError struct {
+	Field  string // comment, status or message
+	Text   string
+	Reason string
+}
+
+func (e *TextError) Error() string {
+	return fmt.Sprintf("invalid %s %q: %s", e.Field, e.Text, e.Reason)
+}
+
+// AddresseeError reports a message addressee that is empty or longer than 9 characters
+type AddresseeError struct {
+	Addressee string
+}
+
+func (e *AddresseeError) Error() string {
+	return fmt.Sprintf("addressee %q is not 1 to %d characters", e.Addressee, addresseeLength)
+}
+
+// ThirdPartyError reports an encapsulated packet that would not be parsed back out of a third-party packet
+type ThirdPartyError struct {
+	Packet string
+	Reason string
+}
+
+func (e *ThirdPartyError) Error() string {
+	return fmt.Sprintf("invalid third-party packet %q: %s", e.Packet, e.Reason)
+}
+
+// NewPositionData returns the PositionData for a station at the given position,
+// or the first problem found by Validate
+func NewPositionData(callsign string, ssid SSID, lat, long float64) (PositionData, error) {
+	data := PositionData{
+		Callsign:    callsign,
+		StationSSID: ssid,
+		Latitude:    lat,
+		Longitude:   long,
+	}
+	return data, data.Validate()
+}
+
+// Validate checks the position report can be sent in the basic format, see ValidateCompressed.
+// Any !DAO! extension is sent in the comment and shortens the comment allowed.
+func (data PositionData) Validate() error {
+	if data.sendsDAO() {
+		return data.validate(MaxBasicCommentLength - daoLength)
+	}
+	return data.validate(MaxBasicCommentLength)
+}
+
+// ValidateCompressed checks the position report can be sent in the compressed format
+func (data PositionData) ValidateCompressed() error {
+	return data.validate(MaxCompressedCommentLength)
+}
+
+func (data PositionData) validate(maxCommentLength int) error {
+	if err := data.validatePosition(); err != nil {
+		return err
+	}
+	if data.Ambiguity < 0 || data.Ambiguity > maxAmbiguity {
+		return &AmbiguityError{Ambiguity: data.Ambiguity}
+	}
+	return validateText("comment", data.Comment, maxCommentLength, "|~")
+}
+
+// validatePosition checks the station and coordinates, all that a raw NMEA report carries
+func (data PositionData) validatePosition() error {
+	if err := validateStation(data.Callsign, data.StationSSID); err != nil {
+		return err
+	}
+	if data.Latitude < -90 || data.Latitude > 90 || math.IsNaN(data.Latitude) {
+		return &LatitudeError{Latitude: data.Latitude}
+	}
+	if data.Longitude < -180 || data.Longitude > 180 || math.IsNaN(data.Longitude) {
+		return &LongitudeError{Longitude: data.Longitude}
+	}
+	return nil
+}
+
+// Validate checks the status report can be sent
+func (data StatusData) Validate() error {
+	if err := validateStation(data.Callsign, data.StationSSID); err != nil {
+		return err
+	}
+	if data.Locator != "" && !isLocator(data.Locator) {
+		return &LocatorError{Locator: data.Locator, Reason: "status reports carry a 4 or 6 character locator"}
+	}
+	return validateText("status", string(data.CalculateStatusInformationField()[1:]), MaxStatusLength, "|~")
+}
+
+// Validate checks the station capabilities can be sent
+func (data CapabilitiesData) Validate() error {
+	return validateStation(data.Callsign, data.StationSSID)
+}
+
+// Validate checks the message can be sent
+func (data MessageData) Validate() error {
+	if err := validateStation(data.Callsign, data.StationSSID); err != nil {
+		return err
+	}
+	if data.Addressee == "" || len(data.Addressee) > addresseeLength {
+		return &AddresseeError{Addressee: data.Addressee}
+	}
+	if len(data.MessageID) > maxMessageIDLength {
+		return &TextError{Field: "message number", Text: data.MessageID, Reason: fmt.Sprintf("longer than %d characters", maxMessageIDLength)}
+	}
+	return validateText("message", data.Text, maxMessageLength, "|~{")
+}
+
+// Validate checks the query can be sent
+func (data QueryData) Validate() error {
+	if err := validateStation(data.Callsign, data.StationSSID); err != nil {
+		return err
+	}
+	if data.IsDirected() && (data.Addressee == "" || len(data.Addressee) > addresseeLength) {
+		return &AddresseeError{Addressee: data.Addressee}
+	}
+	return nil
+}
+
+// Validate checks the bulletin can be sent
+func (data BulletinData) Validate() error {
+	if _, ok := bulletinKind(data.Addressee); !ok || len(data.Addressee) > addresseeLength {
+		return &AddresseeError{Addressee: data.Addressee}
+	}
+	message := MessageData{Callsign: data.Callsign, StationSSID: data.StationSSID, Addressee: data.Addressee, Text: data.Text}
+	return message.Validate()
+}
+
+// Validate checks the gateway, the digipeater path and the encapsulated packet can be sent.
+// The encapsulated addresses need not be AX.25 addresses, they only have to parse back out of the header.
+func (data ThirdPartyData) Validate() error {
+	if err := validateStation(data.Callsign, data.StationSSID); err != nil {
+		return err
+	}
+	for _, digipeater := range data.Path {
+		if _, err := ParseAddress(digipeater); err != nil {
+			return err
+		}
+	}
+	packet := data.Packet.String()
+	addresses := append([]string{data.Packet.Source, data.Packet.Destination}, data.Packet.Path...)
+	for _, address := range addresses {
+		if address == "" || address == "*" {
+			return &ThirdPartyError{Packet: packet, Reason: "the header has an empty address"}
+		}
+		for i := 0; i < len(address); i++ {
+			if address[i] <= ' ' || address[i] > '~' || strings.IndexByte(">,:", address[i]) >= 0 {
+				return &ThirdPartyError{Packet: packet, Reason: fmt.Sprintf("the header address %q is not printable ASCII without separators", address)}
+			}
+		}
+	}
+	if len(data.Packet.Information) == 0 {
+		return &ThirdPartyError{Packet: packet, Reason: "the information field is empty"}
+	}
+	if strings.ContainsAny(string(data.Packet.Information), "\r\n") {
+		return &ThirdPartyError{Packet: packet, Reason: "the information field contains a line break"}
+	}
+	if length := len(data.CalculateThirdPartyInformationField()); length > maxInformationLength {
+		return &ThirdPartyError{Packet: packet, Reason: fmt.Sprintf("%d characters encapsulated, longer than %d", length, maxInformationLength)}
+	}
+	return nil
+}
+
+// validateStation checks the callsign and SSID fit an AX.25 address
+func validateStation(callsign string, ssid SSID) error {
+	return Address{Callsign: callsign, SSID: ssid}.Validate()
+}
+
+// validateText checks text is printable ASCII without any of the excluded characters and at most maxLength long
+func validateText(field string, text string, maxLength int, excluded string) error {
+	if len(text) > maxLength {
+		return &TextError{Field: field, Text: text, Reason: fmt.Sprintf("longer than %d characters", maxLength)}
+	}
+	for i := 0; i < len(text); i++ {
+		if text[i] < ' ' || text[i] > '~' {
+			return &TextError{Field: field, Text: text, Reason: "only printable ASCII characters are allowed"}
+		}
+	}
+	if strings.ContainsAny(text, excluded) {
+		return &TextError{Field: field, Text: text, Reason: fmt.Sprintf("the characters %s are not allowed", excluded)}
+	}
+	return nil
+}
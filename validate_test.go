@@ -0,0 +1,121 @@
+package aprsgo
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestNewPositionData(t *testing.T) {
+	data, err := NewPositionData("W1AW", 7, 41.7147, -72.7272)
+	if err != nil {
+		t.Fatalf("Valid position failed with error %v", err)
+	}
+	if data.Callsign != "W1AW" || data.StationSSID != 7 || data.Latitude != 41.7147 || data.Longitude != -72.7272 {
+		t.Errorf("Unexpected position data %+v", data)
+	}
+
+	if _, err := NewPositionData("W1AW", 7, 91, -72.7272); err == nil {
+		t.Errorf("Latitude 91 should fail validation")
+	}
+}
+
+func TestPositionDataValidate(t *testing.T) {
+	valid := PositionData{Callsign: "W1AW", Latitude: 41.7147, Longitude: -72.7272, Comment: "Test"}
+
+	testCases := []struct {
+		Name string
+		In   func(*PositionData)
+		Want string // the expected error type, empty for valid data
+	}{
+		{Name: "valid", In: func(d *PositionData) {}},
+		{Name: "empty callsign", In: func(d *PositionData) { d.Callsign = "" }, Want: "*aprsgo.CallsignError"},
+		{Name: "long callsign", In: func(d *PositionData) { d.Callsign = "W1AWXYZ" }, Want: "*aprsgo.CallsignError"},
+		{Name: "lower case callsign", In: func(d *PositionData) { d.Callsign = "w1aw" }, Want: "*aprsgo.CallsignError"},
+		{Name: "SSID", In: func(d *PositionData) { d.StationSSID = 16 }, Want: "*aprsgo.SSIDError"},
+		{Name: "north pole", In: func(d *PositionData) { d.Latitude = 90 }},
+		{Name: "latitude", In: func(d *PositionData) { d.Latitude = -90.5 }, Want: "*aprsgo.LatitudeError"},
+		{Name: "longitude", In: func(d *PositionData) { d.Longitude = 180.5 }, Want: "*aprsgo.LongitudeError"},
+		{Name: "ambiguity", In: func(d *PositionData) { d.Ambiguity = 5 }, Want: "*aprsgo.AmbiguityError"},
+		{Name: "long comment", In: func(d *PositionData) { d.Comment = strings.Repeat("x", 44) }, Want: "*aprsgo.TextError"},
+		{Name: "DAO comment", In: func(d *PositionData) { d.Comment, d.DAO = strings.Repeat("x", 38), DAOHuman }},
+		{Name: "long DAO comment", In: func(d *PositionData) { d.Comment, d.DAO = strings.Repeat("x", 39), DAOBase91 }, Want: "*aprsgo.TextError"},
+		{Name: "ambiguous DAO comment", In: func(d *PositionData) { d.Comment, d.DAO, d.Ambiguity = strings.Repeat("x", 43), DAOHuman, 2 }},
+		{Name: "comment characters", In: func(d *PositionData) { d.Comment = "a|b" }, Want: "*aprsgo.TextError"},
+	}
+
+	for _, testCase := range testCases {
+		data := valid
+		testCase.In(&data)
+		err := data.Validate()
+		if got := errorType(err); got != testCase.Want {
+			t.Errorf("%s expected error %q got %q (%v)", testCase.Name, testCase.Want, got, err)
+		}
+		if _, reportErr := data.BasicAPRSReport(); errorType(reportErr) != testCase.Want {
+			t.Errorf("%s report expected error %q got %v", testCase.Name, testCase.Want, reportErr)
+		}
+	}
+
+	// the compressed format has a shorter comment
+	data := valid
+	data.Comment = strings.Repeat("x", 41)
+	if err := data.Validate(); err != nil {
+		t.Errorf("41 character comment should be valid in a basic report, got %v", err)
+	}
+	if _, err := data.CompressedAPRSReport(); errorType(err) != "*aprsgo.TextError" {
+		t.Errorf("41 character comment should not be valid in a compressed report, got %v", err)
+	}
+}
+
+func TestReportValidate(t *testing.T) {
+	testCases := []struct {
+		Name string
+		In   interface{ Validate() error }
+		Want string
+	}{
+		{Name: "status", In: StatusData{Callsign: "W1AW", Text: "Net Control"}},
+		{Name: "long status", In: StatusData{Callsign: "W1AW", Text: strings.Repeat("x", 63)}, Want: "*aprsgo.TextError"},
+		{Name: "status locator", In: StatusData{Callsign: "W1AW", Locator: "IO9"}, Want: "*aprsgo.LocatorError"},
+		{Name: "capabilities", In: CapabilitiesData{Callsign: "W1AW-1"}, Want: "*aprsgo.CallsignError"},
+		{Name: "message", In: MessageData{Callsign: "W1AW", Addressee: "KB2ICI-14", Text: "Hi", MessageID: "1"}},
+		{Name: "message addressee", In: MessageData{Callsign: "W1AW", Addressee: "KB2ICI-141", Text: "Hi"}, Want: "*aprsgo.AddresseeError"},
+		{Name: "message text", In: MessageData{Callsign: "W1AW", Addressee: "KB2ICI", Text: "{1"}, Want: "*aprsgo.TextError"},
+		{Name: "message number", In: MessageData{Callsign: "W1AW", Addressee: "KB2ICI", MessageID: "123456"}, Want: "*aprsgo.TextError"},
+		{Name: "query", In: QueryData{Callsign: "W1AW", Query: QueryStatus, Addressee: "KB2ICI"}},
+		{Name: "bulletin", In: BulletinData{Callsign: "W1AW", Addressee: "BLN0", Text: "Test"}},
+		{Name: "bulletin addressee", In: BulletinData{Callsign: "W1AW", Addressee: "W1AW-1", Text: "Test"}, Want: "*aprsgo.AddresseeError"},
+		{Name: "third-party", In: ThirdPartyData{Callsign: "W1AW", Packet: thirdParty("KB2ICI-9>APRS,TCPIP,W1AW*:>Test")}},
+		{Name: "third-party path", In: ThirdPartyData{Callsign: "W1AW", Path: []string{"WIDE1-16"}, Packet: thirdParty("KB2ICI>APRS:>Test")}, Want: "*aprsgo.SSIDError"},
+		{Name: "third-party no packet", In: ThirdPartyData{Callsign: "W1AW"}, Want: "*aprsgo.ThirdPartyError"},
+		{Name: "third-party source", In: ThirdPartyData{Callsign: "W1AW", Packet: Packet{Source: "KB2 ICI", Destination: "APRS", Information: []byte(">Test")}}, Want: "*aprsgo.ThirdPartyError"},
+		{Name: "third-party destination", In: ThirdPartyData{Callsign: "W1AW", Packet: Packet{Source: "KB2ICI", Destination: "APRS:", Information: []byte(">Test")}}, Want: "*aprsgo.ThirdPartyError"},
+		{Name: "third-party empty digipeater", In: ThirdPartyData{Callsign: "W1AW", Packet: Packet{Source: "KB2ICI", Destination: "APRS", Path: []string{""}, Information: []byte(">Test")}}, Want: "*aprsgo.ThirdPartyError"},
+		{Name: "third-party empty information", In: ThirdPartyData{Callsign: "W1AW", Packet: thirdParty("KB2ICI>APRS:")}, Want: "*aprsgo.ThirdPartyError"},
+		{Name: "third-party line break", In: ThirdPartyData{Callsign: "W1AW", Packet: thirdParty("KB2ICI>APRS:>Test\r\n")}, Want: "*aprsgo.ThirdPartyError"},
+		{Name: "third-party length", In: ThirdPartyData{Callsign: "W1AW", Packet: thirdParty("KB2ICI>APRS:>" + strings.Repeat("x", 250))}, Want: "*aprsgo.ThirdPartyError"},
+	}
+
+	for _, testCase := range testCases {
+		err := testCase.In.Validate()
+		if got := errorType(err); got != testCase.Want {
+			t.Errorf("%s expected error %q got %q (%v)", testCase.Name, testCase.Want, got, err)
+		}
+	}
+}
+
+// thirdParty parses a packet to encapsulate, panicking on malformed test data
+func thirdParty(tnc2 string) Packet {
+	packet, err := ParsePacket(tnc2)
+	if err != nil {
+		panic(err)
+	}
+	return packet
+}
+
+// errorType returns the type of err, or empty for nil
+func errorType(err error) string {
+	if err == nil {
+		return ""
+	}
+	return fmt.Sprintf("%T", err)
+}
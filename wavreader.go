@@ -0,0 +1,235 @@
+package aprsgo
+
+// wavreader.go contains the audio input of the receive path, reading RIFF WAVE files and raw PCM
+// streams into samples between -1.0 and 1.0 for each channel
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// AudioFormat describes the samples of a raw PCM stream, they are little endian and interleaved
+type AudioFormat struct {
+	SamplesPerSecond uint32
+	BitsPerSample    uint8 // 8, 16, 24 or 32, or 32 or 64 for float samples
+	NumChannels      uint8
+	Float            bool // IEEE float samples
+	Unsigned         bool // integer samples are offset by half the range rather than two's complement
+}
+
+// Audio is received audio with the samples of each channel between -1.0 and 1.0
+type Audio struct {
+	SamplesPerSecond uint32
+	Channels         [][]float64
+}
+
+// Channel returns the samples of channel n, counting from 0
+func (audio Audio) Channel(n int) ([]float64, error) {
+	if n < 0 || n >= len(audio.Channels) {
+		return nil, fmt.Errorf("channel %d requested of audio with %d channels", n, len(audio.Channels))
+	}
+	return audio.Channels[n], nil
+}
+
+// ReadWAVFile reads the audio of a WAV file
+func ReadWAVFile(filename string) (Audio, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return Audio{}, err
+	}
+	defer file.Close()
+	return ReadWAV(file)
+}
+
+// ReadWAV reads a RIFF WAVE stream of PCM, IEEE float or WAVE_FORMAT_EXTENSIBLE audio, a data chunk
+// cut short, as when a recording is still being written, is read up to the last whole sample
+func ReadWAV(r io.Reader) (Audio, error) {
+	file, err := io.ReadAll(r)
+	if err != nil {
+		return Audio{}, err
+	}
+	if len(file) < 12 || string(file[0:4]) != riffTag || string(file[8:12]) != waveTag {
+		return Audio{}, errors.New("not a RIFF WAVE file")
+	}
+	var format *AudioFormat
+	for chunks := file[12:]; len(chunks) >= 8; {
+		tag, size := string(chunks[0:4]), uint64(binary.LittleEndian.Uint32(chunks[4:8]))
+		body := chunks[8:]
+		if size < uint64(len(body)) {
+			body = body[:size]
+		}
+		switch tag {
+		case fmtTag:
+			if format, err = parseWAVFormat(body); err != nil {
+				return Audio{}, err
+			}
+		case dataTag:
+			if format == nil {
+				return Audio{}, errors.New("WAV data chunk before the fmt chunk")
+			}
+			return decodePCM(body, *format)
+		}
+		if size+size%2 >= uint64(len(chunks)-8) {
+			break
+		}
+		chunks = chunks[8+size+size%2:]
+	}
+	if format == nil {
+		return Audio{}, errors.New("WAV file has no fmt chunk")
+	}
+	return Audio{}, errors.New("WAV file has no data chunk")
+}
+
+// parseWAVFormat returns the format of the samples described by a fmt chunk
+func parseWAVFormat(chunk []byte) (*AudioFormat, error) {
+	if len(chunk) < 16 {
+		return nil, fmt.Errorf("WAV fmt chunk of %d bytes is too short", len(chunk))
+	}
+	formatTag := binary.LittleEndian.Uint16(chunk[0:])
+	bitsPerSample := binary.LittleEndian.Uint16(chunk[14:])
+	if formatTag == wavFormatExtensible {
+		if len(chunk) < 40 || string(chunk[26:40]) != string(wavSubFormatGUID[:]) {
+			return nil, errors.New("WAV extensible format with an unknown sub format")
+		}
+		formatTag = binary.LittleEndian.Uint16(chunk[24:])
+	}
+	format := &AudioFormat{
+		SamplesPerSecond: binary.LittleEndian.Uint32(chunk[4:]),
+		BitsPerSample:    uint8(bitsPerSample),
+		NumChannels:      uint8(binary.LittleEndian.Uint16(chunk[2:])),
+		Float:            formatTag == wavFormatFloat,
+		Unsigned:         bitsPerSample == 8, // 8-bit WAV samples are offset encoded
+	}
+	if formatTag != wavFormatPCM && formatTag != wavFormatFloat {
+		return nil, fmt.Errorf("WAV format %#04x is not PCM or IEEE float", formatTag)
+	}
+	if bitsPerSample > 64 || binary.LittleEndian.Uint16(chunk[2:]) > 255 {
+		return nil, fmt.Errorf("WAV format of %d bits per sample and %d channels is not supported",
+			bitsPerSample, binary.LittleEndian.Uint16(chunk[2:]))
+	}
+	return format, format.validate()
+}
+
+// validate checks the samples of the format can be decoded
+func (format AudioFormat) validate() error {
+	if format.SamplesPerSecond < symbolRate {
+		return fmt.Errorf("sample rate of %d samples per second is below the %d baud symbol rate", format.SamplesPerSecond, symbolRate)
+	}
+	if format.NumChannels == 0 {
+		return errors.New("audio must have at least one channel")
+	}
+	if format.Float {
+		if format.BitsPerSample != 32 && format.BitsPerSample != 64 {
+			return fmt.Errorf("float samples of %d bits are not supported, only 32 and 64", format.BitsPerSample)
+		}
+		return nil
+	}
+	if format.BitsPerSample%8 != 0 || format.BitsPerSample == 0 || format.BitsPerSample > 32 {
+		return fmt.Errorf("integer samples of %d bits are not supported, only 8, 16, 24 and 32", format.BitsPerSample)
+	}
+	return nil
+}
+
+// ReadRawPCM reads a headerless stream of interleaved samples in the format, until the end of the stream
+func ReadRawPCM(r io.Reader, format AudioFormat) (Audio, error) {
+	reader, err := NewPCMReader(r, format)
+	if err != nil {
+		return Audio{}, err
+	}
+	audio := Audio{SamplesPerSecond: format.SamplesPerSecond, Channels: make([][]float64, format.NumChannels)}
+	for {
+		block, err := reader.Next(pcmBlockFrames)
+		if err == io.EOF {
+			return audio, nil
+		}
+		if err != nil {
+			return Audio{}, err
+		}
+		for c := range audio.Channels {
+			audio.Channels[c] = append(audio.Channels[c], block.Channels[c]...)
+		}
+	}
+}
+
+var pcmBlockFrames = 4096 // frames ReadRawPCM reads at a time
+
+// PCMReader reads a headerless stream of interleaved samples in a format a block at a time, so audio
+// of any length, such as a live stream, can be received without holding all of it
+type PCMReader struct {
+	r      io.Reader
+	format AudioFormat
+	data   []byte // reused for the bytes of each block
+	err    error  // the error ending the stream, returned once the frames before it are
+}
+
+// NewPCMReader returns a reader of the samples in the format from r
+func NewPCMReader(r io.Reader, format AudioFormat) (*PCMReader, error) {
+	if err := format.validate(); err != nil {
+		return nil, err
+	}
+	return &PCMReader{r: r, format: format}, nil
+}
+
+// Next reads up to n frames, a sample of every channel, waiting for all n unless the stream ends.
+// It returns io.EOF at the end of the stream, an incomplete last frame is dropped
+func (p *PCMReader) Next(n int) (Audio, error) {
+	if p.err != nil {
+		return Audio{}, p.err
+	}
+	frameSize := int(p.format.BitsPerSample/8) * int(p.format.NumChannels)
+	if cap(p.data) < n*frameSize {
+		p.data = make([]byte, n*frameSize)
+	}
+	read, err := io.ReadFull(p.r, p.data[:n*frameSize])
+	switch err {
+	case nil:
+	case io.ErrUnexpectedEOF:
+		p.err = io.EOF
+	default:
+		p.err = err
+	}
+	if read < frameSize {
+		return Audio{}, p.err
+	}
+	return decodePCM(p.data[:read], p.format)
+}
+
+// decodePCM separates the channels of interleaved little endian samples and scales them to -1.0 to 1.0,
+// an incomplete last frame is dropped
+func decodePCM(data []byte, format AudioFormat) (Audio, error) {
+	bytesPerSample := int(format.BitsPerSample / 8)
+	numChannels := int(format.NumChannels)
+	frames := len(data) / (bytesPerSample * numChannels)
+	audio := Audio{SamplesPerSecond: format.SamplesPerSecond, Channels: make([][]float64, numChannels)}
+	for c := range audio.Channels {
+		audio.Channels[c] = make([]float64, frames)
+	}
+	fullScale := float64(uint64(1) << (format.BitsPerSample - 1))
+	shift := 64 - uint(format.BitsPerSample)
+	for n := 0; n < frames; n++ {
+		for c := 0; c < numChannels; c++ {
+			offset := (n*numChannels + c) * bytesPerSample
+			var raw uint64
+			for b := bytesPerSample - 1; b >= 0; b-- {
+				raw = raw<<8 | uint64(data[offset+b])
+			}
+			var sample float64
+			switch {
+			case format.Float && format.BitsPerSample == 32:
+				sample = float64(math.Float32frombits(uint32(raw)))
+			case format.Float:
+				sample = math.Float64frombits(raw)
+			case format.Unsigned:
+				sample = (float64(raw) - fullScale) / fullScale
+			default: // sign extend the two's complement sample
+				sample = float64(int64(raw<<shift)>>shift) / fullScale
+			}
+			audio.Channels[c][n] = sample
+		}
+	}
+	return audio, nil
+}
@@ -0,0 +1,251 @@
+package aprsgo
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestReadWAVRoundTrip(t *testing.T) {
+	symbolStream := testAX25Data(t, "Test").Encode()
+	tests := []struct {
+		bitsPerSample uint8
+		numChannels   uint8
+		float         bool
+	}{
+		{8, 1, false},
+		{16, 1, false},
+		{16, 2, false},
+		{24, 1, false},
+		{32, 1, false},
+		{16, 4, false},
+		{32, 1, true},
+		{32, 3, true},
+	}
+	for _, test := range tests {
+		const samplesPerSecond = 48000
+		name := fmt.Sprintf("%d bits %d channels float %t", test.bitsPerSample, test.numChannels, test.float)
+		params := WAVParams{
+			Filename:         filepath.Join(t.TempDir(), "test.wav"),
+			SamplesPerSecond: samplesPerSecond,
+			BitsPerSample:    test.bitsPerSample,
+			NumChannels:      test.numChannels,
+			Float:            test.float,
+		}
+		if err := symbolStream.WriteWAV(params); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		audio, err := ReadWAVFile(params.Filename)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if audio.SamplesPerSecond != samplesPerSecond || len(audio.Channels) != int(test.numChannels) {
+			t.Fatalf("%s: expected %d Hz and %d channels, got %d Hz and %d channels", name, samplesPerSecond,
+				test.numChannels, audio.SamplesPerSecond, len(audio.Channels))
+		}
+		expected := symbolStream.Modulate(samplesPerSecond)
+		tolerance := 1 / float64(uint64(1)<<(test.bitsPerSample-1)) // truncation to the sample size
+		if test.float {
+			tolerance = 1e-7
+		}
+		for c, channel := range audio.Channels {
+			if len(channel) != len(expected) {
+				t.Fatalf("%s: expected %d samples in channel %d, got %d", name, len(expected), c, len(channel))
+			}
+			for n := range expected {
+				if math.Abs(channel[n]-expected[n]) > tolerance {
+					t.Fatalf("%s: channel %d sample %d expected %v, got %v", name, c, n, expected[n], channel[n])
+				}
+			}
+		}
+		received := Receiver{}.Receive(audio.Channels[0], audio.SamplesPerSecond)
+		if len(received) != 1 {
+			t.Errorf("%s: expected the frame received from the file, got %d", name, len(received))
+		}
+	}
+}
+
+func TestReadRawPCM(t *testing.T) {
+	tests := []struct {
+		name     string
+		format   AudioFormat
+		data     []byte
+		channels [][]float64
+	}{
+		{"signed 16", AudioFormat{BitsPerSample: 16, NumChannels: 1},
+			[]byte{0x00, 0x40, 0x00, 0xc0, 0xff, 0x7f, 0x00, 0x80},
+			[][]float64{{0.5, -0.5, 32767.0 / 32768, -1}}},
+		{"unsigned 8", AudioFormat{BitsPerSample: 8, NumChannels: 1, Unsigned: true},
+			[]byte{0x80, 0xc0, 0x40, 0x00},
+			[][]float64{{0, 0.5, -0.5, -1}}},
+		{"signed 8", AudioFormat{BitsPerSample: 8, NumChannels: 1},
+			[]byte{0x00, 0x40, 0xc0, 0x80},
+			[][]float64{{0, 0.5, -0.5, -1}}},
+		{"unsigned 16", AudioFormat{BitsPerSample: 16, NumChannels: 1, Unsigned: true},
+			[]byte{0x00, 0x80, 0x00, 0xc0},
+			[][]float64{{0, 0.5}}},
+		{"signed 24 stereo", AudioFormat{BitsPerSample: 24, NumChannels: 2},
+			[]byte{0x00, 0x00, 0x40, 0x00, 0x00, 0xc0, 0x00, 0x00, 0x80, 0x00, 0x00, 0x20},
+			[][]float64{{0.5, -1}, {-0.5, 0.25}}},
+		{"signed 32", AudioFormat{BitsPerSample: 32, NumChannels: 1},
+			[]byte{0x00, 0x00, 0x00, 0xc0},
+			[][]float64{{-0.5}}},
+		{"float 32", AudioFormat{BitsPerSample: 32, NumChannels: 1, Float: true},
+			[]byte{0x00, 0x00, 0x00, 0x3f, 0x00, 0x00, 0x80, 0xbe},
+			[][]float64{{0.5, -0.25}}},
+		{"float 64", AudioFormat{BitsPerSample: 64, NumChannels: 1, Float: true},
+			[]byte{0, 0, 0, 0, 0, 0, 0xe0, 0xbf},
+			[][]float64{{-0.5}}},
+		{"partial frame", AudioFormat{BitsPerSample: 16, NumChannels: 2},
+			[]byte{0x00, 0x40, 0x00, 0xc0, 0x00},
+			[][]float64{{0.5}, {-0.5}}},
+	}
+	for _, test := range tests {
+		test.format.SamplesPerSecond = 8000
+		audio, err := ReadRawPCM(bytes.NewReader(test.data), test.format)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if fmt.Sprint(audio.Channels) != fmt.Sprint(test.channels) || audio.SamplesPerSecond != 8000 {
+			t.Errorf("%s: expected %v, got %v", test.name, test.channels, audio.Channels)
+		}
+	}
+}
+
+func TestReadRawPCMInvalid(t *testing.T) {
+	for _, format := range []AudioFormat{
+		{BitsPerSample: 12, NumChannels: 1},
+		{BitsPerSample: 16, NumChannels: 0},
+		{BitsPerSample: 16, NumChannels: 1, Float: true},
+		{BitsPerSample: 64, NumChannels: 1},
+		{SamplesPerSecond: 600, BitsPerSample: 16, NumChannels: 1},
+	} {
+		if format.SamplesPerSecond == 0 {
+			format.SamplesPerSecond = 8000
+		}
+		if _, err := ReadRawPCM(bytes.NewReader(nil), format); err == nil {
+			t.Errorf("%+v: expected an error", format)
+		}
+	}
+	if _, err := ReadRawPCM(bytes.NewReader([]byte{0, 0}), AudioFormat{BitsPerSample: 16, NumChannels: 1}); err == nil {
+		t.Errorf("expected an error for a sample rate of 0")
+	}
+}
+
+func TestPCMReader(t *testing.T) {
+	format := AudioFormat{SamplesPerSecond: 8000, BitsPerSample: 16, NumChannels: 2}
+	data := make([]byte, 4*1000+3) // an incomplete last frame
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+	expected, err := ReadRawPCM(bytes.NewReader(data), format)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range []int{1, 7, 1000, 4096} {
+		reader, err := NewPCMReader(bytes.NewReader(data), format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		channels := make([][]float64, 2)
+		for {
+			audio, err := reader.Next(n)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("%d frames: %v", n, err)
+			}
+			if len(audio.Channels[0]) > n || audio.SamplesPerSecond != 8000 {
+				t.Fatalf("%d frames: got %d at %d samples per second", n, len(audio.Channels[0]), audio.SamplesPerSecond)
+			}
+			for c := range channels {
+				channels[c] = append(channels[c], audio.Channels[c]...)
+			}
+		}
+		if len(channels[0]) != 1000 || fmt.Sprint(channels) != fmt.Sprint(expected.Channels) {
+			t.Errorf("%d frames: expected the samples read at once, got %d frames", n, len(channels[0]))
+		}
+		if _, err := reader.Next(n); err != io.EOF {
+			t.Errorf("%d frames: expected io.EOF after the end, got %v", n, err)
+		}
+	}
+
+	// the frames before a read error are returned before the error
+	failed := errors.New("read failed")
+	reader, err := NewPCMReader(io.MultiReader(bytes.NewReader(data[:10]), iotest.ErrReader(failed)), format)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if audio, err := reader.Next(100); err != nil || len(audio.Channels[0]) != 2 {
+		t.Errorf("expected the two frames before the error, got %v %v", audio.Channels, err)
+	}
+	if _, err := reader.Next(100); err != failed {
+		t.Errorf("expected the read error, got %v", err)
+	}
+	if _, err := NewPCMReader(bytes.NewReader(data), AudioFormat{SamplesPerSecond: 8000, BitsPerSample: 12, NumChannels: 1}); err == nil {
+		t.Errorf("expected an error for 12 bit samples")
+	}
+}
+
+func TestAudioChannel(t *testing.T) {
+	audio := Audio{Channels: [][]float64{{1}, {2}}}
+	if channel, err := audio.Channel(1); err != nil || channel[0] != 2 {
+		t.Errorf("expected the second channel, got %v %v", channel, err)
+	}
+	for _, n := range []int{-1, 2} {
+		if _, err := audio.Channel(n); err == nil {
+			t.Errorf("channel %d: expected an error", n)
+		}
+	}
+}
+
+func TestReadWAVErrors(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test.wav")
+	if err := (SymbolStream{mark, space}).WriteWAV(WAVParams{Filename: filename, SamplesPerSecond: 9600, BitsPerSample: 16, NumChannels: 1}); err != nil {
+		t.Fatal(err)
+	}
+	valid, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a recording still being written has a data chunk longer than the file
+	truncated := append([]byte{}, valid[:len(valid)-3]...)
+	audio, err := ReadWAV(bytes.NewReader(truncated))
+	if err != nil || len(audio.Channels[0]) != 14 {
+		t.Errorf("expected the whole samples of a truncated file, got %d %v", len(audio.Channels[0]), err)
+	}
+
+	unknown := append([]byte{}, valid...)
+	unknown[20] = 0x02 // ADPCM
+	noData := append([]byte{}, valid[:36]...)
+	noRate := append([]byte{}, valid...)
+	copy(noRate[24:28], []byte{0, 0, 0, 0})
+	tests := []struct {
+		name  string
+		data  []byte
+		error string
+	}{
+		{"empty", nil, "not a RIFF WAVE"},
+		{"not WAVE", []byte("RIFF\x04\x00\x00\x00AVI "), "not a RIFF WAVE"},
+		{"no fmt", []byte("RIFF\x04\x00\x00\x00WAVE"), "no fmt"},
+		{"no data", noData, "no data"},
+		{"data first", append([]byte("RIFF\x0c\x00\x00\x00WAVEdata\x00\x00\x00\x00"), valid[12:]...), "before the fmt"},
+		{"unknown format", unknown, "not PCM"},
+		{"no sample rate", noRate, "sample rate"},
+	}
+	for _, test := range tests {
+		if _, err := ReadWAV(bytes.NewReader(test.data)); err == nil || !strings.Contains(err.Error(), test.error) {
+			t.Errorf("%s: expected an error containing %q, got %v", test.name, test.error, err)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package aprsgo
+
+// xid.go contains the AX.25 version 2.2 XID information field used to negotiate
+// the parameters of a connected mode link
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	xidFormatIndicator = 0x82 // general purpose XID information
+	xidGroupIdentifier = 0x80 // parameter negotiation
+
+	// parameter identifiers
+	xidClassesOfProcedures = 2
+	xidHDLCOptions         = 3
+	xidIFieldLengthRx      = 6 // N1 in bits
+	xidWindowSizeRx        = 8 // k
+	xidAckTimer            = 9 // T1 in milliseconds
+	xidRetries             = 10
+
+	// classes of procedures bits
+	xidBalancedABM = 0x0100
+	xidHalfDuplex  = 0x2000
+	xidFullDuplex  = 0x4000
+
+	// HDLC optional functions bits
+	xidREJ           = 0x020000
+	xidSREJ          = 0x040000
+	xidExtendedAddr  = 0x800000
+	xidModulo8       = 0x000400
+	xidModulo128     = 0x000800
+	xidTEST          = 0x002000
+	xidFCS16         = 0x008000
+	xidSynchronousTx = 0x000002
+)
+
+// XIDParameters are the link parameters offered or agreed in an XID frame,
+// a zero value is not sent and leaves the parameter unchanged
+type XIDParameters struct {
+	FullDuplex      bool
+	SREJ            bool // selective reject is supported, otherwise only REJ
+	Modulo128       bool // modulo 128 sequence numbers are supported
+	MaxIFieldLength int  // N1 in bytes
+	Window          int  // k
+	AckTimer        time.Duration
+	Retries         int // N2
+}
+
+// XIDParameters returns the parameters offered in XID frames by a station using the link configuration
+func (config LinkConfig) XIDParameters() XIDParameters {
+	return XIDParameters{
+		SREJ:            config.SREJ,
+		Modulo128:       config.Extended,
+		MaxIFieldLength: config.MaxIFieldLength,
+		Window:          config.Window,
+		AckTimer:        config.AckTimer,
+		Retries:         config.Retries,
+	}
+}
+
+// Negotiate returns the parameters agreed from the local and remote offers: the smaller window and I field,
+// the longer ack timer and the larger number of retries, optional functions only if both support them
+func (local XIDParameters) Negotiate(remote XIDParameters) XIDParameters {
+	agreed := XIDParameters{
+		FullDuplex:      local.FullDuplex && remote.FullDuplex,
+		SREJ:            local.SREJ && remote.SREJ,
+		Modulo128:       local.Modulo128 && remote.Modulo128,
+		MaxIFieldLength: minNonZero(local.MaxIFieldLength, remote.MaxIFieldLength),
+		Window:          minNonZero(local.Window, remote.Window),
+		AckTimer:        local.AckTimer,
+		Retries:         local.Retries,
+	}
+	if remote.AckTimer > agreed.AckTimer {
+		agreed.AckTimer = remote.AckTimer
+	}
+	if remote.Retries > agreed.Retries {
+		agreed.Retries = remote.Retries
+	}
+	return agreed
+}
+
+// Apply returns the link configuration with the agreed parameters
+func (agreed XIDParameters) Apply(config LinkConfig) LinkConfig {
+	config.SREJ = agreed.SREJ
+	if agreed.MaxIFieldLength > 0 {
+		config.MaxIFieldLength = agreed.MaxIFieldLength
+	}
+	if agreed.Window > 0 {
+		config.Window = agreed.Window
+	}
+	if agreed.AckTimer > 0 {
+		config.AckTimer = agreed.AckTimer
+	}
+	if agreed.Retries > 0 {
+		config.Retries = agreed.Retries
+	}
+	return config
+}
+
+// Encode returns the XID information field
+func (p XIDParameters) Encode() []byte {
+	classes := xidBalancedABM | xidHalfDuplex
+	if p.FullDuplex {
+		classes = xidBalancedABM | xidFullDuplex
+	}
+	options := xidREJ | xidExtendedAddr | xidTEST | xidFCS16 | xidSynchronousTx
+	if p.SREJ {
+		options |= xidSREJ
+	}
+	if p.Modulo128 {
+		options |= xidModulo128
+	} else {
+		options |= xidModulo8
+	}
+
+	var group []byte
+	group = appendXIDParameter(group, xidClassesOfProcedures, classes, 2)
+	group = appendXIDParameter(group, xidHDLCOptions, options, 3)
+	if p.MaxIFieldLength > 0 {
+		group = appendXIDParameter(group, xidIFieldLengthRx, p.MaxIFieldLength*8, 2)
+	}
+	if p.Window > 0 {
+		group = appendXIDParameter(group, xidWindowSizeRx, p.Window, 1)
+	}
+	if p.AckTimer > 0 {
+		group = appendXIDParameter(group, xidAckTimer, int(p.AckTimer/time.Millisecond), 2)
+	}
+	if p.Retries > 0 {
+		group = appendXIDParameter(group, xidRetries, p.Retries, 1)
+	}
+	return append([]byte{xidFormatIndicator, xidGroupIdentifier, byte(len(group) >> 8), byte(len(group))}, group...)
+}
+
+// appendXIDParameter appends the identifier, length and big endian value of a parameter
+func appendXIDParameter(group []byte, identifier byte, value int, length int) []byte {
+	group = append(group, identifier, byte(length))
+	for i := length - 1; i >= 0; i-- {
+		group = append(group, byte(value>>(8*uint(i))))
+	}
+	return group
+}
+
+// DecodeXIDParameters parses an XID information field, unknown parameters are ignored
+func DecodeXIDParameters(info []byte) (XIDParameters, error) {
+	var p XIDParameters
+	if len(info) == 0 {
+		return p, nil // an empty XID offers nothing
+	}
+	if len(info) < 4 || info[0] != xidFormatIndicator || info[1] != xidGroupIdentifier {
+		return p, errors.New("not an AX.25 XID parameter negotiation")
+	}
+	groupLength := int(info[2])<<8 | int(info[3])
+	group := info[4:]
+	if groupLength > len(group) {
+		return p, fmt.Errorf("XID group length %d exceeds the %d bytes received", groupLength, len(group))
+	}
+	group = group[:groupLength]
+	for len(group) > 0 {
+		if len(group) < 2 || int(group[1]) > len(group)-2 {
+			return p, errors.New("XID parameter is truncated")
+		}
+		identifier, length := group[0], int(group[1])
+		value := 0
+		for _, b := range group[2 : 2+length] {
+			value = value<<8 | int(b)
+		}
+		group = group[2+length:]
+
+		switch identifier {
+		case xidClassesOfProcedures:
+			p.FullDuplex = value&xidFullDuplex != 0
+		case xidHDLCOptions:
+			p.SREJ = value&xidSREJ != 0
+			p.Modulo128 = value&xidModulo128 != 0
+		case xidIFieldLengthRx:
+			p.MaxIFieldLength = value / 8
+		case xidWindowSizeRx:
+			p.Window = value
+		case xidAckTimer:
+			p.AckTimer = time.Duration(value) * time.Millisecond
+		case xidRetries:
+			p.Retries = value
+		}
+	}
+	return p, nil
+}
+
+// minNonZero returns the smaller of a and b, ignoring a zero that was not offered
+func minNonZero(a, b int) int {
+	if a == 0 || (b != 0 && b < a) {
+		return b
+	}
+	return a
+}
@@ -0,0 +1,64 @@
+package aprsgo
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestXIDParametersEncode(t *testing.T) {
+	p := XIDParameters{SREJ: true, Modulo128: true, MaxIFieldLength: 256, Window: 32, AckTimer: 3 * time.Second, Retries: 10}
+	want := []byte{
+		0x82, 0x80, 0x00, 0x17,
+		0x02, 0x02, 0x21, 0x00, // balanced ABM, half duplex
+		0x03, 0x03, 0x86, 0xa8, 0x02, // REJ, SREJ, extended address, modulo 128, TEST, FCS-16, synchronous
+		0x06, 0x02, 0x08, 0x00, // 2048 bits
+		0x08, 0x01, 0x20,
+		0x09, 0x02, 0x0b, 0xb8,
+		0x0a, 0x01, 0x0a,
+	}
+	if got := p.Encode(); !bytes.Equal(got, want) {
+		t.Errorf("Expected % x got % x", want, got)
+	}
+
+	decoded, err := DecodeXIDParameters(want)
+	if err != nil || decoded != p {
+		t.Errorf("Expected %+v got %+v, %v", p, decoded, err)
+	}
+}
+
+func TestDecodeXIDParametersErrors(t *testing.T) {
+	testCases := []struct {
+		In   []byte
+		Fail bool
+	}{
+		{In: nil},
+		{In: []byte{0x82, 0x80, 0x00, 0x00}},
+		{In: []byte{0x82, 0x80, 0x00, 0x03, 0x0b, 0x01, 0x05}}, // unknown parameter ignored
+		{In: []byte{0x81, 0x80, 0x00, 0x00}, Fail: true},
+		{In: []byte{0x82, 0x80, 0x00, 0x08, 0x08, 0x01}, Fail: true},
+		{In: []byte{0x82, 0x80, 0x00, 0x03, 0x09, 0x02, 0x0b}, Fail: true},
+	}
+	for _, testCase := range testCases {
+		if _, err := DecodeXIDParameters(testCase.In); (err != nil) != testCase.Fail {
+			t.Errorf("Decoding % x expected failure %v got %v", testCase.In, testCase.Fail, err)
+		}
+	}
+}
+
+func TestXIDNegotiate(t *testing.T) {
+	local := XIDParameters{SREJ: true, Modulo128: true, MaxIFieldLength: 256, Window: 32, AckTimer: 3 * time.Second, Retries: 10}
+	remote := XIDParameters{Modulo128: true, MaxIFieldLength: 128, Window: 63, AckTimer: 5 * time.Second, Retries: 4}
+	want := XIDParameters{Modulo128: true, MaxIFieldLength: 128, Window: 32, AckTimer: 5 * time.Second, Retries: 10}
+	if got := local.Negotiate(remote); got != want {
+		t.Errorf("Expected %+v got %+v", want, got)
+	}
+	if got := local.Negotiate(XIDParameters{}); got.Window != local.Window || got.MaxIFieldLength != local.MaxIFieldLength {
+		t.Errorf("Parameters not offered should be left unchanged, got %+v", got)
+	}
+
+	config := want.Apply(LinkConfig{SREJ: true, Window: 7, MaxIFieldLength: 256, Retries: 10, AckTimer: time.Second})
+	if config.SREJ || config.Window != 32 || config.MaxIFieldLength != 128 || config.AckTimer != 5*time.Second {
+		t.Errorf("Unexpected configuration %+v", config)
+	}
+}